@@ -16,6 +16,16 @@ type Config struct {
 	UpdateCheckInterval  int    `yaml:"update_check_interval"`  // 업데이트 확인 주기 (초)
 	LogFile              string `yaml:"log_file"`               // 로그 파일 경로
 	AuthToken            string `yaml:"auth_token"`             // 인증 토큰 (보안)
+	UpdatePublicKey      string `yaml:"update_public_key"`      // 업데이트 서명 검증용 Ed25519 공개키 (base64)
+	Group                string `yaml:"group"`                  // 에이전트 그룹 (예: lab1)
+	Labels               map[string]string `yaml:"labels"`    // 플레이북 변수로 쓰는 에이전트 라벨 (예: room: "301")
+	MaxTerminalSessions  int    `yaml:"max_terminal_sessions"`  // 동시 원격 터미널 세션 최대 수
+	MaxConcurrentJobs    int    `yaml:"max_concurrent_jobs"`    // 동시에 실행할 명령 수
+	MaxQueuedJobs        int    `yaml:"max_queued_jobs"`        // 대기 큐 최대 길이 (초과 시 거절)
+	MaxInlineOutput      int64  `yaml:"max_inline_output"`      // 결과에 담는 stdout/stderr 최대 바이트 (0 = 제한 없음)
+	OutputDir            string `yaml:"output_dir"`             // 한도를 넘은 전체 출력 보관 디렉토리
+	OutputRetention      int    `yaml:"output_retention"`       // 전체 출력 보관 기간 (시간)
+	DetachedDir          string `yaml:"detached_dir"`           // 분리 실행 작업의 상태 파일과 로그 디렉토리
 }
 
 // DefaultConfig 기본 설정값 반환
@@ -25,6 +35,13 @@ func DefaultConfig() *Config {
 		StatusInterval:      5,
 		UpdateCheckInterval: 60,
 		LogFile:            "agent.log",
+		MaxTerminalSessions: 2,
+		MaxConcurrentJobs:   2,
+		MaxQueuedJobs:       20,
+		MaxInlineOutput:     64 * 1024,
+		OutputDir:           "output",
+		OutputRetention:     24,
+		DetachedDir:         "jobs",
 	}
 }
 
@@ -72,3 +89,30 @@ func (c *Config) GetStatusDuration() time.Duration {
 func (c *Config) GetUpdateCheckDuration() time.Duration {
 	return time.Duration(c.UpdateCheckInterval) * time.Second
 }
+
+// GetOutputRetention 전체 출력 보관 기간을 time.Duration으로 반환
+func (c *Config) GetOutputRetention() time.Duration {
+	return time.Duration(c.OutputRetention) * time.Hour
+}
+
+// GetOutputDir 전체 출력 보관 디렉토리 (상대 경로는 실행 파일 기준)
+func (c *Config) GetOutputDir() string {
+	return exeRelative(c.OutputDir)
+}
+
+// GetDetachedDir 분리 실행 작업 디렉토리 (상대 경로는 실행 파일 기준)
+func (c *Config) GetDetachedDir() string {
+	return exeRelative(c.DetachedDir)
+}
+
+// exeRelative 상대 경로를 실행 파일 디렉토리 기준 경로로 바꾼다.
+func exeRelative(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(filepath.Dir(exePath), path)
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 분리 실행 작업의 종료를 확인하는 주기
+const detachedPollInterval = 2 * time.Second
+
+// KillLost 감독 프로세스가 결과 없이 사라짐 (재부팅, 강제 종료 등)
+const KillLost = "lost"
+
+// DetachedJob 상태 파일에 기록되는 분리 실행 작업
+type DetachedJob struct {
+	ID        string         `json:"id"`
+	Request   CommandRequest `json:"request"`
+	PID       int            `json:"pid"`                 // 감독 프로세스 PID
+	PIDStart  uint64         `json:"pid_start,omitempty"` // 감독 프로세스 시작 시각 (PID 재사용 구분용, 단위는 플랫폼마다 다름)
+	StdoutLog string         `json:"stdout_log"`
+	StderrLog string         `json:"stderr_log"`
+	StartedAt time.Time      `json:"started_at"`
+}
+
+// detachedState 상태 파일 (detached_dir/state.json)
+type detachedState struct {
+	Jobs map[string]*DetachedJob `json:"jobs"`
+}
+
+// detachedJobs 에이전트와 별도 프로세스로 실행되어 에이전트 재시작 후에도 추적되는 작업
+//
+// 각 작업은 에이전트 실행 파일을 -run-detached 옵션으로 띄운 감독 프로세스가 실행하고,
+// 종료되면 <id>.result 파일에 결과를 남긴다. 에이전트는 이 파일을 기다렸다가 서버에 보고한다.
+type detachedJobs struct {
+	mu       sync.Mutex
+	dir      string
+	jobs     map[string]*DetachedJob
+	watching map[string]bool
+	conn     *agentConn
+}
+
+func newDetachedJobs(dir string) *detachedJobs {
+	d := &detachedJobs{
+		dir:      dir,
+		jobs:     make(map[string]*DetachedJob),
+		watching: make(map[string]bool),
+	}
+	var state detachedState
+	if err := loadDetachedState(dir, &state); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to load detached job state: %v", err)
+	}
+	for id, job := range state.Jobs {
+		d.jobs[id] = job
+	}
+	if len(d.jobs) > 0 {
+		log.Printf("Re-adopting %d detached job(s)", len(d.jobs))
+	}
+	return d
+}
+
+func (d *detachedJobs) statePath() string {
+	return filepath.Join(d.dir, "state.json")
+}
+
+func (d *detachedJobs) file(id, ext string) string {
+	return filepath.Join(d.dir, id+"."+ext)
+}
+
+// save 상태 파일을 원자적으로 저장한다. d.mu를 잡은 상태에서 호출한다.
+func (d *detachedJobs) save() error {
+	data, err := json.MarshalIndent(detachedState{Jobs: d.jobs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return err
+	}
+	tmp := d.statePath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.statePath())
+}
+
+func loadDetachedState(dir string, state *detachedState) error {
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, state)
+}
+
+// Attach 결과를 보낼 서버 연결을 지정하고, 이전 실행에서 남은 작업을 다시 추적한다.
+func (d *detachedJobs) Attach(conn *agentConn) {
+	d.mu.Lock()
+	d.conn = conn
+	var ids []string
+	for id := range d.jobs {
+		if !d.watching[id] {
+			d.watching[id] = true
+			ids = append(ids, id)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, id := range ids {
+		go d.watch(id)
+	}
+}
+
+// Start 감독 프로세스를 띄워 명령을 분리 실행한다.
+func (d *detachedJobs) Start(conn *agentConn, req CommandRequest) {
+	if req.ID == "" || !outputHandlePattern.MatchString(req.ID) {
+		sendJobRejection(conn, req, ResultStartFailed, "detached job requires a valid command id")
+		return
+	}
+
+	job := &DetachedJob{
+		ID:        req.ID,
+		Request:   req,
+		StdoutLog: d.file(req.ID, "stdout"),
+		StderrLog: d.file(req.ID, "stderr"),
+		StartedAt: time.Now(),
+	}
+
+	d.mu.Lock()
+	if _, exists := d.jobs[req.ID]; exists {
+		d.mu.Unlock()
+		return
+	}
+	d.jobs[req.ID] = job
+	err := d.save()
+	d.mu.Unlock()
+	if err != nil {
+		d.forget(req.ID)
+		sendJobRejection(conn, req, ResultStartFailed, "save detached job state: "+err.Error())
+		return
+	}
+
+	exePath, err := os.Executable()
+	if err == nil {
+		cmd := exec.Command(exePath, "-run-detached", req.ID)
+		setDetached(cmd)
+		if err = cmd.Start(); err == nil {
+			// 이 에이전트 프로세스가 살아 있는 동안은 직접 회수 (좀비 방지)
+			go cmd.Wait()
+
+			d.mu.Lock()
+			job.PID = cmd.Process.Pid
+			if started, err := processStartTime(job.PID); err == nil {
+				job.PIDStart = started
+			}
+			d.watching[req.ID] = true
+			if err := d.save(); err != nil {
+				log.Printf("Failed to save detached job state: %v", err)
+			}
+			d.mu.Unlock()
+
+			log.Printf("Detached job %s started (supervisor PID %d)", req.ID, job.PID)
+			info := job.info()
+			conn.WriteJSON(Message{Type: "command_started", Job: &info})
+			go d.watch(req.ID)
+			return
+		}
+	}
+	d.forget(req.ID)
+	sendJobRejection(conn, req, ResultStartFailed, "start detached job: "+err.Error())
+}
+
+// forget 작업을 상태 파일에서 지운다.
+func (d *detachedJobs) forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.jobs, id)
+	delete(d.watching, id)
+	if err := d.save(); err != nil {
+		log.Printf("Failed to save detached job state: %v", err)
+	}
+}
+
+// watch 결과 파일이 생기거나 감독 프로세스가 사라질 때까지 기다린 뒤 결과를 보고한다.
+func (d *detachedJobs) watch(id string) {
+	ticker := time.NewTicker(detachedPollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.mu.Lock()
+		job, ok := d.jobs[id]
+		d.mu.Unlock()
+		if !ok {
+			return
+		}
+
+		result, err := d.readResult(id)
+		if err == nil {
+			d.report(job, result)
+			return
+		}
+		if !job.supervisorAlive() {
+			// 결과를 쓰는 도중에 종료됐을 수 있으므로 한 번 더 확인
+			if result, err = d.readResult(id); err != nil {
+				result = &CommandResult{
+					Status:     ResultKilled,
+					KillReason: KillLost,
+					ExitCode:   -1,
+					Error:      "detached job supervisor exited without a result",
+					StartedAt:  job.StartedAt,
+				}
+				result.finish()
+			}
+			d.report(job, result)
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// supervisorAlive 감독 프로세스가 아직 실행 중인지 확인한다.
+// 재부팅이나 오랜 중단 뒤에는 PID가 다른 프로세스에 재사용됐을 수 있으므로
+// 시작 시각이 기록돼 있으면 같은 프로세스인지도 비교한다.
+func (j *DetachedJob) supervisorAlive() bool {
+	if !processAlive(j.PID) {
+		return false
+	}
+	if j.PIDStart == 0 {
+		return true
+	}
+	started, err := processStartTime(j.PID)
+	return err == nil && started == j.PIDStart
+}
+
+func (d *detachedJobs) readResult(id string) (*CommandResult, error) {
+	data, err := os.ReadFile(d.file(id, "result"))
+	if err != nil {
+		return nil, err
+	}
+	var result CommandResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// report 로그 파일에서 출력을 채워 서버에 결과를 보내고 작업 파일을 정리한다.
+func (d *detachedJobs) report(job *DetachedJob, result *CommandResult) {
+	result.ID = job.ID
+	result.Command = job.Request.Command
+	if job.Request.Exec != nil {
+		result.Command = job.Request.Exec.String()
+	}
+	if job.Request.Script != nil {
+		result.Command = job.Request.Script.String()
+		result.Script = job.Request.Script.Ref()
+	}
+	result.Detached = true
+
+	var outTrunc, errTrunc bool
+	var outErr, errErr error
+	result.Stdout, result.StdoutBytes, outTrunc, outErr = outputStore.Adopt(job.StdoutLog, job.ID, "stdout")
+	result.Stderr, result.StderrBytes, errTrunc, errErr = outputStore.Adopt(job.StderrLog, job.ID, "stderr")
+	if outTrunc || errTrunc {
+		result.Truncated = true
+		result.OutputHandle = job.ID
+		if err := errors.Join(outErr, errErr); err != nil {
+			log.Printf("Failed to keep full output of detached job %s: %v", job.ID, err)
+			result.OutputHandle = ""
+		}
+	}
+
+	d.mu.Lock()
+	conn := d.conn
+	d.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	if err := conn.WriteJSON(Message{Type: "command_result", Result: result}); err != nil {
+		// 다음 연결에서 다시 보고하도록 상태를 남겨 둔다
+		log.Printf("Failed to report detached job %s: %v", job.ID, err)
+		d.mu.Lock()
+		delete(d.watching, job.ID)
+		d.mu.Unlock()
+		return
+	}
+	log.Printf("Detached job %s finished (%s)", job.ID, result.Status)
+
+	d.forget(job.ID)
+	for _, ext := range []string{"stdout", "stderr", "result", "cancel"} {
+		os.Remove(d.file(job.ID, ext))
+	}
+}
+
+// Cancel 분리 실행 작업에 취소를 요청한다. 감독 프로세스가 취소 파일을 보고 프로세스 트리를 종료한다.
+func (d *detachedJobs) Cancel(id string) bool {
+	d.mu.Lock()
+	_, ok := d.jobs[id]
+	d.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if err := os.WriteFile(d.file(id, "cancel"), nil, 0600); err != nil {
+		log.Printf("Failed to cancel detached job %s: %v", id, err)
+		return false
+	}
+	return true
+}
+
+// info jobs 조회/시작 알림용 작업 정보
+func (j *DetachedJob) info() JobInfo {
+	started := j.StartedAt
+	info := JobInfo{
+		ID:        j.ID,
+		Command:   j.Request.Command,
+		Priority:  j.Request.Priority,
+		State:     "detached",
+		QueuedAt:  j.StartedAt,
+		StartedAt: &started,
+	}
+	if j.Request.Script != nil {
+		info.Command = j.Request.Script.String()
+	}
+	return info
+}
+
+// List jobs 조회용 분리 실행 작업 목록
+func (d *detachedJobs) List() []JobInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := make([]JobInfo, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		list = append(list, job.info())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].QueuedAt.Before(list[j].QueuedAt) })
+	return list
+}
+
+// runDetachedSupervisor -run-detached 로 실행된 감독 프로세스의 본체.
+// 명령을 실행하고 출력은 로그 파일에, 종료 결과는 <id>.result 파일에 기록한다.
+func runDetachedSupervisor(dir, id string) int {
+	var state detachedState
+	if err := loadDetachedState(dir, &state); err != nil {
+		log.Printf("detached %s: load state: %v", id, err)
+		return 1
+	}
+	job, ok := state.Jobs[id]
+	if !ok {
+		log.Printf("detached %s: job not found in state", id)
+		return 1
+	}
+	req := job.Request
+	result := &CommandResult{StartedAt: time.Now()}
+
+	writeResult := func() int {
+		result.finish()
+		data, _ := json.Marshal(result)
+		path := filepath.Join(dir, id+".result")
+		if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+			log.Printf("detached %s: write result: %v", id, err)
+			return 1
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			log.Printf("detached %s: write result: %v", id, err)
+			return 1
+		}
+		return 0
+	}
+	fail := func(err error) int {
+		result.Status = ResultStartFailed
+		result.ExitCode = -1
+		result.Error = err.Error()
+		return writeResult()
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if req.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), req.GetTimeout())
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	if req.Script != nil {
+		spec, cleanup, err := req.Script.prepare()
+		if err != nil {
+			return fail(err)
+		}
+		defer cleanup()
+		req.Exec = spec
+	}
+	cmd, err := buildCmd(ctx, req)
+	if err != nil {
+		return fail(err)
+	}
+
+	stdout, err := os.Create(job.StdoutLog)
+	if err != nil {
+		return fail(fmt.Errorf("create log: %v", err))
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(job.StderrLog)
+	if err != nil {
+		return fail(fmt.Errorf("create log: %v", err))
+	}
+	defer stderr.Close()
+
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessTree(cmd.Process)
+	}
+	cmd.WaitDelay = waitDelay
+
+	if err := cmd.Start(); err != nil {
+		return fail(err)
+	}
+
+	// 에이전트가 만든 취소 파일 확인
+	var canceled bool
+	var canceledMu sync.Mutex
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := os.Stat(filepath.Join(dir, id+".cancel")); err == nil {
+					canceledMu.Lock()
+					canceled = true
+					canceledMu.Unlock()
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	classifyExit(result, cmd, err)
+	if ctx.Err() != nil && result.Status != ResultSucceeded {
+		result.Status = ResultKilled
+		result.ExitCode = -1
+		canceledMu.Lock()
+		if canceled {
+			result.KillReason = KillCanceled
+		} else if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.KillReason = KillTimeout
+		}
+		canceledMu.Unlock()
+	}
+	return writeResult()
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 명령 실행 결과 상태
+const (
+	ResultSucceeded   = "succeeded"    // 종료 코드 0
+	ResultStartFailed = "start_failed" // 프로세스를 시작하지 못함
+	ResultExitNonZero = "exit_nonzero" // 0이 아닌 종료 코드
+	ResultKilled      = "killed"       // 시그널 등으로 강제 종료됨
+)
+
+// 강제 종료 사유
+const (
+	KillTimeout  = "timeout"
+	KillCanceled = "canceled"
+)
+
+// 프로세스 종료 후 출력 파이프를 기다리는 최대 시간
+const waitDelay = 5 * time.Second
+
+// CommandRequest 서버에서 받은 명령
+type CommandRequest struct {
+	ID          string         `json:"id"`
+	Command     string         `json:"command"`
+	Timeout     int            `json:"timeout,omitempty"`  // 초 (0 = 제한 없음)
+	Exec        *ExecSpec      `json:"exec,omitempty"`     // 구조화 실행 (있으면 Command 대신 사용)
+	Script      *ScriptPayload `json:"script,omitempty"`   // 스크립트 라이브러리 실행 (임시 파일로 실행)
+	File        *FilePayload   `json:"file,omitempty"`     // 파일 배포 (실행 없이 파일만 저장)
+	Priority    string         `json:"priority,omitempty"` // interactive (기본) | background
+	Detached    bool           `json:"detached,omitempty"` // 에이전트 재시작에도 살아남는 분리 실행
+	RequestedBy string         `json:"requested_by,omitempty"`
+	ApprovedBy  string         `json:"approved_by,omitempty"`
+}
+
+// GetTimeout 실행 제한 시간을 time.Duration으로 반환
+func (r *CommandRequest) GetTimeout() time.Duration {
+	return time.Duration(r.Timeout) * time.Second
+}
+
+// runningCommand 실행 중인 명령 (취소용)
+type runningCommand struct {
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+var (
+	runningMu sync.Mutex
+	running   = make(map[string]*runningCommand)
+)
+
+// markRunning 스케줄러가 작업을 실행 상태로 옮길 때 등록한다.
+// 프로세스가 시작되기 전에 도착한 취소도 놓치지 않기 위함이다.
+func markRunning(id string) {
+	runningMu.Lock()
+	running[id] = &runningCommand{}
+	runningMu.Unlock()
+}
+
+// clearRunning 작업이 끝나면 등록을 해제한다.
+func clearRunning(id string) {
+	runningMu.Lock()
+	delete(running, id)
+	runningMu.Unlock()
+}
+
+// cancelCommand ID로 실행 중인 명령을 취소한다. 해당 명령이 없으면 false.
+// 아직 프로세스가 시작되지 않았으면 취소 표시만 남기고 시작할 때 확인한다.
+func cancelCommand(id string) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	rc, ok := running[id]
+	if !ok {
+		return false
+	}
+	rc.canceled = true
+	if rc.cancel != nil {
+		rc.cancel()
+	}
+	return true
+}
+
+// stopCommands 실행 중인 명령을 모두 취소하고 timeout까지 끝나기를 기다린다.
+// 분리 실행 작업은 에이전트가 종료되어도 계속 실행되므로 건드리지 않는다.
+func stopCommands(timeout time.Duration) {
+	runningMu.Lock()
+	for _, rc := range running {
+		rc.canceled = true
+		if rc.cancel != nil {
+			rc.cancel()
+		}
+	}
+	runningMu.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		runningMu.Lock()
+		n := len(running)
+		runningMu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// attachCancel 실행 중인 명령에 취소 함수를 연결하고 이미 취소되었는지 반환한다.
+func attachCancel(id string, cancel context.CancelFunc) (*runningCommand, bool) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	rc, ok := running[id]
+	if !ok {
+		rc = &runningCommand{}
+		if id != "" {
+			running[id] = rc
+		}
+	}
+	rc.cancel = cancel
+	return rc, rc.canceled
+}
+
+// CommandResult 명령 실행 결과
+type CommandResult struct {
+	ID           string     `json:"id,omitempty"`
+	Command      string     `json:"command"`
+	Status       string     `json:"status"`
+	KillReason   string     `json:"kill_reason,omitempty"` // Status가 killed일 때: timeout, canceled
+	ExitCode     int        `json:"exit_code"`             // 시작 실패/강제 종료 시 -1
+	Stdout       string     `json:"stdout"`
+	Stderr       string     `json:"stderr"`
+	Streamed     bool       `json:"streamed,omitempty"` // 출력이 command_output 청크로 전송됨
+	Chunks       int        `json:"chunks,omitempty"`   // 전송한 청크 수
+	Error        string     `json:"error,omitempty"`
+	Truncated    bool       `json:"truncated,omitempty"`     // 출력이 max_inline_output을 넘어 앞부분만 담김
+	StdoutBytes  int64      `json:"stdout_bytes"`            // 전체 stdout 크기
+	StderrBytes  int64      `json:"stderr_bytes"`            // 전체 stderr 크기
+	OutputHandle string     `json:"output_handle,omitempty"` // 전체 출력 파일 핸들 (잘린 경우)
+	Script       *ScriptRef `json:"script,omitempty"`        // 실행한 스크립트 버전
+	Detached     bool       `json:"detached,omitempty"`      // 분리 실행 작업의 결과
+	StartedAt    time.Time  `json:"started_at"`
+	EndedAt      time.Time  `json:"ended_at"`
+	DurationMs   int64      `json:"duration_ms"`
+	Timestamp    time.Time  `json:"timestamp"` // 하위 호환 (= EndedAt)
+}
+
+// finish 종료 시각과 소요 시간을 채운다.
+func (r *CommandResult) finish() {
+	r.EndedAt = time.Now()
+	r.Timestamp = r.EndedAt
+	r.DurationMs = r.EndedAt.Sub(r.StartedAt).Milliseconds()
+}
+
+func executeCommand(conn *agentConn, req CommandRequest) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in executeCommand: %v", r)
+		}
+	}()
+
+	var result *CommandResult
+	// GUI 명령 확인 (gui: 접두사)
+	if req.File != nil {
+		result = writeFile(req.Command, req.File)
+	} else if guiCmd, ok := strings.CutPrefix(req.Command, "gui:"); ok && guiCmd != "" && req.Exec == nil && req.Script == nil {
+		result = runGUICommand(req.Command, guiCmd)
+	} else {
+		result = runProcess(conn, req)
+	}
+	result.ID = req.ID
+	if req.Script != nil {
+		result.Script = req.Script.Ref()
+	}
+
+	msg := Message{
+		Type:   "command_result",
+		Result: result,
+	}
+	conn.WriteJSON(msg)
+}
+
+// runGUICommand 사용자 세션에서 GUI 프로그램을 실행한다 (완료를 기다리지 않음).
+func runGUICommand(command, guiCmd string) *CommandResult {
+	log.Printf("Executing GUI command: %s", guiCmd)
+	result := &CommandResult{Command: command, StartedAt: time.Now()}
+
+	if err := runAsUser(guiCmd); err != nil {
+		log.Printf("GUI execution error: %v", err)
+		result.Status = ResultStartFailed
+		result.ExitCode = -1
+		result.Error = err.Error()
+	} else {
+		result.Status = ResultSucceeded
+		result.Stdout = "GUI command launched successfully"
+	}
+	result.finish()
+	return result
+}
+
+// runProcess 셸 명령 또는 구조화 명령을 실행하고 종료 코드와 출력을 수집한다.
+// 명령 ID가 있으면 출력을 실행 중에 청크로 전송하고, 결과에는 청크 수만 담는다.
+// 제한 시간이 지나거나 취소되면 프로세스 트리 전체를 종료한다.
+func runProcess(conn *agentConn, req CommandRequest) *CommandResult {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if req.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), req.GetTimeout())
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	rc, canceled := attachCancel(req.ID, cancel)
+
+	display := req.Command
+	if req.Exec != nil {
+		display = req.Exec.String()
+	}
+	if req.Script != nil {
+		display = req.Script.String()
+	}
+	result := &CommandResult{Command: display, StartedAt: time.Now()}
+	if canceled {
+		result.Status = ResultKilled
+		result.KillReason = KillCanceled
+		result.ExitCode = -1
+		result.Error = "canceled before start"
+		result.finish()
+		return result
+	}
+
+	if req.Script != nil {
+		// 스크립트는 임시 파일로 저장한 뒤 해당 셸로 실행
+		spec, cleanup, err := req.Script.prepare()
+		if err != nil {
+			result.Status = ResultStartFailed
+			result.ExitCode = -1
+			result.Error = err.Error()
+			result.finish()
+			return result
+		}
+		defer cleanup()
+		req.Exec = spec
+	}
+
+	cmd, err := buildCmd(ctx, req)
+	if err != nil {
+		result.Status = ResultStartFailed
+		result.ExitCode = -1
+		result.Error = err.Error()
+		result.finish()
+		return result
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessTree(cmd.Process)
+	}
+	cmd.WaitDelay = waitDelay
+
+	var stdout, stderr bytes.Buffer
+	var stream *outputStream
+	var outW, errW io.Writer = &stdout, &stderr
+	if req.ID != "" && conn != nil {
+		stream = newOutputStream(conn, req.ID)
+		outW = stream.Writer("stdout")
+		errW = stream.Writer("stderr")
+	}
+	// 한도를 넘는 출력은 파일로 보관하고 앞부분만 결과/청크로 보낸다
+	capOut := outputStore.Writer(outW, req.ID, "stdout")
+	capErr := outputStore.Writer(errW, req.ID, "stderr")
+	cmd.Stdout = capOut
+	cmd.Stderr = capErr
+
+	if err := cmd.Start(); err != nil {
+		capOut.Close()
+		capErr.Close()
+		if stream != nil {
+			stream.Close()
+		}
+		result.Status = ResultStartFailed
+		result.ExitCode = -1
+		result.Error = err.Error()
+		result.finish()
+		return result
+	}
+
+	err = cmd.Wait()
+	result.finish()
+	finishOutput(result, req.ID, capOut, capErr)
+	if stream != nil {
+		result.Streamed = true
+		result.Chunks = stream.Close()
+	} else {
+		result.Stdout = stdout.String()
+		result.Stderr = stderr.String()
+	}
+	classifyExit(result, cmd, err)
+
+	// 컨텍스트에 의해 종료된 경우 사유 기록
+	if ctx.Err() != nil && result.Status != ResultSucceeded {
+		result.Status = ResultKilled
+		result.ExitCode = -1
+		runningMu.Lock()
+		canceled = rc.canceled
+		runningMu.Unlock()
+		if canceled {
+			result.KillReason = KillCanceled
+		} else if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.KillReason = KillTimeout
+		}
+		log.Printf("Command %s killed (%s)", req.ID, result.KillReason)
+	}
+	return result
+}
+
+// finishOutput 출력 크기와 잘림 여부를 결과에 기록한다.
+func finishOutput(result *CommandResult, handle string, stdout, stderr *cappedOutput) {
+	outTrunc, outTotal, outErr := stdout.Close()
+	errTrunc, errTotal, errErr := stderr.Close()
+	result.StdoutBytes = outTotal
+	result.StderrBytes = errTotal
+	if !outTrunc && !errTrunc {
+		return
+	}
+
+	result.Truncated = true
+	if spillErr := errors.Join(outErr, errErr); spillErr != nil {
+		log.Printf("Failed to save full output of %s: %v", handle, spillErr)
+		result.Error = strings.TrimSpace(result.Error + "\n" + "full output not saved: " + spillErr.Error())
+		return
+	}
+	result.OutputHandle = handle
+}
+
+// classifyExit Wait 결과로 상태와 종료 코드를 결정한다.
+func classifyExit(result *CommandResult, cmd *exec.Cmd, err error) {
+	if err == nil {
+		result.Status = ResultSucceeded
+		result.ExitCode = 0
+		return
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// 출력 복사 실패 등 프로세스 외부 오류
+		result.Status = ResultExitNonZero
+		result.ExitCode = -1
+		if cmd.ProcessState != nil {
+			result.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		result.Error = err.Error()
+		return
+	}
+
+	result.Error = exitErr.Error()
+	result.ExitCode = exitErr.ExitCode()
+	if result.ExitCode == -1 {
+		// 시그널로 종료됨 (Unix)
+		result.Status = ResultKilled
+		return
+	}
+	result.Status = ResultExitNonZero
+}
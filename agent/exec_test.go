@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCancelBeforeProcessStart(t *testing.T) {
+	const id = "job-cancel-window"
+	markRunning(id)
+	t.Cleanup(func() { clearRunning(id) })
+
+	if !cancelCommand(id) {
+		t.Fatal("cancel of a job the scheduler started was dropped")
+	}
+
+	result := runProcess(nil, CommandRequest{ID: id, Command: "echo should-not-run"})
+	if result.Status != ResultKilled || result.KillReason != KillCanceled {
+		t.Fatalf("status = %s/%s, want %s/%s", result.Status, result.KillReason, ResultKilled, KillCanceled)
+	}
+	if result.Stdout != "" {
+		t.Errorf("command ran after cancel: stdout %q", result.Stdout)
+	}
+}
+
+func TestCancelUnknownCommand(t *testing.T) {
+	if cancelCommand("no-such-job") {
+		t.Fatal("cancel of an unknown job reported success")
+	}
+}
+
+func TestStopCommandsCancelsRunning(t *testing.T) {
+	const id = "job-stop"
+	markRunning(id)
+	t.Cleanup(func() { clearRunning(id) })
+
+	stopCommands(10 * time.Millisecond)
+	result := runProcess(nil, CommandRequest{ID: id, Command: "echo should-not-run"})
+	if result.Status != ResultKilled || result.KillReason != KillCanceled {
+		t.Fatalf("status = %s/%s, want %s/%s", result.Status, result.KillReason, ResultKilled, KillCanceled)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// 구조화 실행에서 허용하는 stdin 최대 크기
+const maxExecStdin = 1 << 20
+
+// ExecSpec 셸 문자열 대신 인자 배열로 실행하는 구조화 명령
+type ExecSpec struct {
+	Argv  []string          `json:"argv"`
+	Cwd   string            `json:"cwd,omitempty"`
+	Env   map[string]string `json:"env,omitempty"`   // 기존 환경 변수에 덮어씀
+	Stdin string            `json:"stdin,omitempty"` // 표준 입력으로 전달할 내용
+	Shell string            `json:"shell,omitempty"` // 비어 있으면 셸 없이 실행. sh, bash, cmd, powershell
+}
+
+// Validate 실행 전에 요청을 검증한다.
+func (s *ExecSpec) Validate() error {
+	if len(s.Argv) == 0 || s.Argv[0] == "" {
+		return fmt.Errorf("argv is empty")
+	}
+	for _, a := range s.Argv {
+		if strings.ContainsRune(a, 0) {
+			return fmt.Errorf("argv contains NUL byte")
+		}
+	}
+
+	switch s.Shell {
+	case "", "sh", "bash":
+	case "cmd", "powershell":
+		if len(s.Argv) > 1 {
+			return fmt.Errorf("shell %s accepts a single script in argv", s.Shell)
+		}
+	default:
+		return fmt.Errorf("unsupported shell %q", s.Shell)
+	}
+
+	if s.Cwd != "" {
+		if !filepath.IsAbs(s.Cwd) {
+			return fmt.Errorf("cwd must be an absolute path")
+		}
+		fi, err := os.Stat(s.Cwd)
+		if err != nil {
+			return fmt.Errorf("cwd: %v", err)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("cwd is not a directory")
+		}
+	}
+
+	for k, v := range s.Env {
+		if k == "" || strings.ContainsAny(k, "=\x00") || strings.ContainsRune(v, 0) {
+			return fmt.Errorf("invalid env variable %q", k)
+		}
+	}
+
+	if len(s.Stdin) > maxExecStdin {
+		return fmt.Errorf("stdin exceeds %d bytes", maxExecStdin)
+	}
+	return nil
+}
+
+// commandLine 실제로 실행할 프로그램과 인자 목록
+func (s *ExecSpec) commandLine() []string {
+	switch s.Shell {
+	case "sh", "bash":
+		// 나머지 argv는 스크립트의 $1, $2 ... 로 전달
+		return append([]string{s.Shell, "-c", s.Argv[0], s.Shell}, s.Argv[1:]...)
+	case "cmd":
+		return []string{"cmd", "/C", s.Argv[0]}
+	case "powershell":
+		ps := "powershell"
+		if runtime.GOOS != "windows" {
+			ps = "pwsh"
+		}
+		return []string{ps, "-NoProfile", "-NonInteractive", "-Command", s.Argv[0]}
+	}
+	return s.Argv
+}
+
+// String 결과/로그 표시용 명령 문자열
+func (s *ExecSpec) String() string {
+	parts := make([]string, 0, len(s.Argv)+1)
+	if s.Shell != "" {
+		parts = append(parts, "["+s.Shell+"]")
+	}
+	for _, a := range s.Argv {
+		if a == "" || strings.ContainsAny(a, " \t\"'") {
+			a = fmt.Sprintf("%q", a)
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
+
+// buildCmd 요청에 맞는 exec.Cmd를 만든다. 구조화 요청은 셸 해석 없이 실행된다.
+func buildCmd(ctx context.Context, req CommandRequest) (*exec.Cmd, error) {
+	if req.Exec == nil {
+		if runtime.GOOS == "windows" {
+			return exec.CommandContext(ctx, "cmd", "/C", req.Command), nil
+		}
+		return exec.CommandContext(ctx, "sh", "-c", req.Command), nil
+	}
+
+	spec := req.Exec
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid exec request: %v", err)
+	}
+
+	argv := spec.commandLine()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = spec.Cwd
+	if len(spec.Env) > 0 {
+		keys := make([]string, 0, len(spec.Env))
+		for k := range spec.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		cmd.Env = os.Environ()
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+spec.Env[k])
+		}
+	}
+	if spec.Stdin != "" {
+		cmd.Stdin = strings.NewReader(spec.Stdin)
+	}
+	return cmd, nil
+}
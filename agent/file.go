@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilePayload 서버가 보낸 파일 (플레이북 파일 배포 단계)
+type FilePayload struct {
+	Path    string `json:"path"`           // 저장할 절대 경로
+	Content string `json:"content"`        // 파일 내용 (base64)
+	SHA256  string `json:"sha256"`         // 디코딩한 내용의 SHA-256 (hex)
+	Mode    string `json:"mode,omitempty"` // 8진수 권한 (예: 0644, Windows에서는 무시)
+}
+
+// writeFile 파일을 같은 디렉토리의 임시 파일에 쓴 뒤 이름을 바꿔 원자적으로 저장한다.
+func writeFile(command string, f *FilePayload) *CommandResult {
+	result := &CommandResult{Command: command, StartedAt: time.Now()}
+	written, err := f.write()
+	result.finish()
+	if err != nil {
+		log.Printf("File push to %s failed: %v", f.Path, err)
+		result.Status = ResultStartFailed
+		result.ExitCode = -1
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = ResultSucceeded
+	result.Stdout = fmt.Sprintf("wrote %d bytes to %s", written, f.Path)
+	return result
+}
+
+func (f *FilePayload) write() (int, error) {
+	if !filepath.IsAbs(f.Path) {
+		return 0, fmt.Errorf("file path must be absolute: %q", f.Path)
+	}
+	data, err := base64.StdEncoding.DecodeString(f.Content)
+	if err != nil {
+		return 0, fmt.Errorf("decode file content: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), f.SHA256) {
+		return 0, fmt.Errorf("file checksum mismatch")
+	}
+
+	mode := os.FileMode(0644)
+	if f.Mode != "" {
+		m, err := strconv.ParseUint(f.Mode, 8, 32)
+		if err != nil || m > 0777 {
+			return 0, fmt.Errorf("invalid file mode %q", f.Mode)
+		}
+		mode = os.FileMode(m)
+	}
+
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f.Path)+".gopc-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
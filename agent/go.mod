@@ -2,14 +2,17 @@ module gopc-agent
 
 go 1.24.3
 
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/kardianos/service v1.2.4
+	golang.org/x/sys v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/gen2brain/shm v0.1.0 // indirect
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
-	github.com/kardianos/service v1.2.4 // indirect
 	github.com/kbinani/screenshot v0.0.0-20250624051815-089614a94018 // indirect
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e // indirect
-	golang.org/x/sys v0.34.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -32,7 +32,7 @@ echo Cleaning up previous build...
 if exist agent.exe del agent.exe
 
 echo Building Agent...
-go build -o agent.exe main.go session_windows.go update.go
+go build -o agent.exe .
 if %ERRORLEVEL% NEQ 0 (
     echo Build failed.
     pause
@@ -7,9 +7,11 @@ import (
 	"net"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -19,17 +21,30 @@ import (
 )
 
 type AgentInfo struct {
-	Hostname string `json:"hostname"`
-	OS       string `json:"os"`
-	Arch     string `json:"arch"`
-	MacAddr  string `json:"mac_addr"`
+	Hostname string            `json:"hostname"`
+	OS       string            `json:"os"`
+	Arch     string            `json:"arch"`
+	MacAddr  string            `json:"mac_addr"`
+	Group    string            `json:"group,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
 }
 
 type AgentStatus struct {
-	MemoryUsage float64 `json:"memory_usage"`
-	CPUUsage    float64 `json:"cpu_usage"`
-	DiskUsage   float64 `json:"disk_usage"`
-	Uptime      uint64  `json:"uptime"`
+	MemoryUsage float64 `json:"memory_usage"` // 시스템 메모리 사용률 (%)
+	CPUUsage    float64 `json:"cpu_usage"`    // 이전 보고 이후 CPU 사용률 (%)
+	DiskUsage   float64 `json:"disk_usage"`   // 시스템 디스크(/ 또는 C:\) 사용률 (%)
+	Uptime      uint64  `json:"uptime"`       // 호스트 가동 시간 (초)
+
+	MemoryTotal uint64      `json:"memory_total,omitempty"` // bytes
+	MemoryUsed  uint64      `json:"memory_used,omitempty"`  // bytes
+	Load        []float64   `json:"load,omitempty"`         // 1, 5, 15분 평균 부하 (Linux)
+	Disks       []DiskUsage `json:"disks,omitempty"`        // 마운트 지점(드라이브)별 사용량
+
+	AgentUptime uint64  `json:"agent_uptime"` // 에이전트 실행 시간 (초)
+	AgentMemory float64 `json:"agent_memory"` // 에이전트 Go 힙 (MB)
+
+	Network   []NetInterface `json:"network,omitempty"`    // 네트워크 인터페이스별 주소, 링크 상태, 송수신량
+	LatencyMs float64        `json:"latency_ms,omitempty"` // 서버와의 WebSocket 왕복 시간 (마지막 ping/pong)
 }
 
 type Message struct {
@@ -37,10 +52,63 @@ type Message struct {
 	Info   *AgentInfo   `json:"info,omitempty"`
 	Status *AgentStatus `json:"status,omitempty"`
 	Result interface{}  `json:"result,omitempty"`
+	Output *OutputChunk `json:"output,omitempty"`
+	PTY    *PTYMessage  `json:"pty,omitempty"`
+	Jobs   *JobList     `json:"jobs,omitempty"`
+	Job    *JobInfo     `json:"job,omitempty"`
+	Fetch  *FetchChunk  `json:"fetch,omitempty"`
+
+	Processes *ProcessList `json:"processes,omitempty"`
+	Kill      *KillResult  `json:"kill,omitempty"`
+}
+
+// agentConn 여러 고루틴에서 동시에 쓸 수 있도록 쓰기를 직렬화한 WebSocket 연결
+type agentConn struct {
+	*websocket.Conn
+	mu      sync.Mutex
+	latency atomic.Int64 // 마지막 ping/pong 왕복 시간 (ns)
+}
+
+func newAgentConn(ws *websocket.Conn) *agentConn {
+	c := &agentConn{Conn: ws}
+	// ping에 보낸 시각이 그대로 돌아온다 (서버는 기본 ping 처리기로 응답)
+	ws.SetPongHandler(func(data string) error {
+		if sent, err := strconv.ParseInt(data, 10, 64); err == nil {
+			c.latency.Store(time.Now().UnixNano() - sent)
+		}
+		return nil
+	})
+	return c
+}
+
+func (c *agentConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+// Ping 왕복 시간을 재기 위해 보낸 시각을 담아 ping을 보낸다 (WriteControl은 동시에 호출해도 안전).
+func (c *agentConn) Ping() error {
+	now := time.Now()
+	return c.WriteControl(websocket.PingMessage, []byte(strconv.FormatInt(now.UnixNano(), 10)), now.Add(10*time.Second))
+}
+
+// Latency 마지막으로 잰 왕복 시간 (ms, 아직 없으면 0)
+func (c *agentConn) Latency() float64 {
+	return float64(c.latency.Load()) / float64(time.Millisecond)
 }
 
 var startTime = time.Now()
 
+// 명령 실행 스케줄러 (동시 실행 수 제한, 우선순위 큐)
+var scheduler *jobScheduler
+
+// 한도를 넘는 명령 출력 보관소
+var outputStore *outputFiles
+
+// 에이전트 재시작에도 추적되는 분리 실행 작업
+var detached *detachedJobs
+
 // Service setup
 type program struct{}
 
@@ -50,11 +118,14 @@ func (p *program) Start(s service.Service) error {
 }
 
 func (p *program) Stop(s service.Service) error {
+	// 유닛이 KillMode=process라 systemd가 남은 자식 프로세스를 정리하지 않는다
+	stopCommands(waitDelay)
 	return nil
 }
 
 func main() {
 	svcFlag := flag.String("service", "", "Control the system service.")
+	detachedID := flag.String("run-detached", "", "Run a detached job by id (started by the agent).")
 	flag.Parse()
 
 	// 설정 로드
@@ -75,10 +146,16 @@ func main() {
 		}
 	}
 
+	// 분리 실행 작업의 감독 프로세스
+	if *detachedID != "" {
+		os.Exit(runDetachedSupervisor(cfg.GetDetachedDir(), *detachedID))
+	}
+
 	svcConfig := &service.Config{
 		Name:        "GoPCAgent",
 		DisplayName: "Go PC Agent",
 		Description: "Agent for Go PC Management System",
+		Option:      serviceOptions(),
 	}
 
 	prg := &program{}
@@ -106,15 +183,19 @@ func (p *program) run() {
 	// 설정 로드
 	cfg := config.Load()
 
+	outputStore = newOutputFiles(cfg.GetOutputDir(), cfg.MaxInlineOutput, cfg.GetOutputRetention())
+	go outputStore.runCleanup()
+	detached = newDetachedJobs(cfg.GetDetachedDir())
+
 	u := url.URL{Scheme: "ws", Host: cfg.ServerAddress, Path: "/ws-agent"}
 	log.Printf("connecting to %s", u.String())
 
-	var conn *websocket.Conn
+	var ws *websocket.Conn
 	var err error
 
 	// 연결 재시도 루프
 	for {
-		conn, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
+		ws, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
 		if err != nil {
 			log.Println("dial error:", err)
 			time.Sleep(5 * time.Second)
@@ -122,11 +203,15 @@ func (p *program) run() {
 		}
 		break
 	}
+	conn := newAgentConn(ws)
 	defer conn.Close()
 	log.Println("Connected to server")
 
+	scheduler = newJobScheduler(cfg.MaxConcurrentJobs, cfg.MaxQueuedJobs)
+	detached.Attach(conn)
+
 	// 등록 메시지 전송
-	sendRegister(conn)
+	sendRegister(conn, cfg)
 
 	// 상태 업데이트 및 버전 확인 고루틴
 	go func() {
@@ -138,9 +223,10 @@ func (p *program) run() {
 		for {
 			select {
 			case <-ticker.C:
+				conn.Ping()
 				sendStatus(conn)
 			case <-updateTicker.C:
-				checkForUpdates(cfg.ServerAddress)
+				checkForUpdates(conn, cfg)
 			}
 		}
 	}()
@@ -150,25 +236,60 @@ func (p *program) run() {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			log.Println("read:", err)
+			closeAllTermSessions()
 			return
 		}
 		log.Printf("recv: %s", message)
 
 		// 명령 메시지 파싱
 		var cmdMsg struct {
-			Token   string `json:"token"`
-			Command string `json:"command"`
+			Type  string `json:"type"`
+			Token string `json:"token"`
+			CommandRequest
+			PTYRequest
+			FetchRequest
+			KillRequest
 		}
 
 		// JSON 파싱 시도
-		if err := json.Unmarshal(message, &cmdMsg); err == nil && cmdMsg.Command != "" {
+		if err := json.Unmarshal(message, &cmdMsg); err == nil && (cmdMsg.Command != "" || cmdMsg.Exec != nil || cmdMsg.Type != "") {
 			// 토큰 검증
 			if cfg.AuthToken != "" && cmdMsg.Token != cfg.AuthToken {
 				log.Printf("Security alert: Unauthorized command attempt (Invalid Token)")
 				continue
 			}
-			// 명령 실행
-			go executeCommand(conn, cmdMsg.Command)
+
+			switch cmdMsg.Type {
+			case "", "command":
+				if cmdMsg.ApprovedBy != "" {
+					log.Printf("Command requested by %s, approved by %s", cmdMsg.RequestedBy, cmdMsg.ApprovedBy)
+				}
+				if cmdMsg.Detached {
+					detached.Start(conn, cmdMsg.CommandRequest)
+					break
+				}
+				// 명령 실행 (스케줄러 큐에 추가)
+				scheduler.Submit(conn, cmdMsg.CommandRequest)
+			case "cancel":
+				if cancelCommand(cmdMsg.ID) || scheduler.CancelQueued(cmdMsg.ID) || detached.Cancel(cmdMsg.ID) {
+					log.Printf("Command %s canceled by server", cmdMsg.ID)
+				}
+			case "jobs":
+				jobs := scheduler.List()
+				jobs.Detached = detached.List()
+				conn.WriteJSON(Message{Type: "jobs", Jobs: &jobs})
+			case "processes":
+				// 처음 조회는 CPU 사용률을 재느라 잠시 걸린다
+				go sendProcesses(conn, cmdMsg.RequestID)
+			case "kill_process":
+				go handleKillProcess(conn, cmdMsg.RequestID, cmdMsg.KillRequest)
+			case "fetch_output":
+				go sendOutputFile(conn, cmdMsg.FetchRequest)
+			case "pty_open", "pty_input", "pty_resize", "pty_close":
+				handlePTYMessage(conn, cfg, cmdMsg.Type, cmdMsg.PTYRequest)
+			default:
+				log.Printf("Unknown message type: %s", cmdMsg.Type)
+			}
 		} else {
 			// JSON이 아니거나 형식이 맞지 않는 경우 (레거시 호환성 또는 공격 시도)
 			if cfg.AuthToken != "" {
@@ -177,12 +298,12 @@ func (p *program) run() {
 				continue
 			}
 			// 토큰이 설정되지 않은 경우 기존 방식대로 문자열 그대로 실행 (하위 호환성)
-			go executeCommand(conn, string(message))
+			scheduler.Submit(conn, CommandRequest{Command: string(message)})
 		}
 	}
 }
 
-func sendRegister(conn *websocket.Conn) {
+func sendRegister(conn *agentConn, cfg *config.Config) {
 	hostname, _ := os.Hostname()
 
 	// MAC 주소 가져오기
@@ -202,6 +323,8 @@ func sendRegister(conn *websocket.Conn) {
 		OS:       runtime.GOOS,
 		Arch:     runtime.GOARCH,
 		MacAddr:  macAddr,
+		Group:    cfg.Group,
+		Labels:   cfg.Labels,
 	}
 
 	msg := Message{
@@ -212,16 +335,9 @@ func sendRegister(conn *websocket.Conn) {
 	conn.WriteJSON(msg)
 }
 
-func sendStatus(conn *websocket.Conn) {
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-
-	status := AgentStatus{
-		MemoryUsage: float64(m.Alloc) / 1024 / 1024, // MB
-		CPUUsage:    0.0,
-		DiskUsage:   0.0,
-		Uptime:      uint64(time.Since(startTime).Seconds()),
-	}
+func sendStatus(conn *agentConn) {
+	status := collectStatus()
+	status.LatencyMs = conn.Latency()
 
 	msg := Message{
 		Type:   "status",
@@ -230,71 +346,3 @@ func sendStatus(conn *websocket.Conn) {
 
 	conn.WriteJSON(msg)
 }
-
-func executeCommand(conn *websocket.Conn, command string) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in executeCommand: %v", r)
-		}
-	}()
-
-	// GUI 명령 확인 (gui: 접두사)
-	if len(command) > 4 && command[:4] == "gui:" {
-		guiCmd := command[4:]
-		log.Printf("Executing GUI command: %s", guiCmd)
-		err := runAsUser(guiCmd)
-
-		resultMsg := ""
-		errorMsg := ""
-		if err != nil {
-			errorMsg = err.Error()
-			log.Printf("GUI execution error: %v", err)
-		} else {
-			resultMsg = "GUI command launched successfully"
-		}
-
-		result := map[string]interface{}{
-			"command":   command,
-			"output":    resultMsg,
-			"error":     errorMsg,
-			"timestamp": time.Now(),
-			"exit_code": 0,
-		}
-
-		msg := Message{
-			Type:   "command_result",
-			Result: result,
-		}
-		conn.WriteJSON(msg)
-		return
-	}
-
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", command)
-	} else {
-		cmd = exec.Command("sh", "-c", command)
-	}
-
-	output, err := cmd.CombinedOutput()
-	resultOutput := string(output)
-	errorMsg := ""
-	if err != nil {
-		errorMsg = err.Error()
-	}
-
-	result := map[string]interface{}{
-		"command":   command,
-		"output":    resultOutput,
-		"error":     errorMsg,
-		"timestamp": time.Now(),
-		"exit_code": 0,
-	}
-
-	msg := Message{
-		Type:   "command_result",
-		Result: result,
-	}
-
-	conn.WriteJSON(msg)
-}
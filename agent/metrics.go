@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// 첫 수집 때 CPU 사용률을 재는 구간 (이후에는 이전 수집부터의 구간)
+const cpuSampleInterval = 500 * time.Millisecond
+
+// hostCollector 호스트 상태(CPU, 메모리, 디스크, 가동 시간), 네트워크 카운터와 프로세스 목록을 읽는 플랫폼별 구현
+type hostCollector interface {
+	// Collect 호스트 상태를 status에 채운다. CPU 사용률은 이전 Collect 이후 구간의 값이다.
+	// 일부 값을 읽지 못해도 읽은 값은 채우고 첫 오류를 반환한다.
+	Collect(status *AgentStatus) error
+	// NetCounters 인터페이스 이름별 누적 송수신 카운터
+	NetCounters() (map[string]netCounters, error)
+	// Processes 실행 중인 프로세스 (CPU 사용률 대신 누적 CPU 시간을 채운다)
+	Processes() ([]ProcessInfo, error)
+}
+
+// metricsCollector 현재 플랫폼 구현 (테스트에서는 fakeCollector로 바꿀 수 있음)
+var metricsCollector hostCollector = newHostCollector()
+
+// DiskUsage 마운트 지점(드라이브) 하나의 사용량
+type DiskUsage struct {
+	Mount   string  `json:"mount"`
+	FSType  string  `json:"fstype,omitempty"`
+	Total   uint64  `json:"total"` // bytes
+	Used    uint64  `json:"used"`  // bytes
+	Percent float64 `json:"percent"`
+}
+
+// cpuTimes 부팅 이후 누적 CPU 시간 (단위는 플랫폼마다 다름)
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+// usageSince prev 이후 구간의 CPU 사용률 (%)
+func (c cpuTimes) usageSince(prev cpuTimes) float64 {
+	if c.total <= prev.total || c.idle < prev.idle {
+		return 0
+	}
+	total := float64(c.total - prev.total)
+	idle := float64(c.idle - prev.idle)
+	return clampPercent((total - idle) / total * 100)
+}
+
+// cpuSampler 이전 수집 값을 기억해 구간 CPU 사용률을 계산한다.
+type cpuSampler struct {
+	mu   sync.Mutex
+	prev cpuTimes
+	read func() (cpuTimes, error)
+}
+
+// Usage 이전 호출 이후의 CPU 사용률. 처음에는 cpuSampleInterval 동안 잰다.
+func (s *cpuSampler) Usage() (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.prev.total == 0 {
+		first, err := s.read()
+		if err != nil {
+			return 0, err
+		}
+		s.prev = first
+		time.Sleep(cpuSampleInterval)
+	}
+	cur, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	usage := cur.usageSince(s.prev)
+	s.prev = cur
+	return usage, nil
+}
+
+// percentOf used / total (%)
+func percentOf(used, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return clampPercent(float64(used) / float64(total) * 100)
+}
+
+func clampPercent(v float64) float64 {
+	return min(max(v, 0), 100)
+}
+
+// 같은 수집 오류를 상태 주기마다 반복해서 기록하지 않도록 마지막 오류를 기억한다
+var lastMetricsError string
+
+// collectStatus 호스트 상태, 네트워크 인터페이스와 에이전트 자신의 실행 시간, 메모리를 모은다.
+func collectStatus() AgentStatus {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	status := AgentStatus{
+		AgentUptime: uint64(time.Since(startTime).Seconds()),
+		AgentMemory: float64(m.Alloc) / 1024 / 1024, // MB
+	}
+	err := metricsCollector.Collect(&status)
+	network, netErr := collectNetwork(metricsCollector)
+	status.Network = network
+	if err == nil {
+		err = netErr
+	}
+	switch {
+	case err != nil && err.Error() != lastMetricsError:
+		log.Printf("호스트 상태 수집 실패: %v", err)
+		lastMetricsError = err.Error()
+	case err == nil:
+		lastMetricsError = ""
+	}
+	return status
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// /proc/[pid]/stat 시간 단위 (USER_HZ, 리눅스에서 사실상 고정)
+const clockTicksPerSec = 100
+
+// linuxCollector /proc 과 statfs로 호스트 상태를 읽는다.
+type linuxCollector struct {
+	proc   string // /proc 경로 (fixture 디렉토리로 바꿀 수 있음)
+	cpu    *cpuSampler
+	statfs func(path string) (total, used, avail uint64, err error)
+	users  sync.Map // UID -> 사용자 이름
+}
+
+func newHostCollector() hostCollector {
+	c := &linuxCollector{proc: "/proc", statfs: statfsUsage}
+	c.cpu = &cpuSampler{read: func() (cpuTimes, error) {
+		var t cpuTimes
+		err := c.read("stat", func(r io.Reader) (err error) {
+			t, err = parseProcStat(r)
+			return err
+		})
+		return t, err
+	}}
+	return c
+}
+
+// read proc 아래 파일을 열어 parse에 넘긴다.
+func (c *linuxCollector) read(name string, parse func(r io.Reader) error) error {
+	f, err := os.Open(filepath.Join(c.proc, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+func (c *linuxCollector) Collect(status *AgentStatus) error {
+	var first error
+	keep := func(err error) {
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	usage, err := c.cpu.Usage()
+	keep(err)
+	status.CPUUsage = usage
+
+	keep(c.read("meminfo", func(r io.Reader) error {
+		total, available, err := parseMeminfo(r)
+		if err != nil {
+			return err
+		}
+		status.MemoryTotal = total
+		status.MemoryUsed = total - available
+		status.MemoryUsage = percentOf(total-available, total)
+		return nil
+	}))
+	keep(c.read("uptime", func(r io.Reader) (err error) {
+		status.Uptime, err = parseUptime(r)
+		return err
+	}))
+	keep(c.read("loadavg", func(r io.Reader) (err error) {
+		status.Load, err = parseLoadavg(r)
+		return err
+	}))
+	keep(c.read("mounts", func(r io.Reader) error {
+		mounts, err := parseMounts(r)
+		if err != nil {
+			return err
+		}
+		for _, m := range mounts {
+			total, used, avail, err := c.statfs(m.Path)
+			if err != nil || total == 0 {
+				// 권한이 없거나 분리된 장치는 건너뛴다
+				continue
+			}
+			disk := DiskUsage{Mount: m.Path, FSType: m.FSType, Total: total, Used: used, Percent: percentOf(used, used+avail)}
+			status.Disks = append(status.Disks, disk)
+			if m.Path == "/" {
+				status.DiskUsage = disk.Percent
+			}
+		}
+		if status.DiskUsage == 0 && len(status.Disks) > 0 {
+			status.DiskUsage = status.Disks[0].Percent
+		}
+		return nil
+	}))
+	return first
+}
+
+func (c *linuxCollector) NetCounters() (map[string]netCounters, error) {
+	var counters map[string]netCounters
+	err := c.read("net/dev", func(r io.Reader) (err error) {
+		counters, err = parseNetDev(r)
+		return err
+	})
+	return counters, err
+}
+
+func (c *linuxCollector) Processes() ([]ProcessInfo, error) {
+	var memTotal uint64
+	c.read("meminfo", func(r io.Reader) (err error) {
+		memTotal, _, err = parseMeminfo(r)
+		return err
+	})
+	entries, err := os.ReadDir(c.proc)
+	if err != nil {
+		return nil, err
+	}
+	pageSize := uint64(os.Getpagesize())
+
+	var procs []ProcessInfo
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		dir := entry.Name()
+		var stat procPidStat
+		if err := c.read(filepath.Join(dir, "stat"), func(r io.Reader) (err error) {
+			stat, err = parseProcPidStat(r)
+			return err
+		}); err != nil {
+			// 목록을 읽는 사이에 끝난 프로세스
+			continue
+		}
+		p := ProcessInfo{
+			PID:     pid,
+			PPID:    stat.PPID,
+			Name:    stat.Name,
+			Memory:  stat.RSS * pageSize,
+			cpuTime: time.Duration(stat.CPU) * time.Second / clockTicksPerSec,
+			started: stat.Started,
+		}
+		p.MemoryPercent = percentOf(p.Memory, memTotal)
+		c.read(filepath.Join(dir, "status"), func(r io.Reader) error {
+			uid, err := parseProcPidUID(r)
+			if err == nil {
+				p.User = c.userName(uid)
+			}
+			return err
+		})
+		c.read(filepath.Join(dir, "cmdline"), func(r io.Reader) (err error) {
+			p.Cmdline, err = parseProcPidCmdline(r)
+			return err
+		})
+		p.exeName = c.exeName(dir, p.Cmdline)
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// exeName 실행 파일 이름. /proc/[pid]/exe를 읽을 수 없으면 (다른 사용자의 프로세스) 명령줄 첫 인자를 쓴다.
+func (c *linuxCollector) exeName(dir, cmdline string) string {
+	if exe, err := os.Readlink(filepath.Join(c.proc, dir, "exe")); err == nil {
+		return filepath.Base(strings.TrimSuffix(exe, " (deleted)"))
+	}
+	if fields := strings.Fields(cmdline); len(fields) > 0 {
+		return filepath.Base(fields[0])
+	}
+	return ""
+}
+
+// processStartTime 프로세스 시작 시각 (부팅 후 클록 틱)
+func processStartTime(pid int) (uint64, error) {
+	f, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	stat, err := parseProcPidStat(f)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Started, nil
+}
+
+// userName UID의 사용자 이름 (찾지 못하면 UID 그대로)
+func (c *linuxCollector) userName(uid string) string {
+	if name, ok := c.users.Load(uid); ok {
+		return name.(string)
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	c.users.Store(uid, name)
+	return name
+}
+
+// statfsUsage 파일 시스템 전체 크기, 사용량, 일반 사용자가 쓸 수 있는 여유 공간 (bytes)
+// 사용률은 df와 같이 used / (used + avail)로 계산한다 (root 예약 공간 제외).
+func statfsUsage(path string) (total, used, avail uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, 0, err
+	}
+	bsize := uint64(st.Bsize)
+	return st.Blocks * bsize, (st.Blocks - st.Bfree) * bsize, st.Bavail * bsize, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProcFixture(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLinuxCollectorFixture(t *testing.T) {
+	dir := t.TempDir()
+	writeProcFixture(t, dir, map[string]string{
+		"stat":       procStatFixture2,
+		"meminfo":    "MemTotal: 1000 kB\nMemAvailable: 250 kB\n",
+		"uptime":     "3600.5 7000.1\n",
+		"loadavg":    "1.50 1.00 0.50 2/300 4000\n",
+		"mounts":     "/dev/sda2 / ext4 rw 0 0\n/dev/sda1 /boot/efi vfat rw 0 0\n",
+		"net/dev":    "  eth0: 100 2 0 0 0 0 0 0 200 3 0 0 0 0 0 0\n",
+		"42/stat":    "42 (worker) S 1 42 42 0 -1 0 0 0 0 0 150 50 0 0 20 0 1 0 777 0 10\n",
+		"42/status":  "Name:\tworker\nUid:\t0\t0\t0\t0\n",
+		"42/cmdline": "worker\x00--serve\x00",
+	})
+	writeProcFixture(t, dir, map[string]string{
+		"43/stat":    "43 (chromium-browse) S 1 43 43 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 900 0 10\n",
+		"43/status":  "Uid:\t0\t0\t0\t0\n",
+		"43/cmdline": "/usr/lib/chromium/chromium-browser\x00--type=renderer\x00",
+	})
+	if err := os.Symlink("/opt/tools/long-running-worker", filepath.Join(dir, "42", "exe")); err != nil {
+		t.Fatal(err)
+	}
+	c := newHostCollector().(*linuxCollector)
+	c.proc = dir
+	c.statfs = func(path string) (total, used, avail uint64, err error) {
+		return 100, 30, 70, nil
+	}
+	// 이전 샘플을 넣어 두면 기다리지 않고 두 샘플 사이의 사용률을 계산한다
+	c.cpu.prev, _ = parseProcStat(strings.NewReader(procStatFixture1))
+
+	var status AgentStatus
+	if err := c.Collect(&status); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(status.CPUUsage-40) > 1e-9 {
+		t.Errorf("cpu = %v, want 40", status.CPUUsage)
+	}
+	if status.MemoryTotal != 1000*1024 || status.MemoryUsed != 750*1024 || status.MemoryUsage != 75 {
+		t.Errorf("memory = %d/%d (%v%%)", status.MemoryUsed, status.MemoryTotal, status.MemoryUsage)
+	}
+	if status.Uptime != 3600 || len(status.Load) != 3 || status.Load[0] != 1.5 {
+		t.Errorf("uptime = %d, load = %v", status.Uptime, status.Load)
+	}
+	if len(status.Disks) != 2 || status.DiskUsage != 30 {
+		t.Errorf("disks = %+v, disk usage = %v", status.Disks, status.DiskUsage)
+	}
+
+	counters, err := c.NetCounters()
+	if err != nil || counters["eth0"].RxBytes != 100 || counters["eth0"].TxPackets != 3 {
+		t.Errorf("counters = %+v, %v", counters, err)
+	}
+
+	procs, err := c.Processes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 2 {
+		t.Fatalf("got %d processes", len(procs))
+	}
+	p := procs[0]
+	if p.exeName != "long-running-worker" {
+		t.Errorf("exe name = %q (from exe link)", p.exeName)
+	}
+	if procs[1].exeName != "chromium-browser" || !procs[1].nameMatches("chromium-browser") {
+		t.Errorf("exe name = %q (from argv[0])", procs[1].exeName)
+	}
+	if p.PID != 42 || p.PPID != 1 || p.Name != "worker" || p.User != "root" || p.Cmdline != "worker --serve" {
+		t.Errorf("process = %+v", p)
+	}
+	if p.cpuTime.Seconds() != 2 || p.started != 777 {
+		t.Errorf("cpu time = %v, started = %d", p.cpuTime, p.started)
+	}
+}
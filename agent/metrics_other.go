@@ -0,0 +1,29 @@
+//go:build !linux && !windows
+
+package main
+
+import "fmt"
+
+// unsupportedCollector 호스트 상태 수집을 지원하지 않는 플랫폼
+type unsupportedCollector struct{}
+
+func newHostCollector() hostCollector {
+	return unsupportedCollector{}
+}
+
+func (unsupportedCollector) Collect(status *AgentStatus) error {
+	return fmt.Errorf("host metrics are not supported on this platform")
+}
+
+func (unsupportedCollector) NetCounters() (map[string]netCounters, error) {
+	return nil, fmt.Errorf("network counters are not supported on this platform")
+}
+
+func (unsupportedCollector) Processes() ([]ProcessInfo, error) {
+	return nil, fmt.Errorf("process list is not supported on this platform")
+}
+
+// processStartTime 이 플랫폼에서는 시작 시각을 읽지 않는다 (분리 실행 작업은 PID만으로 추적).
+func processStartTime(pid int) (uint64, error) {
+	return 0, fmt.Errorf("process start time is not supported on this platform")
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+// fakeCollector 고정된 상태를 채우는 테스트용 구현
+type fakeCollector struct {
+	Status   AgentStatus
+	Counters map[string]netCounters
+	Procs    []ProcessInfo
+	Err      error
+}
+
+func (f *fakeCollector) Collect(status *AgentStatus) error {
+	agentUptime, agentMemory := status.AgentUptime, status.AgentMemory
+	*status = f.Status
+	status.AgentUptime, status.AgentMemory = agentUptime, agentMemory
+	return f.Err
+}
+
+func (f *fakeCollector) NetCounters() (map[string]netCounters, error) {
+	return f.Counters, f.Err
+}
+
+func (f *fakeCollector) Processes() ([]ProcessInfo, error) {
+	return append([]ProcessInfo(nil), f.Procs...), f.Err
+}
+
+func TestCollectStatusUsesCollector(t *testing.T) {
+	fake := &fakeCollector{Status: AgentStatus{CPUUsage: 12.5, MemoryUsage: 40, Uptime: 3600, AgentUptime: 999}}
+	saved := metricsCollector
+	metricsCollector = fake
+	t.Cleanup(func() { metricsCollector = saved })
+
+	status := collectStatus()
+	if status.CPUUsage != 12.5 || status.MemoryUsage != 40 || status.Uptime != 3600 {
+		t.Errorf("status = %+v", status)
+	}
+	// 에이전트 자신의 값은 수집기가 덮어쓰지 않는다
+	if status.AgentUptime == 999 || status.AgentMemory <= 0 {
+		t.Errorf("agent uptime = %d, memory = %v", status.AgentUptime, status.AgentMemory)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procGlobalMemoryStatusEx = modkernel32.NewProc("GlobalMemoryStatusEx")
+	procGetSystemTimes       = modkernel32.NewProc("GetSystemTimes")
+	procGetTickCount64       = modkernel32.NewProc("GetTickCount64")
+	procGetProcessMemoryInfo = modkernel32.NewProc("K32GetProcessMemoryInfo")
+)
+
+// processMemoryCounters PROCESS_MEMORY_COUNTERS
+type processMemoryCounters struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// memoryStatusEx MEMORYSTATUSEX
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+// windowsCollector kernel32 API로 호스트 상태를 읽는다.
+type windowsCollector struct {
+	cpu   *cpuSampler
+	users sync.Map // SID 문자열 -> DOMAIN\사용자
+}
+
+func newHostCollector() hostCollector {
+	return &windowsCollector{cpu: &cpuSampler{read: systemTimes}}
+}
+
+func (c *windowsCollector) Collect(status *AgentStatus) error {
+	var first error
+	keep := func(err error) {
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	usage, err := c.cpu.Usage()
+	keep(err)
+	status.CPUUsage = usage
+
+	mem := memoryStatusEx{Length: uint32(unsafe.Sizeof(memoryStatusEx{}))}
+	if r, _, err := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&mem))); r == 0 {
+		keep(fmt.Errorf("GlobalMemoryStatusEx: %v", err))
+	} else {
+		status.MemoryTotal = mem.TotalPhys
+		status.MemoryUsed = mem.TotalPhys - mem.AvailPhys
+		status.MemoryUsage = percentOf(status.MemoryUsed, mem.TotalPhys)
+	}
+
+	ms, _, _ := procGetTickCount64.Call()
+	status.Uptime = uint64(ms) / 1000
+
+	disks, err := fixedDrives()
+	keep(err)
+	systemDrive := strings.ToUpper(os.Getenv("SystemDrive")) + `\`
+	for _, disk := range disks {
+		status.Disks = append(status.Disks, disk)
+		if strings.EqualFold(disk.Mount, systemDrive) {
+			status.DiskUsage = disk.Percent
+		}
+	}
+	if status.DiskUsage == 0 && len(status.Disks) > 0 {
+		status.DiskUsage = status.Disks[0].Percent
+	}
+	return first
+}
+
+// NetCounters GetIfEntry2Ex로 인터페이스 카운터를 읽는다 (이름은 net.Interfaces와 같은 별칭).
+func (c *windowsCollector) NetCounters() (map[string]netCounters, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	counters := map[string]netCounters{}
+	for _, iface := range ifaces {
+		row := windows.MibIfRow2{InterfaceIndex: uint32(iface.Index)}
+		if err := windows.GetIfEntry2Ex(windows.MibIfEntryNormal, &row); err != nil {
+			continue
+		}
+		counters[iface.Name] = netCounters{
+			RxBytes:   row.InOctets,
+			RxPackets: row.InUcastPkts + row.InNUcastPkts,
+			RxErrors:  row.InErrors,
+			RxDropped: row.InDiscards,
+			TxBytes:   row.OutOctets,
+			TxPackets: row.OutUcastPkts + row.OutNUcastPkts,
+			TxErrors:  row.OutErrors,
+			TxDropped: row.OutDiscards,
+		}
+	}
+	return counters, nil
+}
+
+// systemTimes GetSystemTimes 누적 시간 (100ns 단위, kernel 시간에 idle 포함)
+func systemTimes() (cpuTimes, error) {
+	var idle, kernel, user windows.Filetime
+	r, _, err := procGetSystemTimes.Call(
+		uintptr(unsafe.Pointer(&idle)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)),
+	)
+	if r == 0 {
+		return cpuTimes{}, fmt.Errorf("GetSystemTimes: %v", err)
+	}
+	ticks := func(ft windows.Filetime) uint64 {
+		return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+	}
+	return cpuTimes{idle: ticks(idle), total: ticks(kernel) + ticks(user)}, nil
+}
+
+// fixedDrives 로컬 고정 드라이브(C:\ 등)의 사용량
+func fixedDrives() ([]DiskUsage, error) {
+	buf := make([]uint16, 256)
+	n, err := windows.GetLogicalDriveStrings(uint32(len(buf)), &buf[0])
+	if err != nil {
+		return nil, fmt.Errorf("GetLogicalDriveStrings: %v", err)
+	}
+	var disks []DiskUsage
+	for _, root := range strings.Split(windows.UTF16ToString(buf[:n]), "\x00") {
+		if root == "" {
+			continue
+		}
+		p, err := windows.UTF16PtrFromString(root)
+		if err != nil || windows.GetDriveType(p) != windows.DRIVE_FIXED {
+			continue
+		}
+		var avail, total, free uint64
+		if err := windows.GetDiskFreeSpaceEx(p, &avail, &total, &free); err != nil || total == 0 {
+			continue
+		}
+		disks = append(disks, DiskUsage{
+			Mount:   root,
+			Total:   total,
+			Used:    total - free,
+			Percent: percentOf(total-free, total),
+		})
+	}
+	return disks, nil
+}
+
+// Processes 스냅샷의 프로세스마다 시간, 작업 집합, 소유자와 명령줄을 읽는다.
+// 권한이 없어 열 수 없는 프로세스(시스템 서비스 등)는 PID, 부모, 이름만 채운다.
+func (c *windowsCollector) Processes() ([]ProcessInfo, error) {
+	mem := memoryStatusEx{Length: uint32(unsafe.Sizeof(memoryStatusEx{}))}
+	procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&mem)))
+
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("CreateToolhelp32Snapshot: %v", err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var procs []ProcessInfo
+	entry := windows.ProcessEntry32{Size: uint32(unsafe.Sizeof(windows.ProcessEntry32{}))}
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		p := ProcessInfo{
+			PID:  int(entry.ProcessID),
+			PPID: int(entry.ParentProcessID),
+			Name: windows.UTF16ToString(entry.ExeFile[:]),
+		}
+		c.fillProcess(&p)
+		p.MemoryPercent = percentOf(p.Memory, mem.TotalPhys)
+		procs = append(procs, p)
+	}
+	if err != windows.ERROR_NO_MORE_FILES {
+		return nil, fmt.Errorf("Process32Next: %v", err)
+	}
+	return procs, nil
+}
+
+// processStartTime 프로세스 생성 시각 (FILETIME, 100ns 단위)
+func processStartTime(pid int) (uint64, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(h)
+
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0, err
+	}
+	return uint64(creation.HighDateTime)<<32 | uint64(creation.LowDateTime), nil
+}
+
+func (c *windowsCollector) fillProcess(p *ProcessInfo) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(p.PID))
+	if err != nil {
+		return
+	}
+	defer windows.CloseHandle(h)
+
+	var creation, exit, kernel, user windows.Filetime
+	if windows.GetProcessTimes(h, &creation, &exit, &kernel, &user) == nil {
+		ticks := func(ft windows.Filetime) uint64 {
+			return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+		}
+		p.cpuTime = time.Duration(ticks(kernel)+ticks(user)) * 100 // 100ns 단위
+		p.started = ticks(creation)
+	}
+
+	counters := processMemoryCounters{Cb: uint32(unsafe.Sizeof(processMemoryCounters{}))}
+	if r, _, _ := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&counters)), uintptr(counters.Cb)); r != 0 {
+		p.Memory = uint64(counters.WorkingSetSize)
+	}
+
+	var token windows.Token
+	if windows.OpenProcessToken(h, windows.TOKEN_QUERY, &token) == nil {
+		if tu, err := token.GetTokenUser(); err == nil {
+			p.User = c.accountName(tu.User.Sid)
+		}
+		token.Close()
+	}
+	p.Cmdline = processCommandLine(h)
+}
+
+// accountName SID의 DOMAIN\사용자 이름 (찾지 못하면 SID 문자열)
+func (c *windowsCollector) accountName(sid *windows.SID) string {
+	key := sid.String()
+	if name, ok := c.users.Load(key); ok {
+		return name.(string)
+	}
+	name := key
+	if account, domain, _, err := sid.LookupAccount(""); err == nil {
+		name = account
+		if domain != "" {
+			name = domain + `\` + account
+		}
+	}
+	c.users.Store(key, name)
+	return name
+}
+
+// processCommandLine NtQueryInformationProcess(ProcessCommandLineInformation)로 명령줄을 읽는다 (Windows 8.1 이상).
+func processCommandLine(h windows.Handle) string {
+	buf := make([]byte, 1024)
+	for {
+		var n uint32
+		err := windows.NtQueryInformationProcess(h, windows.ProcessCommandLineInformation, unsafe.Pointer(&buf[0]), uint32(len(buf)), &n)
+		if err == windows.STATUS_INFO_LENGTH_MISMATCH && int(n) > len(buf) {
+			buf = make([]byte, n)
+			continue
+		}
+		if err != nil {
+			return ""
+		}
+		return (*windows.NTUnicodeString)(unsafe.Pointer(&buf[0])).String()
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// netCounters 인터페이스의 누적 송수신 카운터
+type netCounters struct {
+	RxBytes   uint64
+	RxPackets uint64
+	RxErrors  uint64
+	RxDropped uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxErrors  uint64
+	TxDropped uint64
+}
+
+// NetInterface 네트워크 인터페이스 하나의 상태. 속도는 이전 보고 이후 구간의 초당 값이다.
+type NetInterface struct {
+	Name     string   `json:"name"`
+	MAC      string   `json:"mac,omitempty"`
+	IPs      []string `json:"ips,omitempty"` // CIDR 표기
+	Up       bool     `json:"up"`            // 관리상 켜져 있고 링크가 연결됨
+	Loopback bool     `json:"loopback,omitempty"`
+	MTU      int      `json:"mtu,omitempty"`
+
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
+	RxErrors        uint64  `json:"rx_errors"` // 부팅 이후 누적
+	TxErrors        uint64  `json:"tx_errors"`
+	RxDropped       uint64  `json:"rx_dropped"`
+	TxDropped       uint64  `json:"tx_dropped"`
+}
+
+// netSampler 이전 카운터를 기억해 인터페이스별 초당 송수신량을 계산한다.
+type netSampler struct {
+	mu   sync.Mutex
+	prev map[string]netCounters
+	at   time.Time
+}
+
+var netRates = &netSampler{}
+
+// rate 누적 카운터의 초당 증가량 (카운터가 초기화되거나 넘치면 0)
+func rate(cur, prev uint64, seconds float64) float64 {
+	if cur < prev || seconds <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+// collectNetwork 인터페이스 목록과 주소에 플랫폼 수집기의 카운터를 합친다.
+func collectNetwork(collector hostCollector) ([]NetInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	counters, counterErr := collector.NetCounters()
+
+	netRates.mu.Lock()
+	defer netRates.mu.Unlock()
+
+	now := time.Now()
+	seconds := now.Sub(netRates.at).Seconds()
+	list := make([]NetInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		ni := NetInterface{
+			Name:     iface.Name,
+			MAC:      iface.HardwareAddr.String(),
+			Up:       iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagRunning != 0,
+			Loopback: iface.Flags&net.FlagLoopback != 0,
+			MTU:      iface.MTU,
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				ni.IPs = append(ni.IPs, addr.String())
+			}
+		}
+		if cur, ok := counters[iface.Name]; ok {
+			ni.RxErrors, ni.TxErrors = cur.RxErrors, cur.TxErrors
+			ni.RxDropped, ni.TxDropped = cur.RxDropped, cur.TxDropped
+			if prev, ok := netRates.prev[iface.Name]; ok {
+				ni.RxBytesPerSec = rate(cur.RxBytes, prev.RxBytes, seconds)
+				ni.TxBytesPerSec = rate(cur.TxBytes, prev.TxBytes, seconds)
+				ni.RxPacketsPerSec = rate(cur.RxPackets, prev.RxPackets, seconds)
+				ni.TxPacketsPerSec = rate(cur.TxPackets, prev.TxPackets, seconds)
+			}
+		}
+		list = append(list, ni)
+	}
+	if counters != nil {
+		netRates.prev = counters
+		netRates.at = now
+	}
+	sort.SliceStable(list, func(i, j int) bool { return !list[i].Loopback && list[j].Loopback })
+	return list, counterErr
+}
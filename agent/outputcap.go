@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 출력 파일 전송 시 한 메시지에 담는 최대 크기
+const fetchChunkSize = 256 * 1024
+
+var outputHandlePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// outputFiles 결과에 담기 너무 큰 출력을 파일로 보관한다.
+type outputFiles struct {
+	dir       string
+	limit     int64 // 스트림별로 결과/청크에 담는 최대 바이트 (0 = 제한 없음)
+	retention time.Duration
+}
+
+func newOutputFiles(dir string, limit int64, retention time.Duration) *outputFiles {
+	return &outputFiles{dir: dir, limit: limit, retention: retention}
+}
+
+// path 명령 출력 파일 경로 (handle은 명령 ID)
+func (o *outputFiles) path(handle, stream string) (string, error) {
+	if !outputHandlePattern.MatchString(handle) {
+		return "", fmt.Errorf("invalid output handle")
+	}
+	if stream != "stdout" && stream != "stderr" {
+		return "", fmt.Errorf("invalid stream %q", stream)
+	}
+	return filepath.Join(o.dir, handle+"."+stream), nil
+}
+
+// Writer 처음 limit 바이트만 dst로 보내고 나머지는 파일에 저장하는 Writer
+func (o *outputFiles) Writer(dst io.Writer, handle, stream string) *cappedOutput {
+	c := &cappedOutput{dst: dst}
+	if o == nil || o.limit <= 0 {
+		return c
+	}
+	c.limit = o.limit
+	c.path, c.err = o.path(handle, stream)
+	return c
+}
+
+// runCleanup 보관 기간이 지난 출력 파일을 주기적으로 지운다.
+func (o *outputFiles) runCleanup() {
+	if o.retention <= 0 {
+		return
+	}
+	for {
+		entries, _ := os.ReadDir(o.dir)
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || e.IsDir() {
+				continue
+			}
+			if time.Since(info.ModTime()) > o.retention {
+				os.Remove(filepath.Join(o.dir, e.Name()))
+			}
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// Adopt 파일에 저장된 출력을 결과에 담을 만큼만 읽는다.
+// 한도를 넘으면 파일을 보관소로 옮겨 전체 출력을 내려받을 수 있게 한다.
+func (o *outputFiles) Adopt(path, handle, stream string) (inline string, total int64, truncated bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", 0, false, nil
+		}
+		return "", 0, false, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return "", 0, false, err
+	}
+	total = fi.Size()
+
+	var r io.Reader = f
+	if o != nil && o.limit > 0 {
+		r = io.LimitReader(f, o.limit)
+	}
+	data, err := io.ReadAll(r)
+	f.Close()
+	if err != nil {
+		return "", total, false, err
+	}
+	if o == nil || int64(len(data)) >= total {
+		return string(data), total, false, nil
+	}
+
+	dest, err := o.path(handle, stream)
+	if err == nil {
+		err = os.MkdirAll(o.dir, 0700)
+	}
+	if err == nil {
+		err = os.Rename(path, dest)
+	}
+	return string(data), total, true, err
+}
+
+// cappedOutput 출력이 limit를 넘으면 전체 출력을 파일로 옮기고 dst에는 앞부분만 보낸다.
+type cappedOutput struct {
+	mu    sync.Mutex
+	dst   io.Writer
+	limit int64 // 0 = 제한 없음
+	path  string
+	head  bytes.Buffer // dst로 보낸 앞부분 (파일로 옮길 때 사용)
+	file  *os.File
+	total int64
+	err   error // 파일 저장 오류 (명령 실행은 계속됨)
+}
+
+func (c *cappedOutput) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total += int64(len(p))
+	if c.limit <= 0 {
+		return c.dst.Write(p)
+	}
+
+	// 남은 한도만큼 dst로 보냄
+	if room := c.limit - int64(c.head.Len()); room > 0 {
+		part := p
+		if int64(len(part)) > room {
+			part = part[:room]
+		}
+		c.head.Write(part)
+		c.dst.Write(part)
+	}
+	if c.total <= c.limit || c.err != nil {
+		return len(p), nil
+	}
+
+	if c.file == nil {
+		if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+			c.err = err
+			return len(p), nil
+		}
+		f, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			c.err = err
+			return len(p), nil
+		}
+		c.file = f
+		// 앞부분부터 다시 써서 파일에 전체 출력이 남도록 한다
+		written := c.total - int64(len(p))
+		if _, err := c.file.Write(c.head.Bytes()[:written]); err != nil {
+			c.err = err
+			return len(p), nil
+		}
+	}
+	if _, err := c.file.Write(p); err != nil {
+		c.err = err
+	}
+	return len(p), nil
+}
+
+// Close 파일을 닫고 잘림 여부와 전체 출력 크기를 반환한다.
+func (c *cappedOutput) Close() (truncated bool, total int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file != nil {
+		if cerr := c.file.Close(); cerr != nil && c.err == nil {
+			c.err = cerr
+		}
+	}
+	return c.limit > 0 && c.total > c.limit, c.total, c.err
+}
+
+// FetchRequest 서버가 요청한 출력 파일 전송
+type FetchRequest struct {
+	RequestID string `json:"request_id"`
+	Handle    string `json:"handle"`
+	Stream    string `json:"stream"`
+}
+
+// FetchChunk 출력 파일 전송 조각
+type FetchChunk struct {
+	RequestID string `json:"request_id"`
+	Seq       int    `json:"seq"`
+	Data      string `json:"data,omitempty"` // base64
+	Size      int64  `json:"size,omitempty"` // 전체 파일 크기 (첫 조각에만)
+	EOF       bool   `json:"eof,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// sendOutputFile 보관된 출력 파일을 조각으로 나누어 서버에 보낸다.
+func sendOutputFile(conn *agentConn, req FetchRequest) {
+	send := func(chunk FetchChunk) {
+		chunk.RequestID = req.RequestID
+		conn.WriteJSON(Message{Type: "output_fetch", Fetch: &chunk})
+	}
+
+	path, err := outputStore.path(req.Handle, req.Stream)
+	if err != nil {
+		send(FetchChunk{EOF: true, Error: err.Error()})
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("output not found (expired or not truncated)")
+		}
+		send(FetchChunk{EOF: true, Error: err.Error()})
+		return
+	}
+	defer f.Close()
+
+	var size int64
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+	log.Printf("Sending output file %s (%d bytes)", filepath.Base(path), size)
+
+	buf := make([]byte, fetchChunkSize)
+	for seq := 0; ; seq++ {
+		n, err := io.ReadFull(f, buf)
+		chunk := FetchChunk{Seq: seq, Data: base64.StdEncoding.EncodeToString(buf[:n])}
+		if seq == 0 {
+			chunk.Size = size
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			chunk.EOF = true
+			send(chunk)
+			return
+		}
+		if err != nil {
+			chunk.EOF = true
+			chunk.Error = err.Error()
+			send(chunk)
+			return
+		}
+		send(chunk)
+	}
+}
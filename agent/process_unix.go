@@ -0,0 +1,55 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 자식 프로세스를 새 프로세스 그룹에서 시작한다.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessTree 프로세스 그룹 전체에 SIGKILL을 보낸다.
+func killProcessTree(p *os.Process) error {
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
+
+// setDetached 에이전트가 종료되어도 살아남도록 새 세션에서 시작한다.
+func setDetached(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive PID의 프로세스가 실행 중인지 확인한다.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// 종료 요청 신호 이름
+var unixSignals = map[string]syscall.Signal{
+	"term": syscall.SIGTERM,
+	"kill": syscall.SIGKILL,
+	"int":  syscall.SIGINT,
+	"hup":  syscall.SIGHUP,
+}
+
+// signalProcess PID에 신호를 보낸다.
+func signalProcess(pid int, signal string) error {
+	sig, ok := unixSignals[signal]
+	if !ok {
+		return fmt.Errorf("unknown signal %q", signal)
+	}
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// DETACHED_PROCESS 콘솔을 상속하지 않는 프로세스 생성 플래그
+const detachedProcess = 0x00000008
+
+// setProcessGroup 자식 프로세스를 새 프로세스 그룹에서 시작한다.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killProcessTree taskkill /T 로 자식 프로세스까지 모두 종료한다.
+func killProcessTree(p *os.Process) error {
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid))
+	if err := kill.Run(); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
+
+// setDetached 에이전트(서비스)가 종료되어도 살아남도록 콘솔과 프로세스 그룹을 분리한다.
+func setDetached(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+		HideWindow:    true,
+	}
+}
+
+// processAlive PID의 프로세스가 실행 중인지 확인한다.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// 권한이 없어 열 수 없는 경우는 실행 중으로 본다
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == 259 // STILL_ACTIVE
+}
+
+// signalProcess term은 taskkill로 창을 닫도록 요청하고, kill은 TerminateProcess로 바로 끝낸다.
+// Windows에는 int, hup에 해당하는 신호가 없다.
+func signalProcess(pid int, signal string) error {
+	switch signal {
+	case "term":
+		out, err := exec.Command("taskkill", "/PID", strconv.Itoa(pid)).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("taskkill: %s", strings.TrimSpace(string(out)))
+		}
+		return nil
+	case "kill":
+		h, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, uint32(pid))
+		if err != nil {
+			return err
+		}
+		defer windows.CloseHandle(h)
+		return windows.TerminateProcess(h, 1)
+	default:
+		return fmt.Errorf("signal %q is not supported on Windows", signal)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessInfo 실행 중인 프로세스 하나
+type ProcessInfo struct {
+	PID           int     `json:"pid"`
+	PPID          int     `json:"ppid"`
+	Name          string  `json:"name"`
+	User          string  `json:"user,omitempty"`
+	Cmdline       string  `json:"cmdline,omitempty"`
+	CPU           float64 `json:"cpu"`            // 이전 조회 이후 CPU 사용률 (%, 전체 코어 기준)
+	Memory        uint64  `json:"memory"`         // 상주 메모리 (bytes)
+	MemoryPercent float64 `json:"memory_percent"` // 전체 메모리 대비 (%)
+
+	cpuTime time.Duration // 누적 CPU 시간 (user + system)
+	exeName string        // 실행 파일 이름 (Linux comm은 15자에서 잘리므로 이름 비교에 함께 쓴다)
+	started uint64        // 시작 시각 (PID 재사용 구분용, 단위는 플랫폼마다 다름)
+}
+
+// ProcessList processes 요청에 대한 응답
+type ProcessList struct {
+	RequestID string        `json:"request_id"`
+	Processes []ProcessInfo `json:"processes"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// KillRequest kill_process 요청 (PID나 이름 중 하나)
+type KillRequest struct {
+	PID    int    `json:"pid,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Signal string `json:"signal,omitempty"` // term (기본) | kill | int | hup
+}
+
+// KillResult kill_process 처리 결과
+type KillResult struct {
+	RequestID string   `json:"request_id"`
+	Signal    string   `json:"signal"`
+	Killed    []int    `json:"killed"`           // 신호를 보낸 PID
+	Errors    []string `json:"errors,omitempty"` // PID별 실패 사유
+}
+
+// 종료 요청에 쓸 수 있는 신호
+var killSignals = map[string]bool{"term": true, "kill": true, "int": true, "hup": true}
+
+// processKey PID와 시작 시각 (재사용된 PID의 CPU 시간을 잘못 빼지 않도록)
+type processKey struct {
+	pid     int
+	started uint64
+}
+
+// processSampler 이전 조회의 프로세스별 CPU 시간을 기억해 구간 사용률을 계산한다.
+type processSampler struct {
+	mu   sync.Mutex
+	prev map[processKey]time.Duration
+	at   time.Time
+}
+
+var processes = &processSampler{}
+
+// List 프로세스 목록. CPU 사용률은 이전 호출 이후 구간이며, 처음에는 cpuSampleInterval 동안 잰다.
+func (s *processSampler) List(collector hostCollector) ([]ProcessInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.prev == nil {
+		first, err := collector.Processes()
+		if err != nil {
+			return nil, err
+		}
+		s.remember(first, time.Now())
+		time.Sleep(cpuSampleInterval)
+	}
+	procs, err := collector.Processes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	elapsed := now.Sub(s.at) * time.Duration(runtime.NumCPU())
+	for i := range procs {
+		p := &procs[i]
+		if prev, ok := s.prev[processKey{p.PID, p.started}]; ok && elapsed > 0 && p.cpuTime >= prev {
+			p.CPU = clampPercent(float64(p.cpuTime-prev) / float64(elapsed) * 100)
+		}
+	}
+	s.remember(procs, now)
+	return procs, nil
+}
+
+func (s *processSampler) remember(procs []ProcessInfo, at time.Time) {
+	s.prev = make(map[processKey]time.Duration, len(procs))
+	for _, p := range procs {
+		s.prev[processKey{p.PID, p.started}] = p.cpuTime
+	}
+	s.at = at
+}
+
+// sendProcesses 프로세스 목록을 서버에 보낸다.
+func sendProcesses(conn *agentConn, requestID string) {
+	list := ProcessList{RequestID: requestID}
+	procs, err := processes.List(metricsCollector)
+	if err != nil {
+		list.Error = err.Error()
+	}
+	list.Processes = procs
+	conn.WriteJSON(Message{Type: "processes", Processes: &list})
+}
+
+// killProcesses PID 또는 이름이 같은 프로세스에 신호를 보낸다.
+// 에이전트 자신과 PID 1 이하는 건드리지 않는다.
+func killProcesses(collector hostCollector, req KillRequest) KillResult {
+	result := KillResult{Signal: req.Signal, Killed: []int{}}
+	if result.Signal == "" {
+		result.Signal = "term"
+	}
+	fail := func(format string, args ...interface{}) KillResult {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+		return result
+	}
+	if !killSignals[result.Signal] {
+		return fail("unknown signal %q", req.Signal)
+	}
+	if (req.PID == 0) == (req.Name == "") {
+		return fail("pid or name required")
+	}
+
+	var pids []int
+	if req.PID != 0 {
+		pids = []int{req.PID}
+	} else {
+		procs, err := collector.Processes()
+		if err != nil {
+			return fail("list processes: %v", err)
+		}
+		for _, p := range procs {
+			if p.nameMatches(req.Name) {
+				pids = append(pids, p.PID)
+			}
+		}
+		if len(pids) == 0 {
+			return fail("no process named %q", req.Name)
+		}
+	}
+
+	self := os.Getpid()
+	for _, pid := range pids {
+		switch {
+		case pid <= 1 || pid == self:
+			result.Errors = append(result.Errors, fmt.Sprintf("%d: refusing to signal this process", pid))
+		default:
+			if err := signalProcess(pid, result.Signal); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%d: %v", pid, err))
+				continue
+			}
+			result.Killed = append(result.Killed, pid)
+		}
+	}
+	return result
+}
+
+// nameMatches 프로세스 이름이나 실행 파일 이름이 want와 같은지 확인한다.
+func (p *ProcessInfo) nameMatches(want string) bool {
+	return processNameMatches(p.Name, want) || (p.exeName != "" && processNameMatches(p.exeName, want))
+}
+
+// processNameMatches 이름 비교 (Windows는 대소문자와 .exe를 무시)
+func processNameMatches(name, want string) bool {
+	if runtime.GOOS != "windows" {
+		return name == want
+	}
+	trim := func(s string) string {
+		return strings.TrimSuffix(strings.ToLower(s), ".exe")
+	}
+	return trim(name) == trim(want)
+}
+
+// handleKillProcess kill_process 요청을 처리하고 결과를 보낸다.
+func handleKillProcess(conn *agentConn, requestID string, req KillRequest) {
+	result := killProcesses(metricsCollector, req)
+	result.RequestID = requestID
+	conn.WriteJSON(Message{Type: "kill_result", Kill: &result})
+}
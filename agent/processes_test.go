@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProcessSamplerCPU(t *testing.T) {
+	fake := &fakeCollector{Procs: []ProcessInfo{
+		{PID: 10, Name: "busy", cpuTime: 2 * time.Second, started: 100},
+		{PID: 20, Name: "reused", cpuTime: 5 * time.Second, started: 200},
+	}}
+	s := &processSampler{}
+	first, _ := fake.Processes()
+	at := time.Now().Add(-time.Second)
+	s.remember(first, at)
+
+	// PID 20은 다른 프로세스가 재사용했다 (시작 시각이 다름)
+	fake.Procs[0].cpuTime += time.Second / 2
+	fake.Procs[1].started, fake.Procs[1].cpuTime = 300, 6*time.Second
+	procs, err := s.List(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if procs[0].CPU <= 0 || procs[0].CPU > 100 {
+		t.Errorf("busy cpu = %v", procs[0].CPU)
+	}
+	if procs[1].CPU != 0 {
+		t.Errorf("reused pid cpu = %v, want 0", procs[1].CPU)
+	}
+}
+
+func TestKillProcessesRefusesSelf(t *testing.T) {
+	fake := &fakeCollector{Procs: []ProcessInfo{
+		{PID: 1, Name: "gopc-agent"},
+		{PID: os.Getpid(), Name: "gopc-agent"},
+	}}
+	result := killProcesses(fake, KillRequest{Name: "gopc-agent"})
+	if len(result.Killed) != 0 || len(result.Errors) != 2 {
+		t.Errorf("result = %+v", result)
+	}
+
+	result = killProcesses(fake, KillRequest{Name: "missing"})
+	if len(result.Killed) != 0 || !slices.Equal(result.Errors, []string{`no process named "missing"`}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	result = killProcesses(fake, KillRequest{PID: 5, Signal: "usr1"})
+	if len(result.Errors) != 1 || result.Signal != "usr1" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestProcessNameMatchesExeName(t *testing.T) {
+	// Linux comm은 15자에서 잘린다
+	p := ProcessInfo{PID: 42, Name: "chromium-browse", exeName: "chromium-browser"}
+	for _, name := range []string{"chromium-browse", "chromium-browser"} {
+		if !p.nameMatches(name) {
+			t.Errorf("%q did not match", name)
+		}
+	}
+	if p.nameMatches("chromium") {
+		t.Error("prefix matched")
+	}
+	if (&ProcessInfo{Name: "sh"}).nameMatches("") {
+		t.Error("empty name matched")
+	}
+}
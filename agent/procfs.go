@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// /proc 파일 파서. 파일 대신 io.Reader를 받으므로 다른 OS에서도 고정된 내용(fixture)으로 확인할 수 있다.
+
+// mountPoint /proc/mounts 한 줄
+type mountPoint struct {
+	Device string
+	Path   string
+	FSType string
+}
+
+// 디스크 사용량을 보고하지 않는 가상 파일 시스템
+var pseudoFSTypes = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "tmpfs": true, "ramfs": true,
+	"cgroup": true, "cgroup2": true, "securityfs": true, "pstore": true, "debugfs": true,
+	"tracefs": true, "mqueue": true, "hugetlbfs": true, "configfs": true, "fusectl": true,
+	"autofs": true, "binfmt_misc": true, "bpf": true, "rpc_pipefs": true, "nsfs": true,
+	"squashfs": true, "efivarfs": true, "selinuxfs": true,
+}
+
+// parseProcStat /proc/stat 의 전체 CPU 줄(cpu ...)에서 누적 시간을 읽는다.
+// idle에는 iowait을 포함하고, guest 시간은 user에 이미 들어 있어 더하지 않는다.
+func parseProcStat(r io.Reader) (cpuTimes, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var t cpuTimes
+		// user nice system idle iowait irq softirq steal
+		for i, f := range fields[1:min(len(fields), 9)] {
+			v, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("/proc/stat: %v", err)
+			}
+			t.total += v
+			if i == 3 || i == 4 {
+				t.idle += v
+			}
+		}
+		return t, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return cpuTimes{}, err
+	}
+	return cpuTimes{}, fmt.Errorf("/proc/stat: cpu line not found")
+}
+
+// parseMeminfo /proc/meminfo 에서 전체 메모리와 사용 가능한 메모리(bytes)를 읽는다.
+// MemAvailable이 없는 오래된 커널은 MemFree + Buffers + Cached로 계산한다.
+func parseMeminfo(r io.Reader) (total, available uint64, err error) {
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+		values[name] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	total, ok := values["MemTotal"]
+	if !ok || total == 0 {
+		return 0, 0, fmt.Errorf("/proc/meminfo: MemTotal not found")
+	}
+	available, ok = values["MemAvailable"]
+	if !ok {
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	return total, min(available, total), nil
+}
+
+// parseUptime /proc/uptime 의 첫 값(부팅 후 초)
+func parseUptime(r io.Reader) (uint64, error) {
+	var uptime float64
+	if _, err := fmt.Fscan(r, &uptime); err != nil {
+		return 0, fmt.Errorf("/proc/uptime: %v", err)
+	}
+	return uint64(uptime), nil
+}
+
+// parseLoadavg /proc/loadavg 의 1, 5, 15분 평균 부하
+func parseLoadavg(r io.Reader) ([]float64, error) {
+	load := make([]float64, 3)
+	if _, err := fmt.Fscan(r, &load[0], &load[1], &load[2]); err != nil {
+		return nil, fmt.Errorf("/proc/loadavg: %v", err)
+	}
+	return load, nil
+}
+
+// parseNetDev /proc/net/dev 에서 인터페이스별 누적 카운터를 읽는다.
+// 수신: bytes packets errs drop fifo frame compressed multicast, 송신: bytes packets errs drop fifo colls carrier compressed
+func parseNetDev(r io.Reader) (map[string]netCounters, error) {
+	counters := map[string]netCounters{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			continue
+		}
+		var v [16]uint64
+		for i := range v {
+			n, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("/proc/net/dev: %v", err)
+			}
+			v[i] = n
+		}
+		counters[strings.TrimSpace(name)] = netCounters{
+			RxBytes: v[0], RxPackets: v[1], RxErrors: v[2], RxDropped: v[3],
+			TxBytes: v[8], TxPackets: v[9], TxErrors: v[10], TxDropped: v[11],
+		}
+	}
+	return counters, scanner.Err()
+}
+
+// parseMounts /proc/mounts 에서 디스크 사용량을 볼 마운트 지점을 고른다.
+// 가상 파일 시스템과 같은 장치를 다시 마운트한 경로(bind mount)는 뺀다.
+func parseMounts(r io.Reader) ([]mountPoint, error) {
+	var mounts []mountPoint
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		m := mountPoint{Device: fields[0], Path: unescapeMountPath(fields[1]), FSType: fields[2]}
+		if pseudoFSTypes[m.FSType] || strings.HasPrefix(m.FSType, "fuse.") {
+			continue
+		}
+		if !strings.HasPrefix(m.Device, "/") && m.FSType != "overlay" && m.FSType != "zfs" {
+			continue
+		}
+		if seen[m.Device] {
+			continue
+		}
+		seen[m.Device] = true
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath /proc/mounts 의 8진수 이스케이프(공백은 \040)를 되돌린다.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// procPidStat /proc/[pid]/stat 에서 필요한 값
+type procPidStat struct {
+	Name    string // comm (최대 15자)
+	PPID    int
+	CPU     uint64 // utime + stime (clock tick)
+	Started uint64 // 부팅 후 시작 시각 (clock tick)
+	RSS     uint64 // 상주 페이지 수
+}
+
+// parseProcPidStat /proc/[pid]/stat 한 줄을 읽는다.
+// comm에는 공백과 괄호가 들어갈 수 있으므로 마지막 ')' 뒤부터 필드를 나눈다.
+func parseProcPidStat(r io.Reader) (procPidStat, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return procPidStat{}, err
+	}
+	line := string(data)
+	open, end := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+	if open < 0 || end < open {
+		return procPidStat{}, fmt.Errorf("stat: comm not found")
+	}
+	// state ppid pgrp session tty_nr tpgid flags minflt cminflt majflt cmajflt utime stime ... starttime vsize rss
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 22 {
+		return procPidStat{}, fmt.Errorf("stat: %d fields", len(fields))
+	}
+	num := func(i int) uint64 {
+		v, _ := strconv.ParseUint(fields[i], 10, 64)
+		return v
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return procPidStat{
+		Name:    line[open+1 : end],
+		PPID:    ppid,
+		CPU:     num(11) + num(12),
+		Started: num(19),
+		RSS:     num(21),
+	}, nil
+}
+
+// parseProcPidUID /proc/[pid]/status 의 실제 UID
+func parseProcPidUID(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "Uid:"); ok {
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				return fields[0], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("status: Uid not found")
+}
+
+// parseProcPidCmdline /proc/[pid]/cmdline 의 NUL로 구분된 인자를 공백으로 잇는다 (커널 스레드는 빈 문자열).
+func parseProcPidCmdline(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	args := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	return strings.TrimSpace(strings.Join(args, " ")), nil
+}
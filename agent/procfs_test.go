@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const procStatFixture1 = `cpu  4705 356 584 3699 23 0 12 0 0 0
+cpu0 1393 280 141 1817 11 0 6 0 0 0
+cpu1 3312 76 443 1882 12 0 6 0 0 0
+intr 114930548 113199788 3 0 5 263 0 4 [... lots more numbers ...]
+ctxt 1990473
+btime 1062191376
+`
+
+// 두 번째 샘플: user +300, system +100, idle +550, iowait +50 → busy 400 / total 1000
+const procStatFixture2 = `cpu  5005 356 684 4249 73 0 12 0 0 0
+cpu0 1543 280 191 2092 36 0 6 0 0 0
+cpu1 3462 76 493 2157 37 0 6 0 0 0
+`
+
+func TestParseProcStatCPUDelta(t *testing.T) {
+	first, err := parseProcStat(strings.NewReader(procStatFixture1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.total != 4705+356+584+3699+23+0+12+0 || first.idle != 3699+23 {
+		t.Errorf("first = %+v", first)
+	}
+	second, err := parseProcStat(strings.NewReader(procStatFixture2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.usageSince(first); math.Abs(got-40) > 1e-9 {
+		t.Errorf("usage = %v, want 40", got)
+	}
+	// 카운터가 되돌아간 경우 (재부팅 등)
+	if got := first.usageSince(second); got != 0 {
+		t.Errorf("usage after counter reset = %v, want 0", got)
+	}
+
+	if _, err := parseProcStat(strings.NewReader("intr 1 2 3\n")); err == nil {
+		t.Error("missing cpu line accepted")
+	}
+	if _, err := parseProcStat(strings.NewReader("cpu  1 2 x 4\n")); err == nil {
+		t.Error("invalid number accepted")
+	}
+}
+
+func TestCPUSamplerUsesPreviousSample(t *testing.T) {
+	samples := []string{procStatFixture1, procStatFixture2}
+	s := &cpuSampler{read: func() (cpuTimes, error) {
+		fixture := samples[0]
+		samples = samples[1:]
+		return parseProcStat(strings.NewReader(fixture))
+	}}
+	// 이전 값이 있으면 기다리지 않고 그 이후 구간을 잰다
+	s.prev, _ = s.read()
+	usage, err := s.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(usage-40) > 1e-9 {
+		t.Errorf("usage = %v, want 40", usage)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	fixture := `MemTotal:        8048552 kB
+MemFree:          602024 kB
+MemAvailable:    4013456 kB
+Buffers:          256412 kB
+Cached:          3112944 kB
+SwapTotal:       2097148 kB
+`
+	total, available, err := parseMeminfo(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 8048552*1024 || available != 4013456*1024 {
+		t.Errorf("total, available = %d, %d", total, available)
+	}
+
+	// MemAvailable이 없는 오래된 커널
+	old := strings.Replace(fixture, "MemAvailable:    4013456 kB\n", "", 1)
+	_, available, err = parseMeminfo(strings.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(602024+256412+3112944) * 1024; available != want {
+		t.Errorf("available = %d, want %d", available, want)
+	}
+
+	if _, _, err := parseMeminfo(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Error("missing MemTotal accepted")
+	}
+}
+
+func TestParseUptimeAndLoadavg(t *testing.T) {
+	uptime, err := parseUptime(strings.NewReader("350735.47 234388.90\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime != 350735 {
+		t.Errorf("uptime = %d", uptime)
+	}
+
+	load, err := parseLoadavg(strings.NewReader("0.75 0.35 0.25 1/25 1747\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(load, []float64{0.75, 0.35, 0.25}) {
+		t.Errorf("load = %v", load)
+	}
+
+	if _, err := parseUptime(strings.NewReader("")); err == nil {
+		t.Error("empty uptime accepted")
+	}
+	if _, err := parseLoadavg(strings.NewReader("0.1 x")); err == nil {
+		t.Error("invalid loadavg accepted")
+	}
+}
+
+func TestParseNetDev(t *testing.T) {
+	fixture := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo: 1908016    18446    0    0    0     0          0         0  1908016    18446    0    0    0     0       0          0
+  eth0:123456789  98765    2    5    0     0          0       120 98765432    54321    1    3    0     0       0          0
+`
+	counters, err := parseNetDev(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counters) != 2 {
+		t.Fatalf("got %d interfaces", len(counters))
+	}
+	want := netCounters{
+		RxBytes: 123456789, RxPackets: 98765, RxErrors: 2, RxDropped: 5,
+		TxBytes: 98765432, TxPackets: 54321, TxErrors: 1, TxDropped: 3,
+	}
+	if counters["eth0"] != want {
+		t.Errorf("eth0 = %+v, want %+v", counters["eth0"], want)
+	}
+}
+
+func TestParseMounts(t *testing.T) {
+	fixture := `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda2 / ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev 0 0
+/dev/sda1 /boot/efi vfat rw,relatime 0 0
+/dev/sdb1 /mnt/USB\040Drive exfat rw,relatime 0 0
+/dev/sda2 /var/lib/docker ext4 rw,relatime 0 0
+gvfsd-fuse /run/user/1000/gvfs fuse.gvfsd-fuse rw 0 0
+overlay /var/lib/docker/overlay2/abc/merged overlay rw 0 0
+`
+	mounts, err := parseMounts(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, m := range mounts {
+		paths = append(paths, m.Path)
+	}
+	want := []string{"/", "/boot/efi", "/mnt/USB Drive", "/var/lib/docker/overlay2/abc/merged"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("paths = %q, want %q", paths, want)
+	}
+}
+
+func TestParseProcPid(t *testing.T) {
+	stat := "1234 (my (odd) app) S 1 1234 1234 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 1 0 5000 10000000 300 18446744073709551615\n"
+	got, err := parseProcPidStat(strings.NewReader(stat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := procPidStat{Name: "my (odd) app", PPID: 1, CPU: 300, Started: 5000, RSS: 300}
+	if got != want {
+		t.Errorf("stat = %+v, want %+v", got, want)
+	}
+	if _, err := parseProcPidStat(strings.NewReader("1234 app S 1")); err == nil {
+		t.Error("stat without comm accepted")
+	}
+
+	uid, err := parseProcPidUID(strings.NewReader("Name:\tapp\nUid:\t1000\t1000\t1000\t1000\nGid:\t1000\n"))
+	if err != nil || uid != "1000" {
+		t.Errorf("uid = %q, %v", uid, err)
+	}
+
+	cmdline, err := parseProcPidCmdline(strings.NewReader("/usr/bin/python3\x00-m\x00http.server\x00"))
+	if err != nil || cmdline != "/usr/bin/python3 -m http.server" {
+		t.Errorf("cmdline = %q, %v", cmdline, err)
+	}
+	if cmdline, _ := parseProcPidCmdline(strings.NewReader("")); cmdline != "" {
+		t.Errorf("kernel thread cmdline = %q", cmdline)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 작업 우선순위
+const (
+	PriorityInteractive = "interactive" // 대시보드에서 바로 보낸 명령 (기본값)
+	PriorityBackground  = "background"  // 설치, 대량 복사 등 오래 걸리는 작업
+)
+
+// ResultRejected 큐가 가득 차서 실행하지 않은 명령
+const ResultRejected = "rejected"
+
+// JobInfo jobs 조회에 응답하는 작업 정보
+type JobInfo struct {
+	ID        string     `json:"id"`
+	Command   string     `json:"command"`
+	Priority  string     `json:"priority"`
+	State     string     `json:"state"` // queued | running | detached
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// JobList jobs 조회 응답
+type JobList struct {
+	Running       []JobInfo `json:"running"`
+	Queued        []JobInfo `json:"queued"`
+	Detached      []JobInfo `json:"detached"`
+	MaxConcurrent int       `json:"max_concurrent"`
+	MaxQueued     int       `json:"max_queued"`
+}
+
+type jobEntry struct {
+	conn     *agentConn
+	req      CommandRequest
+	queuedAt time.Time
+	started  time.Time
+}
+
+func (j *jobEntry) info(state string) JobInfo {
+	info := JobInfo{
+		ID:       j.req.ID,
+		Command:  j.req.Command,
+		Priority: j.req.Priority,
+		State:    state,
+		QueuedAt: j.queuedAt,
+	}
+	if info.Command == "" && j.req.Exec != nil {
+		info.Command = j.req.Exec.String()
+	}
+	if info.Command == "" && j.req.Script != nil {
+		info.Command = j.req.Script.String()
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		info.StartedAt = &started
+	}
+	return info
+}
+
+// jobScheduler 동시 실행 수를 제한하고 우선순위 순서로 명령을 실행한다.
+type jobScheduler struct {
+	mu          sync.Mutex
+	maxRunning  int
+	maxQueued   int
+	running     map[string]*jobEntry
+	interactive []*jobEntry
+	background  []*jobEntry
+	localSeq    int
+}
+
+func newJobScheduler(maxRunning, maxQueued int) *jobScheduler {
+	if maxRunning <= 0 {
+		maxRunning = 1
+	}
+	return &jobScheduler{
+		maxRunning: maxRunning,
+		maxQueued:  maxQueued,
+		running:    make(map[string]*jobEntry),
+	}
+}
+
+// Submit 명령을 큐에 넣는다. 큐가 가득 차면 rejected 결과를 바로 보낸다.
+func (s *jobScheduler) Submit(conn *agentConn, req CommandRequest) {
+	if req.Priority != PriorityBackground {
+		req.Priority = PriorityInteractive
+	}
+
+	s.mu.Lock()
+	if req.ID == "" {
+		// 레거시 명령도 조회/취소할 수 있도록 로컬 ID 부여
+		s.localSeq++
+		req.ID = fmt.Sprintf("local-%d", s.localSeq)
+	}
+	if s.maxQueued > 0 && len(s.interactive)+len(s.background) >= s.maxQueued {
+		s.mu.Unlock()
+		log.Printf("Job queue full, rejecting %s", req.ID)
+		sendJobRejection(conn, req, ResultRejected, fmt.Sprintf("job queue full (%d queued)", s.maxQueued))
+		return
+	}
+
+	job := &jobEntry{conn: conn, req: req, queuedAt: time.Now()}
+	if req.Priority == PriorityBackground {
+		s.background = append(s.background, job)
+	} else {
+		s.interactive = append(s.interactive, job)
+	}
+	s.mu.Unlock()
+
+	s.dispatch()
+}
+
+// dispatch 실행 슬롯이 비어 있으면 대화형 작업부터 시작한다.
+func (s *jobScheduler) dispatch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.running) < s.maxRunning {
+		var job *jobEntry
+		switch {
+		case len(s.interactive) > 0:
+			job, s.interactive = s.interactive[0], s.interactive[1:]
+		case len(s.background) > 0:
+			job, s.background = s.background[0], s.background[1:]
+		default:
+			return
+		}
+
+		job.started = time.Now()
+		s.running[job.req.ID] = job
+		markRunning(job.req.ID)
+		go s.run(job)
+	}
+}
+
+func (s *jobScheduler) run(job *jobEntry) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.req.ID)
+		s.mu.Unlock()
+		clearRunning(job.req.ID)
+		s.dispatch()
+	}()
+	// 서버가 대기(sent)와 실행(running)을 구분할 수 있도록 시작을 알린다
+	info := job.info("running")
+	job.conn.WriteJSON(Message{Type: "command_started", Job: &info})
+	executeCommand(job.conn, job.req)
+}
+
+// CancelQueued 아직 시작하지 않은 작업을 큐에서 제거한다.
+func (s *jobScheduler) CancelQueued(id string) bool {
+	s.mu.Lock()
+	var job *jobEntry
+	if s.interactive, job = removeJob(s.interactive, id); job == nil {
+		s.background, job = removeJob(s.background, id)
+	}
+	s.mu.Unlock()
+
+	if job == nil {
+		return false
+	}
+	sendJobRejection(job.conn, job.req, ResultKilled, "canceled before start")
+	return true
+}
+
+func removeJob(queue []*jobEntry, id string) ([]*jobEntry, *jobEntry) {
+	for i, job := range queue {
+		if job.req.ID == id {
+			return append(queue[:i:i], queue[i+1:]...), job
+		}
+	}
+	return queue, nil
+}
+
+// List 실행 중/대기 중 작업 목록
+func (s *jobScheduler) List() JobList {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := JobList{
+		Running:       []JobInfo{},
+		Queued:        []JobInfo{},
+		Detached:      []JobInfo{},
+		MaxConcurrent: s.maxRunning,
+		MaxQueued:     s.maxQueued,
+	}
+	for _, job := range s.running {
+		list.Running = append(list.Running, job.info("running"))
+	}
+	sort.Slice(list.Running, func(i, j int) bool {
+		return list.Running[i].StartedAt.Before(*list.Running[j].StartedAt)
+	})
+	for _, job := range s.interactive {
+		list.Queued = append(list.Queued, job.info("queued"))
+	}
+	for _, job := range s.background {
+		list.Queued = append(list.Queued, job.info("queued"))
+	}
+	return list
+}
+
+// sendJobRejection 실행하지 않은 작업에 대한 결과를 보낸다.
+func sendJobRejection(conn *agentConn, req CommandRequest, status, reason string) {
+	now := time.Now()
+	command := req.Command
+	if req.Exec != nil {
+		command = req.Exec.String()
+	}
+	if req.Script != nil {
+		command = req.Script.String()
+	}
+	result := &CommandResult{
+		ID:        req.ID,
+		Command:   command,
+		Status:    status,
+		ExitCode:  -1,
+		Error:     reason,
+		StartedAt: now,
+	}
+	if status == ResultKilled {
+		result.KillReason = KillCanceled
+	}
+	if req.Script != nil {
+		result.Script = req.Script.Ref()
+	}
+	result.finish()
+	conn.WriteJSON(Message{Type: "command_result", Result: result})
+}
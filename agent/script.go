@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ScriptPayload 서버 스크립트 라이브러리에서 전달된 스크립트 (에이전트 OS에 맞는 변형)
+type ScriptPayload struct {
+	Name    string            `json:"name"`
+	Version int               `json:"version"`
+	Shell   string            `json:"shell"` // sh | bash | cmd | powershell
+	Body    string            `json:"body"`
+	SHA256  string            `json:"sha256"`           // Body의 SHA-256 (hex)
+	Params  map[string]string `json:"params,omitempty"` // 환경 변수로 전달할 파라미터 값
+}
+
+// ScriptRef 결과에 기록하는 실행한 스크립트 버전
+type ScriptRef struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// String 결과/로그 표시용 문자열
+func (s *ScriptPayload) String() string {
+	return fmt.Sprintf("script:%s@v%d", s.Name, s.Version)
+}
+
+// Ref 결과에 기록할 스크립트 버전
+func (s *ScriptPayload) Ref() *ScriptRef {
+	return &ScriptRef{Name: s.Name, Version: s.Version}
+}
+
+// prepare 스크립트를 임시 파일로 저장하고 그 파일을 실행하는 구조화 명령을 만든다.
+// 반환된 cleanup으로 실행 후 임시 파일을 지운다.
+func (s *ScriptPayload) prepare() (*ExecSpec, func(), error) {
+	sum := sha256.Sum256([]byte(s.Body))
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), s.SHA256) {
+		return nil, nil, fmt.Errorf("script checksum mismatch")
+	}
+
+	body := s.Body
+	var ext string
+	switch s.Shell {
+	case "sh", "bash":
+		ext = ".sh"
+	case "cmd":
+		ext = ".bat"
+		// 배치 파일은 CRLF 줄바꿈이 아니면 레이블/goto가 오동작한다.
+		body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	case "powershell":
+		ext = ".ps1"
+	default:
+		return nil, nil, fmt.Errorf("unsupported script shell %q", s.Shell)
+	}
+
+	f, err := os.CreateTemp("", "gopc-script-*"+ext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create script file: %v", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	if _, err := f.WriteString(body); err != nil {
+		f.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("write script file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("write script file: %v", err)
+	}
+
+	var argv []string
+	switch s.Shell {
+	case "sh", "bash":
+		argv = []string{s.Shell, f.Name()}
+	case "cmd":
+		argv = []string{"cmd", "/C", f.Name()}
+	case "powershell":
+		ps := "powershell"
+		if runtime.GOOS != "windows" {
+			ps = "pwsh"
+		}
+		argv = []string{ps, "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", f.Name()}
+	}
+
+	spec := &ExecSpec{Argv: argv, Env: s.Params}
+	if err := spec.Validate(); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return spec, cleanup, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import "github.com/kardianos/service"
+
+// systemdUnit kardianos/service 기본 유닛에 KillMode=process를 더한 것.
+// 기본값(control-group)이면 에이전트가 재시작하거나 업데이트로 종료될 때 systemd가
+// 같은 cgroup에 있는 분리 실행 감독 프로세스와 작업까지 함께 종료한다.
+// 일반 명령은 서비스 중지 시 에이전트가 직접 종료한다 (stopCommands).
+const systemdUnit = `[Unit]
+Description={{.Description}}
+ConditionFileIsExecutable={{.Path|cmdEscape}}
+{{range $i, $dep := .Dependencies}}
+{{$dep}} {{end}}
+
+[Service]
+StartLimitInterval=5
+StartLimitBurst=10
+ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
+KillMode=process
+{{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
+{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
+{{if .UserName}}User={{.UserName}}{{end}}
+{{if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
+{{if .PIDFile}}PIDFile={{.PIDFile|cmd}}{{end}}
+{{if and .LogOutput .HasOutputFileSupport -}}
+StandardOutput=file:{{.LogDirectory}}/{{.Name}}.out
+StandardError=file:{{.LogDirectory}}/{{.Name}}.err
+{{- end}}
+{{if gt .LimitNOFILE -1 }}LimitNOFILE={{.LimitNOFILE}}{{end}}
+{{if .Restart}}Restart={{.Restart}}{{end}}
+{{if .SuccessExitStatus}}SuccessExitStatus={{.SuccessExitStatus}}{{end}}
+RestartSec=120
+EnvironmentFile=-/etc/sysconfig/{{.Name}}
+
+{{range $k, $v := .EnvVars -}}
+Environment={{$k}}={{$v}}
+{{end -}}
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// serviceOptions 서비스 설치 옵션 (SystemdScript는 Linux systemd에서만 쓰인다)
+func serviceOptions() service.KeyValue {
+	return service.KeyValue{"SystemdScript": systemdUnit}
+}
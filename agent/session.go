@@ -0,0 +1,15 @@
+package main
+
+// userSession 활성 사용자 세션(로그인한 학생)에서 프로그램을 실행하는 플랫폼별 구현
+type userSession interface {
+	// RunAsUser 활성 사용자 세션에서 명령을 실행한다. 완료를 기다리지 않는다.
+	RunAsUser(command string) error
+}
+
+// sessionRunner 현재 플랫폼 구현 (테스트에서는 fakeSession으로 바꿀 수 있음)
+var sessionRunner userSession = newPlatformSession()
+
+// runAsUser 활성 사용자 세션에서 명령을 실행한다.
+func runAsUser(command string) error {
+	return sessionRunner.RunAsUser(command)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// utmp 파일 경로 (logind가 없을 때 사용)
+const utmpPath = "/var/run/utmp"
+
+// utmp ut_type: 로그인한 사용자 프로세스
+const utmpUserProcess = 7
+
+// loginSession 사용자 세션 정보
+type loginSession struct {
+	ID      string
+	User    string // 사용자 이름
+	Type    string // x11 | wayland | tty ...
+	Class   string // user | greeter ...
+	Display string // X 디스플레이 (:0)
+	Active  bool
+	Remote  bool
+}
+
+// graphical 그래픽 세션 여부
+func (s *loginSession) graphical() bool {
+	return s.Type == "x11" || s.Type == "wayland" || s.Display != ""
+}
+
+// linuxSession logind(없으면 utmp)에서 활성 세션을 찾아 그 사용자 권한과 환경으로 실행한다.
+type linuxSession struct{}
+
+func newPlatformSession() userSession {
+	return linuxSession{}
+}
+
+func (linuxSession) RunAsUser(command string) error {
+	session, err := findActiveSession()
+	if err != nil {
+		return err
+	}
+	u, err := user.Lookup(session.User)
+	if err != nil {
+		return fmt.Errorf("lookup user %s: %v", session.User, err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid uid %s", u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid gid %s", u.Gid)
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Dir = u.HomeDir
+	cmd.Env = sessionEnv(u, session, filepath.Join("/run/user", u.Uid))
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	switch {
+	case os.Geteuid() == 0:
+		var groups []uint32
+		if ids, err := u.GroupIds(); err == nil {
+			for _, id := range ids {
+				if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+					groups = append(groups, uint32(g))
+				}
+			}
+		}
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid:    uint32(uid),
+			Gid:    uint32(gid),
+			Groups: groups,
+		}
+	case uint64(os.Geteuid()) != uid:
+		return fmt.Errorf("agent must run as root to start programs as %s", u.Username)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	log.Printf("Launched process in session %s of %s: %s (PID: %d)", session.ID, u.Username, command, cmd.Process.Pid)
+	go cmd.Wait()
+	return nil
+}
+
+// findActiveSession 로컬 활성 세션을 찾는다. 그래픽 세션을 우선한다.
+func findActiveSession() (*loginSession, error) {
+	sessions, err := logindSessions()
+	if err != nil {
+		log.Printf("logind unavailable (%v), falling back to utmp", err)
+		f, err := os.Open(utmpPath)
+		if err != nil {
+			return nil, fmt.Errorf("no login session source: %v", err)
+		}
+		defer f.Close()
+		if sessions, err = parseUtmp(f, processAlive); err != nil {
+			return nil, err
+		}
+	}
+
+	if s := pickSession(sessions); s != nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("no active local user session found")
+}
+
+// pickSession 활성 로컬 사용자 세션 중 그래픽 세션을 우선 고른다.
+func pickSession(sessions []*loginSession) *loginSession {
+	var fallback *loginSession
+	for _, s := range sessions {
+		if !s.Active || s.Remote || s.User == "" || (s.Class != "" && s.Class != "user") {
+			continue
+		}
+		if s.graphical() {
+			return s
+		}
+		if fallback == nil {
+			fallback = s
+		}
+	}
+	return fallback
+}
+
+// logindSessions loginctl로 세션 목록과 속성을 읽는다.
+func logindSessions() ([]*loginSession, error) {
+	out, err := exec.Command("loginctl", "list-sessions", "--no-legend").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*loginSession
+	for _, id := range parseLoginctlSessions(bytes.NewReader(out)) {
+		props, err := exec.Command("loginctl", "show-session", id,
+			"-p", "Name", "-p", "Type", "-p", "Class", "-p", "Display",
+			"-p", "Active", "-p", "Remote").Output()
+		if err != nil {
+			continue
+		}
+		s := parseLoginctlShow(bytes.NewReader(props))
+		s.ID = id
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// parseLoginctlSessions `loginctl list-sessions --no-legend` 출력에서 세션 ID 목록을 읽는다.
+func parseLoginctlSessions(r io.Reader) []string {
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			ids = append(ids, fields[0])
+		}
+	}
+	return ids
+}
+
+// parseLoginctlShow `loginctl show-session` 의 Key=Value 출력을 읽는다.
+func parseLoginctlShow(r io.Reader) *loginSession {
+	s := &loginSession{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Name":
+			s.User = value
+		case "Type":
+			s.Type = value
+		case "Class":
+			s.Class = value
+		case "Display":
+			s.Display = value
+		case "Active":
+			s.Active = value == "yes"
+		case "Remote":
+			s.Remote = value == "yes"
+		}
+	}
+	return s
+}
+
+// utmpRecord glibc struct utmp (Linux, 384 bytes)
+type utmpRecord struct {
+	Type    int16
+	_       [2]byte
+	Pid     int32
+	Line    [32]byte
+	ID      [4]byte
+	User    [32]byte
+	Host    [256]byte
+	Exit    [2]int16
+	Session int32
+	Tv      [2]int32
+	AddrV6  [4]int32
+	_       [20]byte
+}
+
+// parseUtmp utmp 레코드 중 살아 있는 사용자 프로세스를 세션으로 읽는다.
+// utmp에는 활성/원격 구분이 없으므로 호스트가 비어 있거나 X 디스플레이인 것만 로컬 활성 세션으로 본다.
+func parseUtmp(r io.Reader, alive func(pid int) bool) ([]*loginSession, error) {
+	var sessions []*loginSession
+	for {
+		var rec utmpRecord
+		if err := binary.Read(r, binary.NativeEndian, &rec); err != nil {
+			if err == io.EOF {
+				return sessions, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return sessions, fmt.Errorf("truncated utmp record")
+			}
+			return sessions, err
+		}
+		if rec.Type != utmpUserProcess || (alive != nil && !alive(int(rec.Pid))) {
+			continue
+		}
+
+		line := cString(rec.Line[:])
+		host := cString(rec.Host[:])
+		s := &loginSession{
+			ID:     line,
+			User:   cString(rec.User[:]),
+			Type:   "tty",
+			Active: true,
+		}
+		switch {
+		case strings.HasPrefix(line, ":"):
+			s.Display, s.Type = line, "x11"
+		case strings.HasPrefix(host, ":"):
+			s.Display, s.Type = host, "x11"
+		case host != "":
+			s.Remote = true
+		}
+		sessions = append(sessions, s)
+	}
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// sessionEnv 세션 사용자의 실행 환경 (DISPLAY, XDG_RUNTIME_DIR, D-Bus 등)
+func sessionEnv(u *user.User, s *loginSession, runtimeDir string) []string {
+	env := []string{
+		"HOME=" + u.HomeDir,
+		"USER=" + u.Username,
+		"LOGNAME=" + u.Username,
+		"SHELL=/bin/sh",
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	}
+	if lang := os.Getenv("LANG"); lang != "" {
+		env = append(env, "LANG="+lang)
+	}
+
+	if fi, err := os.Stat(runtimeDir); err == nil && fi.IsDir() {
+		env = append(env, "XDG_RUNTIME_DIR="+runtimeDir)
+		if _, err := os.Stat(filepath.Join(runtimeDir, "bus")); err == nil {
+			env = append(env, "DBUS_SESSION_BUS_ADDRESS=unix:path="+filepath.Join(runtimeDir, "bus"))
+		}
+		if s.Type == "wayland" {
+			if sockets, _ := filepath.Glob(filepath.Join(runtimeDir, "wayland-[0-9]*")); len(sockets) > 0 {
+				env = append(env, "WAYLAND_DISPLAY="+filepath.Base(sockets[0]))
+			}
+		}
+	}
+
+	display := s.Display
+	if display == "" && s.graphical() {
+		display = ":0"
+	}
+	if display != "" {
+		env = append(env, "DISPLAY="+display)
+		if xauth := filepath.Join(u.HomeDir, ".Xauthority"); fileExists(xauth) {
+			env = append(env, "XAUTHORITY="+xauth)
+		}
+	}
+	if s.Type != "" {
+		env = append(env, "XDG_SESSION_TYPE="+s.Type)
+	}
+	return env
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
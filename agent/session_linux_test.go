@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseLoginctlSessions(t *testing.T) {
+	out := `      2 1000 student seat0 tty2
+     c1  120 gdm     seat0 tty1
+`
+	ids := parseLoginctlSessions(strings.NewReader(out))
+	if !slices.Equal(ids, []string{"2", "c1"}) {
+		t.Errorf("ids = %q", ids)
+	}
+}
+
+func TestParseLoginctlShow(t *testing.T) {
+	out := `Name=student
+Type=wayland
+Class=user
+Display=
+Active=yes
+Remote=no
+`
+	s := parseLoginctlShow(strings.NewReader(out))
+	want := loginSession{User: "student", Type: "wayland", Class: "user", Active: true}
+	if *s != want {
+		t.Errorf("session = %+v, want %+v", *s, want)
+	}
+	if !s.graphical() {
+		t.Error("wayland session not graphical")
+	}
+}
+
+func TestPickSessionPrefersGraphical(t *testing.T) {
+	sessions := []*loginSession{
+		{ID: "c1", User: "gdm", Type: "x11", Class: "greeter", Active: true},
+		{ID: "3", User: "admin", Type: "tty", Class: "user", Active: true, Remote: true},
+		{ID: "4", User: "student", Type: "tty", Class: "user", Active: true},
+		{ID: "2", User: "student", Type: "x11", Class: "user", Display: ":0", Active: true},
+	}
+	if s := pickSession(sessions); s == nil || s.ID != "2" {
+		t.Errorf("picked %+v, want session 2", s)
+	}
+	if s := pickSession(sessions[:3]); s == nil || s.ID != "4" {
+		t.Errorf("picked %+v, want tty session 4", s)
+	}
+	if s := pickSession(sessions[:2]); s != nil {
+		t.Errorf("picked %+v from greeter and remote sessions", s)
+	}
+}
+
+func utmpFixture(t *testing.T, recs ...utmpRecord) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		if err := binary.Write(&buf, binary.NativeEndian, &rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf
+}
+
+func utmpUser(pid int32, line, user, host string) utmpRecord {
+	rec := utmpRecord{Type: utmpUserProcess, Pid: pid}
+	copy(rec.Line[:], line)
+	copy(rec.User[:], user)
+	copy(rec.Host[:], host)
+	return rec
+}
+
+func TestParseUtmp(t *testing.T) {
+	if size := binary.Size(utmpRecord{}); size != 384 {
+		t.Fatalf("utmp record size = %d, want 384", size)
+	}
+	boot := utmpRecord{Type: 2} // BOOT_TIME
+	copy(boot.User[:], "reboot")
+	buf := utmpFixture(t,
+		boot,
+		utmpUser(100, "tty1", "student", ""),
+		utmpUser(200, ":0", "student", ""),
+		utmpUser(300, "pts/0", "student", ":0"),
+		utmpUser(400, "pts/1", "admin", "10.0.0.5"),
+		utmpUser(500, "tty2", "gone", ""),
+	)
+
+	alive := func(pid int) bool { return pid != 500 }
+	sessions, err := parseUtmp(buf, alive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []loginSession{
+		{ID: "tty1", User: "student", Type: "tty", Active: true},
+		{ID: ":0", User: "student", Type: "x11", Display: ":0", Active: true},
+		{ID: "pts/0", User: "student", Type: "x11", Display: ":0", Active: true},
+		{ID: "pts/1", User: "admin", Type: "tty", Active: true, Remote: true},
+	}
+	if len(sessions) != len(want) {
+		t.Fatalf("got %d sessions, want %d", len(sessions), len(want))
+	}
+	for i, s := range sessions {
+		if *s != want[i] {
+			t.Errorf("session %d = %+v, want %+v", i, *s, want[i])
+		}
+	}
+	if s := pickSession(sessions); s == nil || s.ID != ":0" {
+		t.Errorf("picked %+v, want :0", s)
+	}
+}
+
+func TestParseUtmpTruncated(t *testing.T) {
+	buf := utmpFixture(t, utmpUser(100, "tty1", "student", ""))
+	buf.Truncate(buf.Len() - 10)
+	if _, err := parseUtmp(buf, nil); err == nil {
+		t.Error("truncated record accepted")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !windows
+
+package main
+
+import "fmt"
+
+// unsupportedSession 사용자 세션 실행을 지원하지 않는 플랫폼
+type unsupportedSession struct{}
+
+func newPlatformSession() userSession {
+	return unsupportedSession{}
+}
+
+func (unsupportedSession) RunAsUser(command string) error {
+	return fmt.Errorf("running in the user session is not supported on this platform")
+}
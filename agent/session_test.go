@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// fakeSession 실행 요청을 기록만 하는 테스트용 구현
+type fakeSession struct {
+	mu       sync.Mutex
+	Commands []string
+	Err      error // RunAsUser가 반환할 오류
+}
+
+func (f *fakeSession) RunAsUser(command string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Commands = append(f.Commands, command)
+	return f.Err
+}
+
+func TestRunGUICommandUsesSession(t *testing.T) {
+	fake := &fakeSession{}
+	saved := sessionRunner
+	sessionRunner = fake
+	t.Cleanup(func() { sessionRunner = saved })
+
+	result := runGUICommand("gui:firefox https://example.com", "firefox https://example.com")
+	if result.Status != ResultSucceeded || result.ExitCode != 0 {
+		t.Fatalf("status = %s, exit = %d, want succeeded", result.Status, result.ExitCode)
+	}
+	if result.Command != "gui:firefox https://example.com" {
+		t.Errorf("command = %q", result.Command)
+	}
+	if !slices.Equal(fake.Commands, []string{"firefox https://example.com"}) {
+		t.Errorf("session commands = %q", fake.Commands)
+	}
+
+	fake.Err = errors.New("no active local user session found")
+	result = runGUICommand("gui:calc", "calc")
+	if result.Status != ResultStartFailed || result.ExitCode != -1 {
+		t.Fatalf("status = %s, exit = %d, want start_failed", result.Status, result.ExitCode)
+	}
+	if result.Error != fake.Err.Error() {
+		t.Errorf("error = %q", result.Error)
+	}
+}
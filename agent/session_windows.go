@@ -23,7 +23,14 @@ var (
 	procCreateProcessAsUserW         = modadvapi32.NewProc("CreateProcessAsUserW")
 )
 
-func runAsUser(command string) error {
+// windowsSession WTS API로 활성 콘솔 세션 사용자의 토큰을 얻어 프로세스를 만든다.
+type windowsSession struct{}
+
+func newPlatformSession() userSession {
+	return windowsSession{}
+}
+
+func (windowsSession) RunAsUser(command string) error {
 	log.Println("runAsUser: Starting")
 
 	// 0. Check if we are already in a user session
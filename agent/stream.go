@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 출력 청크 최대 크기
+	maxChunkSize = 16 * 1024
+	// 버퍼에 모인 출력을 보내는 주기
+	flushInterval = 250 * time.Millisecond
+)
+
+// OutputChunk 실행 중인 명령의 출력 조각
+type OutputChunk struct {
+	ID     string `json:"id"`
+	Seq    int    `json:"seq"`    // 명령별 0부터 증가하는 순번
+	Stream string `json:"stream"` // stdout | stderr
+	Data   string `json:"data"`
+}
+
+// outputStream 명령 출력을 모아 주기적으로 command_output 메시지로 전송한다.
+// stdout/stderr 쓰기 순서를 유지하며, 같은 스트림의 연속 쓰기는 한 청크로 합친다.
+type outputStream struct {
+	conn *agentConn
+	id   string
+
+	flushMu sync.Mutex // 청크 전송 순서 보장
+	mu      sync.Mutex
+	seq     int
+	pending []*pendingChunk
+	size    int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type pendingChunk struct {
+	stream string
+	data   []byte
+}
+
+func newOutputStream(conn *agentConn, id string) *outputStream {
+	s := &outputStream{
+		conn: conn,
+		id:   id,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Writer 지정한 스트림(stdout/stderr)으로 쓰는 io.Writer 반환
+func (s *outputStream) Writer(stream string) io.Writer {
+	return streamWriter{s: s, stream: stream}
+}
+
+type streamWriter struct {
+	s      *outputStream
+	stream string
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.s.write(w.stream, p)
+	return len(p), nil
+}
+
+func (s *outputStream) write(stream string, p []byte) {
+	s.mu.Lock()
+	if n := len(s.pending); n > 0 && s.pending[n-1].stream == stream {
+		s.pending[n-1].data = append(s.pending[n-1].data, p...)
+	} else {
+		s.pending = append(s.pending, &pendingChunk{stream: stream, data: append([]byte(nil), p...)})
+	}
+	s.size += len(p)
+	full := s.size >= maxChunkSize
+	s.mu.Unlock()
+
+	if full {
+		s.flush(false)
+	}
+}
+
+func (s *outputStream) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(false)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// flush 모인 출력을 전송한다. final이 아니면 끝에 잘린 UTF-8 문자는 다음 전송으로 미룬다.
+func (s *outputStream) flush(final bool) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	var chunks []OutputChunk
+	var keep []*pendingChunk
+	for i, pc := range s.pending {
+		data := pc.data
+		if !final && i == len(s.pending)-1 {
+			cut := utf8Boundary(data)
+			if cut < len(data) {
+				keep = append(keep, &pendingChunk{stream: pc.stream, data: append([]byte(nil), data[cut:]...)})
+				data = data[:cut]
+			}
+		}
+		for len(data) > 0 {
+			n := len(data)
+			if n > maxChunkSize {
+				n = utf8Boundary(data[:maxChunkSize])
+				if n == 0 {
+					n = maxChunkSize
+				}
+			}
+			chunks = append(chunks, OutputChunk{ID: s.id, Seq: s.seq, Stream: pc.stream, Data: string(data[:n])})
+			s.seq++
+			data = data[n:]
+		}
+	}
+	s.pending = keep
+	s.size = 0
+	for _, pc := range keep {
+		s.size += len(pc.data)
+	}
+	s.mu.Unlock()
+
+	for i := range chunks {
+		s.conn.WriteJSON(Message{Type: "command_output", Output: &chunks[i]})
+	}
+}
+
+// Close 남은 출력을 모두 전송하고 보낸 청크 수를 반환한다.
+func (s *outputStream) Close() int {
+	close(s.stop)
+	<-s.done
+	s.flush(true)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq
+}
+
+// utf8Boundary 끝에 잘린 멀티바이트 문자가 있으면 그 앞까지의 길이를 반환한다.
+func utf8Boundary(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"gopc-agent/config"
+)
+
+// 터미널 기본 크기
+const (
+	defaultTermCols = 80
+	defaultTermRows = 24
+)
+
+// terminal 셸이 연결된 의사 터미널 (Linux: pty, Windows: ConPTY 예정)
+type terminal interface {
+	io.ReadWriteCloser
+	Resize(cols, rows uint16) error
+	Wait() error
+}
+
+// PTYRequest 서버에서 받은 터미널 세션 메시지 필드
+type PTYRequest struct {
+	Session string `json:"session"`
+	Cols    uint16 `json:"cols,omitempty"`
+	Rows    uint16 `json:"rows,omitempty"`
+	Data    string `json:"data,omitempty"` // base64
+}
+
+// PTYMessage 서버로 보내는 터미널 세션 메시지
+type PTYMessage struct {
+	Session string `json:"session"`
+	Data    string `json:"data,omitempty"`   // base64
+	Reason  string `json:"reason,omitempty"` // pty_closed 사유
+}
+
+var (
+	termMu   sync.Mutex
+	termSess = make(map[string]terminal)
+)
+
+// handlePTYMessage pty_* 메시지를 처리한다.
+func handlePTYMessage(conn *agentConn, cfg *config.Config, msgType string, req PTYRequest) {
+	switch msgType {
+	case "pty_open":
+		openTermSession(conn, cfg, req)
+	case "pty_input":
+		data, err := base64.StdEncoding.DecodeString(req.Data)
+		if err != nil {
+			return
+		}
+		if t := getTermSession(req.Session); t != nil {
+			t.Write(data)
+		}
+	case "pty_resize":
+		if t := getTermSession(req.Session); t != nil && req.Cols > 0 && req.Rows > 0 {
+			t.Resize(req.Cols, req.Rows)
+		}
+	case "pty_close":
+		if t := getTermSession(req.Session); t != nil {
+			t.Close()
+		}
+	}
+}
+
+func getTermSession(id string) terminal {
+	termMu.Lock()
+	defer termMu.Unlock()
+	return termSess[id]
+}
+
+func openTermSession(conn *agentConn, cfg *config.Config, req PTYRequest) {
+	if req.Session == "" {
+		return
+	}
+
+	termMu.Lock()
+	if _, exists := termSess[req.Session]; exists {
+		termMu.Unlock()
+		return
+	}
+	if len(termSess) >= cfg.MaxTerminalSessions {
+		termMu.Unlock()
+		sendPTY(conn, "pty_closed", PTYMessage{
+			Session: req.Session,
+			Reason:  fmt.Sprintf("session limit reached (%d)", cfg.MaxTerminalSessions),
+		})
+		return
+	}
+	termMu.Unlock()
+
+	cols, rows := req.Cols, req.Rows
+	if cols == 0 || rows == 0 {
+		cols, rows = defaultTermCols, defaultTermRows
+	}
+	t, err := openTerminal(cols, rows)
+	if err != nil {
+		log.Printf("Failed to open terminal: %v", err)
+		sendPTY(conn, "pty_closed", PTYMessage{Session: req.Session, Reason: err.Error()})
+		return
+	}
+
+	termMu.Lock()
+	termSess[req.Session] = t
+	termMu.Unlock()
+
+	log.Printf("Terminal session %s opened", req.Session)
+	sendPTY(conn, "pty_opened", PTYMessage{Session: req.Session})
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := t.Read(buf)
+			if n > 0 {
+				sendPTY(conn, "pty_output", PTYMessage{
+					Session: req.Session,
+					Data:    base64.StdEncoding.EncodeToString(buf[:n]),
+				})
+			}
+			if err != nil {
+				break
+			}
+		}
+
+		t.Close()
+		reason := "exited"
+		if err := t.Wait(); err != nil {
+			reason = err.Error()
+		}
+
+		termMu.Lock()
+		delete(termSess, req.Session)
+		termMu.Unlock()
+
+		log.Printf("Terminal session %s closed (%s)", req.Session, reason)
+		sendPTY(conn, "pty_closed", PTYMessage{Session: req.Session, Reason: reason})
+	}()
+}
+
+// closeAllTermSessions 서버 연결이 끊기면 모든 터미널 세션을 종료한다.
+func closeAllTermSessions() {
+	termMu.Lock()
+	defer termMu.Unlock()
+
+	for _, t := range termSess {
+		t.Close()
+	}
+}
+
+func sendPTY(conn *agentConn, msgType string, pty PTYMessage) {
+	conn.WriteJSON(Message{Type: msgType, PTY: &pty})
+}
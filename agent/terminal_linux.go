@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ptyTerminal /dev/ptmx 기반 의사 터미널
+type ptyTerminal struct {
+	ptmx *os.File
+	cmd  *exec.Cmd
+}
+
+func openTerminal(cols, rows uint16) (terminal, error) {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open ptmx: %v", err)
+	}
+
+	// 슬레이브 잠금 해제 후 번호 조회
+	var ptsNum uint32
+	err = controlFd(ptmx, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return err
+		}
+		n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+		ptsNum = n
+		return err
+	})
+	if err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("unlock pty: %v", err)
+	}
+
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", ptsNum), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("open pts: %v", err)
+	}
+	defer tty.Close()
+
+	t := &ptyTerminal{ptmx: ptmx}
+	if err := t.Resize(cols, rows); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+		if _, err := os.Stat("/bin/bash"); err == nil {
+			shell = "/bin/bash"
+		}
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	// 새 세션의 제어 터미널로 pts 지정 (Ctty는 자식 프로세스의 stdin fd)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("start shell: %v", err)
+	}
+	t.cmd = cmd
+	return t, nil
+}
+
+func (t *ptyTerminal) Read(p []byte) (int, error) {
+	return t.ptmx.Read(p)
+}
+
+func (t *ptyTerminal) Write(p []byte) (int, error) {
+	return t.ptmx.Write(p)
+}
+
+func (t *ptyTerminal) Resize(cols, rows uint16) error {
+	return controlFd(t.ptmx, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+	})
+}
+
+// Close 셸 프로세스 그룹을 종료하고 마스터를 닫는다.
+func (t *ptyTerminal) Close() error {
+	if t.cmd != nil && t.cmd.Process != nil {
+		killProcessTree(t.cmd.Process)
+	}
+	return t.ptmx.Close()
+}
+
+func (t *ptyTerminal) Wait() error {
+	return t.cmd.Wait()
+}
+
+// controlFd 파일을 블로킹 모드로 바꾸지 않고 fd에 ioctl을 수행한다.
+func controlFd(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	if err := rc.Control(func(fd uintptr) { opErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return opErr
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func openTerminal(cols, rows uint16) (terminal, error) {
+	return nil, fmt.Errorf("terminal sessions are not supported on %s", runtime.GOOS)
+}
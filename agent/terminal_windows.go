@@ -0,0 +1,8 @@
+package main
+
+import "fmt"
+
+// openTerminal Windows ConPTY 구현 전까지는 지원하지 않는다.
+func openTerminal(cols, rows uint16) (terminal, error) {
+	return nil, fmt.Errorf("terminal sessions are not supported on windows yet (ConPTY)")
+}
@@ -1,99 +1,247 @@
 package main
 
 import (
+	"cmp"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopc-agent/config"
 )
 
 const AgentVersion = "1.0.1"
 
-type VersionResponse struct {
-	Version string `json:"version"`
+// UpdateManifest 서버가 제공하는 서명된 업데이트 정보
+type UpdateManifest struct {
+	Version   string `json:"version"`
+	File      string `json:"file"`
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size"`
+	Signature string `json:"signature"`
+}
+
+// UpdateStatus 업데이트 진행/실패 보고
+type UpdateStatus struct {
+	Version string `json:"version,omitempty"`
+	Status  string `json:"status"`          // "installed" | "failed"
+	Stage   string `json:"stage,omitempty"` // manifest, signature, download, checksum, install
+	Error   string `json:"error,omitempty"`
+}
+
+// manifestPayload 서명 대상 문자열 (서버의 동일 함수와 형식이 같아야 함)
+func manifestPayload(version, file, sum string, size int64) []byte {
+	return []byte(fmt.Sprintf("gopc-agent-update\n%s\n%s\n%s\n%d", version, file, sum, size))
 }
 
-func checkForUpdates(serverAddr string) {
+func checkForUpdates(conn *agentConn, cfg *config.Config) {
 	log.Println("Checking for updates...")
-	resp, err := http.Get(fmt.Sprintf("http://%s/version", serverAddr))
+	resp, err := http.Get(fmt.Sprintf("http://%s/updates/manifest", cfg.ServerAddress))
 	if err != nil {
 		log.Printf("Failed to check version: %v", err)
 		return
 	}
 	defer resp.Body.Close()
 
-	var versionResp VersionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&versionResp); err != nil {
-		log.Printf("Failed to decode version response: %v", err)
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Update manifest unavailable: %s", resp.Status)
+		return
+	}
+
+	var manifest UpdateManifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		log.Printf("Failed to decode update manifest: %v", err)
 		return
 	}
 
-	if versionResp.Version != AgentVersion {
-		log.Printf("New version available: %s (current: %s)", versionResp.Version, AgentVersion)
-		doUpdate(serverAddr)
-	} else {
+	// 서명된 예전 매니페스트를 다시 보내 구버전으로 내리는 것을 막기 위해 더 높은 버전만 설치한다
+	newer, err := compareVersions(manifest.Version, AgentVersion)
+	if err != nil {
+		log.Printf("Invalid update manifest version: %v", err)
+		reportUpdate(conn, UpdateStatus{Version: manifest.Version, Status: "failed", Stage: "manifest", Error: err.Error()})
+		return
+	}
+	if newer == 0 {
 		log.Println("Agent is up to date.")
+		return
+	}
+	if newer < 0 {
+		log.Printf("Refusing to downgrade to %s (current: %s)", manifest.Version, AgentVersion)
+		return
+	}
+
+	log.Printf("New version available: %s (current: %s)", manifest.Version, AgentVersion)
+	if stage, err := doUpdate(cfg, &manifest); err != nil {
+		log.Printf("Update failed at %s: %v", stage, err)
+		reportUpdate(conn, UpdateStatus{
+			Version: manifest.Version,
+			Status:  "failed",
+			Stage:   stage,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	reportUpdate(conn, UpdateStatus{Version: manifest.Version, Status: "installed"})
+	log.Println("Update downloaded and installed. Restarting service...")
+	// Service manager (Windows Service) should handle restart if we exit
+	// But simply exiting might be interpreted as failure.
+	// For now, we will just exit and let the service recovery options (if configured) or manual restart handle it.
+	// Ideally, we should trigger a service restart command.
+	os.Exit(0)
+}
+
+// parseVersion "1.2.3" 또는 "v1.2.3" 형식의 버전을 숫자 목록으로 바꾼다.
+func parseVersion(v string) ([]int, error) {
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// compareVersions a가 b보다 높으면 1, 같으면 0, 낮으면 -1 (빠진 자리는 0으로 봄, 1.2 == 1.2.0)
+func compareVersions(a, b string) (int, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < max(len(va), len(vb)); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if c := cmp.Compare(x, y); c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
+
+// verifyManifest 고정된 공개키로 매니페스트 서명을 검증한다.
+func verifyManifest(publicKey string, m *UpdateManifest) error {
+	if publicKey == "" {
+		return fmt.Errorf("no update public key pinned in config")
+	}
+	pub, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid pinned public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
 	}
+	if !ed25519.Verify(pub, manifestPayload(m.Version, m.File, m.SHA256, m.Size), sig) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
 }
 
-func doUpdate(serverAddr string) {
+// doUpdate 업데이트를 받아 검증 후 실행 파일을 교체한다.
+// 실패 시 실패한 단계 이름과 오류를 반환한다.
+func doUpdate(cfg *config.Config, m *UpdateManifest) (string, error) {
 	log.Println("Starting update process...")
 
+	// 0. Verify manifest signature before touching anything
+	if err := verifyManifest(cfg.UpdatePublicKey, m); err != nil {
+		return "signature", err
+	}
+	if m.Size <= 0 {
+		return "manifest", fmt.Errorf("invalid size %d", m.Size)
+	}
+
 	// 1. Download new executable
-	resp, err := http.Get(fmt.Sprintf("http://%s/updates/agent.exe", serverAddr))
+	resp, err := http.Get(fmt.Sprintf("http://%s/updates/%s", cfg.ServerAddress, m.File))
 	if err != nil {
-		log.Printf("Failed to download update: %v", err)
-		return
+		return "download", err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "download", fmt.Errorf("unexpected status %s", resp.Status)
+	}
 
 	exePath, err := os.Executable()
 	if err != nil {
-		log.Printf("Failed to get executable path: %v", err)
-		return
+		return "install", fmt.Errorf("get executable path: %v", err)
 	}
 
 	newExePath := exePath + ".new"
 	out, err := os.Create(newExePath)
 	if err != nil {
-		log.Printf("Failed to create new executable file: %v", err)
-		return
+		return "download", fmt.Errorf("create new executable file: %v", err)
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	// 매니페스트 크기보다 큰 응답은 읽지 않는다
+	h := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, h), io.LimitReader(resp.Body, m.Size+1))
 	if err != nil {
-		log.Printf("Failed to write new executable file: %v", err)
-		return
+		os.Remove(newExePath)
+		return "download", fmt.Errorf("write new executable file: %v", err)
 	}
 	out.Close() // Ensure file is closed before renaming
 
-	// 2. Rename current executable to .old
+	// 2. Verify size and checksum
+	if written != m.Size {
+		os.Remove(newExePath)
+		return "checksum", fmt.Errorf("size mismatch: got %d, want %d", written, m.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != m.SHA256 {
+		os.Remove(newExePath)
+		return "checksum", fmt.Errorf("sha256 mismatch: got %s, want %s", sum, m.SHA256)
+	}
+	log.Printf("Update %s verified (sha256=%s)", m.Version, m.SHA256)
+
+	// 3. Rename current executable to .old
 	oldExePath := exePath + ".old"
 	// Remove old backup if exists
 	os.Remove(oldExePath)
 
 	err = os.Rename(exePath, oldExePath)
 	if err != nil {
-		log.Printf("Failed to rename current executable: %v", err)
-		return
+		os.Remove(newExePath)
+		return "install", fmt.Errorf("rename current executable: %v", err)
 	}
 
-	// 3. Rename new executable to current name
+	// 4. Rename new executable to current name
 	err = os.Rename(newExePath, exePath)
 	if err != nil {
-		log.Printf("Failed to rename new executable: %v", err)
 		// Try to rollback
 		os.Rename(oldExePath, exePath)
-		return
+		return "install", fmt.Errorf("rename new executable: %v", err)
 	}
 
-	log.Println("Update downloaded and installed. Restarting service...")
-	// Service manager (Windows Service) should handle restart if we exit
-	// But simply exiting might be interpreted as failure.
-	// For now, we will just exit and let the service recovery options (if configured) or manual restart handle it.
-	// Ideally, we should trigger a service restart command.
-	os.Exit(0)
+	return "", nil
+}
+
+func reportUpdate(conn *agentConn, status UpdateStatus) {
+	msg := map[string]interface{}{
+		"type":      "update_status",
+		"update":    status,
+		"timestamp": time.Now(),
+	}
+	if err := conn.WriteJSON(msg); err != nil {
+		log.Printf("Failed to report update status: %v", err)
+	}
 }
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.2", "1.0.1", 1},
+		{"1.0.1", "1.0.1", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.10.0", "1.9.9", 1},
+		{"v2.0", "1.99.99", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.2.0.1", "1.2", 1},
+	}
+	for _, tt := range tests {
+		got, err := compareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("compareVersions(%q, %q): %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "1.x", "1..2", "1.-1", "1.0.0-beta"} {
+		if _, err := compareVersions(bad, "1.0.0"); err == nil {
+			t.Errorf("compareVersions(%q) accepted an invalid version", bad)
+		}
+	}
+}
@@ -6,7 +6,7 @@ echo ==========================================
 echo.
 echo [1/4] Building Agent...
 cd agent
-go build -o agent.exe main.go session_windows.go update.go
+go build -o agent.exe .
 if %ERRORLEVEL% NEQ 0 (
     echo Agent build failed!
     pause
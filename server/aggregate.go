@@ -0,0 +1,272 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// 묶음에 담는 대표 출력 최대 길이 (바이트)
+	maxBucketOutput = 4096
+	// 출력 비교에 쓰는 최대 줄 수 (앞뒤 공통 줄을 뺀 나머지)
+	maxDiffLines = 1000
+)
+
+// BucketMember 묶음에 속한 대상
+type BucketMember struct {
+	AgentID  string `json:"agent_id"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// ResultBucket 정규화한 출력, 종료 코드, 상태가 같은 대상 묶음
+type ResultBucket struct {
+	Key       string         `json:"key"`
+	State     string         `json:"state"`
+	ExitCode  *int           `json:"exit_code,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Output    string         `json:"output"`              // 정규화한 출력 (maxBucketOutput까지)
+	Truncated bool           `json:"truncated,omitempty"` // 출력이 잘림 (에이전트 또는 묶음 표시 한도)
+	Count     int            `json:"count"`
+	Members   []BucketMember `json:"members"`
+}
+
+// normalizedResult 대상 하나의 비교용 결과
+type normalizedResult struct {
+	state     string
+	exitCode  *int
+	errText   string
+	output    string
+	truncated bool
+}
+
+// key 같은 결과끼리 같은 값
+func (n *normalizedResult) key() string {
+	exit := "-"
+	if n.exitCode != nil {
+		exit = fmt.Sprint(*n.exitCode)
+	}
+	sum := sha256.Sum256([]byte(n.state + "\x00" + exit + "\x00" + n.errText + "\x00" + n.output))
+	return hex.EncodeToString(sum[:6])
+}
+
+// normalizeResult 대상 결과를 비교할 수 있게 정리한다.
+// 줄바꿈(CRLF)과 줄 끝 공백, 끝의 빈 줄을 무시하고 PC마다 다른 호스트 이름은 <hostname>으로 바꾼다.
+func normalizeResult(t *JobTarget) *normalizedResult {
+	n := &normalizedResult{state: t.State, errText: t.Error}
+	if t.Result == nil {
+		return n
+	}
+	if code, ok := t.Result["exit_code"].(float64); ok {
+		c := int(code)
+		n.exitCode = &c
+	}
+	n.truncated, _ = t.Result["truncated"].(bool)
+	n.output = normalizeOutput(targetOutput(t), t.Hostname)
+	n.errText = normalizeOutput(n.errText, t.Hostname)
+	return n
+}
+
+func normalizeOutput(s, hostname string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	s = strings.Join(lines, "\n")
+	if len(hostname) >= 2 {
+		s = regexp.MustCompile(`(?i)`+regexp.QuoteMeta(hostname)).ReplaceAllString(s, "<hostname>")
+	}
+	return s
+}
+
+// aggregateJob 작업 결과를 묶음으로 나눈다 (대상이 많은 묶음부터)
+func aggregateJob(job *Job) []*ResultBucket {
+	byKey := map[string]*ResultBucket{}
+	var buckets []*ResultBucket
+	for _, t := range job.Targets {
+		n := normalizeResult(t)
+		key := n.key()
+		b, ok := byKey[key]
+		if !ok {
+			b = &ResultBucket{
+				Key:       key,
+				State:     n.state,
+				ExitCode:  n.exitCode,
+				Error:     n.errText,
+				Output:    n.output,
+				Truncated: n.truncated,
+			}
+			if len(b.Output) > maxBucketOutput {
+				b.Output = b.Output[:maxBucketOutput]
+				b.Truncated = true
+			}
+			byKey[key] = b
+			buckets = append(buckets, b)
+		}
+		b.Count++
+		b.Members = append(b.Members, BucketMember{AgentID: t.AgentID, Hostname: t.Hostname})
+	}
+	for _, b := range buckets {
+		sort.Slice(b.Members, func(i, j int) bool { return b.Members[i].Hostname < b.Members[j].Hostname })
+	}
+	sort.SliceStable(buckets, func(i, j int) bool { return buckets[i].Count > buckets[j].Count })
+	return buckets
+}
+
+// DiffLine 출력 비교 한 줄. op: " " 같음, "-" 기준 묶음에만 있음, "+" 대상에만 있음
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// diffLines 줄 단위 최장 공통 부분열 비교. 앞뒤 공통 줄을 먼저 떼어 내고, 남은 줄이 너무 많으면 잘라서 비교한다.
+func diffLines(a, b []string) (lines []DiffLine, truncated bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		lines = append(lines, DiffLine{Op: " ", Text: line})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA) > maxDiffLines {
+		midA, truncated = midA[:maxDiffLines], true
+	}
+	if len(midB) > maxDiffLines {
+		midB, truncated = midB[:maxDiffLines], true
+	}
+
+	// lcs[i][j]: midA[i:]와 midB[j:]의 최장 공통 부분열 길이
+	lcs := make([][]int32, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			lines = append(lines, DiffLine{Op: " ", Text: midA[i]})
+			i++
+			j++
+		case i < len(midA) && (j == len(midB) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, DiffLine{Op: "-", Text: midA[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: "+", Text: midB[j]})
+			j++
+		}
+	}
+	if !truncated {
+		for _, line := range a[len(a)-suffix:] {
+			lines = append(lines, DiffLine{Op: " ", Text: line})
+		}
+	}
+	return lines, truncated
+}
+
+// splitOutput 비교용 줄 목록 (오류 메시지가 있으면 앞에 붙인다)
+func splitOutput(n *normalizedResult) []string {
+	text := n.output
+	if n.errText != "" {
+		text = "[error] " + n.errText + "\n" + text
+	}
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// handleJobBuckets GET /api/jobs/{id}/buckets
+func handleJobBuckets(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"job_id":  job.ID,
+		"total":   len(job.Targets),
+		"buckets": aggregateJob(job),
+	})
+}
+
+// handleJobDiff GET /api/jobs/{id}/diff?agent=<에이전트 ID 또는 호스트 이름>&bucket=<묶음 키>
+// 대상의 출력을 묶음(기본값: 대상이 가장 많은 묶음)의 대표 출력과 비교한다.
+func handleJobDiff(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	agent := q.Get("agent")
+	var target *JobTarget
+	for _, t := range job.Targets {
+		if agent != "" && (t.AgentID == agent || strings.EqualFold(t.Hostname, agent)) {
+			target = t
+			break
+		}
+	}
+	if target == nil {
+		http.Error(w, "target not found", http.StatusNotFound)
+		return
+	}
+
+	buckets := aggregateJob(job)
+	var base *ResultBucket
+	for _, b := range buckets {
+		if q.Get("bucket") == "" || b.Key == q.Get("bucket") {
+			base = b
+			break
+		}
+	}
+	if base == nil {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return
+	}
+	// 묶음의 첫 대상을 기준으로 잘리지 않은 정규화 출력을 비교한다
+	var reference *normalizedResult
+	for _, t := range job.Targets {
+		if t.AgentID == base.Members[0].AgentID && t.Hostname == base.Members[0].Hostname {
+			reference = normalizeResult(t)
+			break
+		}
+	}
+	mine := normalizeResult(target)
+	lines, truncated := diffLines(splitOutput(reference), splitOutput(mine))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"agent_id":  target.AgentID,
+		"hostname":  target.Hostname,
+		"bucket":    base.Key,
+		"same":      mine.key() == base.Key,
+		"lines":     lines,
+		"truncated": truncated,
+	})
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gopc-server/config"
+)
+
+// 승인 대기 명령 상태
+const (
+	approvalPending  = "pending"
+	approvalApproved = "approved"
+	approvalRejected = "rejected"
+	approvalExpired  = "expired"
+)
+
+// PendingCommand 두 번째 사용자의 승인을 기다리는 위험 명령
+type PendingCommand struct {
+	ID          string         `json:"id"`
+	Request     CommandRequest `json:"request"`
+	Targets     []string       `json:"targets"` // 요청 시점의 대상 에이전트 ID
+	Reasons     []string       `json:"reasons"` // 승인이 필요한 이유
+	RequestedBy string         `json:"requested_by"`
+	ScheduleID  string         `json:"schedule_id,omitempty"`  // 예약 실행이면 예약 ID
+	ScheduledBy string         `json:"scheduled_by,omitempty"` // 예약을 마지막으로 저장한 사용자 (승인할 수 없음)
+	RequestedAt time.Time      `json:"requested_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+	Status      string         `json:"status"`
+	DecidedBy   string         `json:"decided_by,omitempty"`
+	DecidedAt   time.Time      `json:"decided_at,omitempty"`
+}
+
+// approvalManager 위험 명령 규칙 평가 및 승인 대기 목록 관리
+type approvalManager struct {
+	mu       sync.Mutex
+	patterns []*regexp.Regexp
+	sources  []string // patterns에 대응하는 원본 패턴 (사유 표시용)
+	rules    config.ApprovalConfig
+	timeout  time.Duration
+	pending  map[string]*PendingCommand
+}
+
+func newApprovalManager(cfg *config.Config) *approvalManager {
+	m := &approvalManager{
+		rules:   cfg.Approval,
+		timeout: cfg.GetApprovalTimeout(),
+		pending: make(map[string]*PendingCommand),
+	}
+	for _, p := range cfg.Approval.Patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			log.Printf("승인 규칙: 잘못된 패턴 %q 무시: %v", p, err)
+			continue
+		}
+		m.patterns = append(m.patterns, re)
+		m.sources = append(m.sources, p)
+	}
+
+	approvers := 0
+	for _, u := range cfg.DashboardUsers {
+		if u.CanApprove {
+			approvers++
+		}
+	}
+	if approvers == 0 && (len(m.patterns) > 0 || m.rules.MaxTargets > 0 || len(m.rules.Groups) > 0) {
+		log.Printf("승인 규칙: 승인 권한이 있는 대시보드 사용자가 없어 위험 명령은 모두 만료됩니다")
+	}
+	return m
+}
+
+// Check 명령이 승인 대상인지 평가하고 해당 사유를 반환한다.
+// agentsMutex를 잡은 상태에서 호출해야 한다.
+func (m *approvalManager) Check(req CommandRequest, targets []*Agent) []string {
+	var reasons []string
+	// 꺼져 있어 보류할 대상도 센다
+	held := inventory.Offline(req)
+
+	text := req.Command
+	if req.Script != nil {
+		// 스크립트는 모든 OS 변형의 본문까지 검사
+		text += "\n" + scripts.Text(req.Script)
+	}
+	if req.Playbook != nil {
+		// 플레이북은 모든 단계의 명령(대상마다 변수를 치환한 결과)과 스크립트 본문까지 검사
+		text += "\n" + playbooks.Text(req.Playbook, targets, held)
+	}
+	for i, re := range m.patterns {
+		if re.MatchString(text) {
+			reasons = append(reasons, fmt.Sprintf("명령이 위험 패턴 %q 에 해당", m.sources[i]))
+			break
+		}
+	}
+
+	total := len(targets) + len(held)
+	if m.rules.MaxTargets > 0 && total > m.rules.MaxTargets {
+		reasons = append(reasons, fmt.Sprintf("대상 에이전트 %d대 (기준 %d대 초과)", total, m.rules.MaxTargets))
+	}
+
+	if len(m.rules.Groups) > 0 {
+		groups := make([]string, 0, total)
+		for _, agent := range targets {
+			if agent.Info != nil {
+				groups = append(groups, agent.Info.Group)
+			}
+		}
+		for _, info := range held {
+			groups = append(groups, info.Group)
+		}
+		hit := map[string]bool{}
+		for _, group := range groups {
+			for _, g := range m.rules.Groups {
+				if group == g {
+					hit[g] = true
+				}
+			}
+		}
+		for g := range hit {
+			reasons = append(reasons, fmt.Sprintf("보호 그룹 %q 포함", g))
+		}
+	}
+
+	sort.Strings(reasons)
+	return reasons
+}
+
+func (m *approvalManager) newPending(req CommandRequest, targets []string, reasons []string, user string) *PendingCommand {
+	now := time.Now()
+	return &PendingCommand{
+		ID:          newID(),
+		Request:     req,
+		Targets:     targets,
+		Reasons:     reasons,
+		RequestedBy: user,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(m.timeout),
+		Status:      approvalPending,
+	}
+}
+
+// Submit 승인 대기 명령을 등록한다.
+func (m *approvalManager) Submit(req CommandRequest, targets []string, reasons []string, user string) *PendingCommand {
+	p := m.newPending(req, targets, reasons, user)
+
+	m.mu.Lock()
+	m.pending[p.ID] = p
+	m.mu.Unlock()
+	return p
+}
+
+// SubmitScheduled 위험 명령 규칙에 해당하는 예약 실행을 승인 대기로 등록한다.
+// 같은 예약의 이전 실행이 아직 승인을 기다리고 있으면 새로 등록하지 않고 그 요청과 false를 반환한다.
+func (m *approvalManager) SubmitScheduled(item *Schedule, targets []string, reasons []string) (*PendingCommand, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.pending {
+		if p.ScheduleID == item.ID {
+			return p, false
+		}
+	}
+	p := m.newPending(item.Request, targets, reasons, "schedule:"+item.Name)
+	p.ScheduleID = item.ID
+	p.ScheduledBy = item.UpdatedBy
+	m.pending[p.ID] = p
+	return p, true
+}
+
+// Decide 승인 또는 거절을 처리한다. 요청자 본인이나 승인 권한이 없는 사용자는 결정할 수 없다.
+func (m *approvalManager) Decide(id string, user *config.DashboardUser, approve bool) (*PendingCommand, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pending[id]
+	if !ok {
+		return nil, fmt.Errorf("승인 요청 %s 을 찾을 수 없습니다", id)
+	}
+	if approve {
+		if !user.CanApprove {
+			return nil, fmt.Errorf("%s 사용자는 승인 권한이 없습니다", user.Name)
+		}
+		if user.Name == p.RequestedBy {
+			return nil, fmt.Errorf("요청자 본인은 승인할 수 없습니다")
+		}
+		if p.ScheduledBy != "" && user.Name == p.ScheduledBy {
+			return nil, fmt.Errorf("예약을 저장한 사용자는 그 실행을 승인할 수 없습니다")
+		}
+	} else if !user.CanApprove && user.Name != p.RequestedBy {
+		// 거절(취소)은 요청자 본인 또는 승인 권한자만 가능
+		return nil, fmt.Errorf("%s 사용자는 거절 권한이 없습니다", user.Name)
+	}
+	if time.Now().After(p.ExpiresAt) {
+		return nil, fmt.Errorf("승인 요청이 만료되었습니다")
+	}
+
+	p.Status = approvalRejected
+	if approve {
+		p.Status = approvalApproved
+	}
+	p.DecidedBy = user.Name
+	p.DecidedAt = time.Now()
+	delete(m.pending, id)
+	return p, nil
+}
+
+// List 현재 대기 중인 승인 요청 목록
+func (m *approvalManager) List() []*PendingCommand {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]*PendingCommand, 0, len(m.pending))
+	for _, p := range m.pending {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RequestedAt.Before(list[j].RequestedAt) })
+	return list
+}
+
+// expire 만료된 요청을 목록에서 제거하고 반환한다.
+func (m *approvalManager) expire(now time.Time) []*PendingCommand {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []*PendingCommand
+	for id, p := range m.pending {
+		if now.After(p.ExpiresAt) {
+			p.Status = approvalExpired
+			p.DecidedAt = now
+			delete(m.pending, id)
+			expired = append(expired, p)
+		}
+	}
+	return expired
+}
+
+// runExpiry 주기적으로 만료된 승인 요청을 정리하고 대시보드에 알린다.
+func (m *approvalManager) runExpiry() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, p := range m.expire(now) {
+			audit.Record("command_approval_expired", p.RequestedBy, map[string]interface{}{
+				"approval_id": p.ID,
+				"command":     p.Request.Command,
+			})
+			broadcastApprovalUpdate(p)
+		}
+	}
+}
+
+func broadcastApprovalUpdate(p *PendingCommand) {
+	broadcastToDashboards(map[string]interface{}{
+		"type":     "approval_update",
+		"approval": p,
+	})
+}
+
+// handleApprovalDecision 대시보드의 approve/reject 메시지 처리
+func handleApprovalDecision(ws *websocket.Conn, msg map[string]interface{}, user *config.DashboardUser, approve bool) {
+	id, _ := msg["approval_id"].(string)
+	p, err := approvals.Decide(id, user, approve)
+	if err != nil {
+		sendDashboardError(ws, err.Error())
+		return
+	}
+
+	fields := map[string]interface{}{
+		"approval_id":  p.ID,
+		"command":      p.Request.Command,
+		"targets":      p.Targets,
+		"requested_by": p.RequestedBy,
+	}
+	if !approve {
+		audit.Record("command_rejected", user.Name, fields)
+		broadcastApprovalUpdate(p)
+		return
+	}
+
+	audit.Record("command_approved", user.Name, fields)
+	broadcastApprovalUpdate(p)
+
+	agentsMutex.Lock()
+	ids := make(map[string]bool, len(p.Targets))
+	for _, id := range p.Targets {
+		ids[id] = true
+	}
+	var targets []*Agent
+	for _, agent := range agents {
+		if ids[agent.ID] {
+			targets = append(targets, agent)
+		}
+	}
+	subscribers := append(dashboardsOf(p.RequestedBy), ws)
+	commandID := sendCommandToAgents(targets, p.Request, p.RequestedBy, user.Name, subscribers...)
+	audit.Record("command_sent", p.RequestedBy, map[string]interface{}{
+		"command_id":  commandID,
+		"approval_id": p.ID,
+		"approved_by": user.Name,
+		"command":     p.Request.Command,
+		"targets":     agentIDs(targets),
+	})
+	agentsMutex.Unlock()
+
+	if p.ScheduleID != "" {
+		schedules.recordRun(p.ScheduleID, p.DecidedAt, commandID, "")
+	}
+}
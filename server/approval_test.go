@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gopc-server/config"
+)
+
+func TestSubmitScheduledNeedsDifferentApprover(t *testing.T) {
+	m := &approvalManager{timeout: time.Hour, pending: make(map[string]*PendingCommand)}
+	item := &Schedule{ID: "s1", Name: "nightly", UpdatedBy: "alice", Request: CommandRequest{Command: "shutdown -h now"}}
+
+	p, added := m.SubmitScheduled(item, []string{"a1"}, []string{"reason"})
+	if !added || p.RequestedBy != "schedule:nightly" || p.ScheduledBy != "alice" {
+		t.Fatalf("submitted %+v, added = %v", p, added)
+	}
+	// 이전 실행이 승인 대기 중이면 새로 쌓지 않는다
+	if again, added := m.SubmitScheduled(item, []string{"a1"}, []string{"reason"}); added || again.ID != p.ID {
+		t.Errorf("second run added = %v, id = %s", added, again.ID)
+	}
+
+	if _, err := m.Decide(p.ID, &config.DashboardUser{Name: "alice", CanApprove: true}, true); err == nil {
+		t.Error("schedule author approved its own run")
+	}
+	decided, err := m.Decide(p.ID, &config.DashboardUser{Name: "bob", CanApprove: true}, true)
+	if err != nil || decided.Status != approvalApproved {
+		t.Fatalf("bob approve: %v", err)
+	}
+	if _, added := m.SubmitScheduled(item, nil, []string{"reason"}); !added {
+		t.Error("next run not submitted after the previous one was decided")
+	}
+}
+
+func TestCheckPlaybookExpandsVars(t *testing.T) {
+	saved := playbooks
+	playbooks = newPlaybookLibrary(t.TempDir())
+	t.Cleanup(func() { playbooks = saved })
+	if _, err := playbooks.Save([]byte(`name: cleanup
+vars:
+  action: echo
+steps:
+  - name: run
+    shell: "{{action}} done on {{labels.room}}"
+`)); err != nil {
+		t.Fatal(err)
+	}
+	m := newApprovalManager(&config.Config{Approval: config.ApprovalConfig{Patterns: []string{`shutdown\s+-h`, `lab-9`}}})
+	targets := []*Agent{{ID: "a1", Info: &AgentInfo{Hostname: "pc-01", Labels: map[string]string{"room": "lab-1"}}}}
+
+	check := func(vars map[string]string, targets []*Agent) []string {
+		req := CommandRequest{Playbook: &PlaybookRun{Name: "cleanup", Vars: vars}}
+		if err := playbooks.Resolve(req.Playbook); err != nil {
+			t.Fatal(err)
+		}
+		return m.Check(req, targets)
+	}
+	if reasons := check(nil, targets); len(reasons) != 0 {
+		t.Errorf("default vars flagged: %v", reasons)
+	}
+	// 실행 시 값으로 위험 명령을 넣는다
+	if reasons := check(map[string]string{"action": "shutdown -h now;"}, targets); len(reasons) != 1 {
+		t.Errorf("expanded command not flagged: %v", reasons)
+	}
+	if reasons := check(map[string]string{"action": "shutdown -h now;"}, nil); len(reasons) != 1 {
+		t.Errorf("expanded command without targets not flagged: %v", reasons)
+	}
+	// 대상 에이전트의 라벨 값도 치환해 검사한다
+	targets[0].Info.Labels["room"] = "lab-9"
+	if reasons := check(nil, targets); len(reasons) != 1 {
+		t.Errorf("label value not flagged: %v", reasons)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry 감사 로그 한 줄
+type AuditEntry struct {
+	Time   time.Time              `json:"time"`
+	Event  string                 `json:"event"`
+	User   string                 `json:"user,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// auditLog 감사 로그를 JSON lines 형식으로 파일에 추가한다.
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newAuditLog(path string) *auditLog {
+	return &auditLog{path: path}
+}
+
+// Record 이벤트를 기록한다. 파일 오류는 로그만 남긴다.
+func (a *auditLog) Record(event, user string, fields map[string]interface{}) {
+	entry := AuditEntry{
+		Time:   time.Now(),
+		Event:  event,
+		User:   user,
+		Fields: fields,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("audit: marshal error: %v", err)
+		return
+	}
+	log.Printf("audit: %s", data)
+
+	if a == nil || a.path == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("audit: open error: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"gopc-server/config"
+)
+
+// 사용자가 설정되지 않았을 때 사용하는 익명 사용자
+var anonymousUser = &config.DashboardUser{Name: "anonymous", Role: "admin"}
+
+// authenticateDashboard 요청의 user/token 쿼리로 대시보드 사용자를 확인한다.
+// 설정에 사용자가 없으면 익명 사용자로 허용한다.
+func authenticateDashboard(r *http.Request, users []config.DashboardUser) (*config.DashboardUser, bool) {
+	if len(users) == 0 {
+		return anonymousUser, true
+	}
+
+	name := r.URL.Query().Get("user")
+	token := r.URL.Query().Get("token")
+	if name == "" || token == "" {
+		return nil, false
+	}
+
+	for i := range users {
+		u := &users[i]
+		if u.Name == name && subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// requireDashboardUser HTTP API 요청의 사용자를 확인하고, 실패하면 401을 응답한다.
+func requireDashboardUser(w http.ResponseWriter, r *http.Request) (*config.DashboardUser, bool) {
+	user, ok := authenticateDashboard(r, dashboardUsers)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}
+	return user, ok
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +16,47 @@ type Config struct {
 	UpdatesDir   string `yaml:"updates_dir"`   // 업데이트 파일 디렉토리
 	AgentVersion string `yaml:"agent_version"` // 현재 에이전트 버전
 	AuthToken    string `yaml:"auth_token"`    // 인증 토큰 (보안)
+
+	UpdateKeyFile string `yaml:"update_key_file"` // 업데이트 서명용 Ed25519 개인키 파일
+
+	DashboardUsers []DashboardUser `yaml:"dashboard_users"` // 대시보드 사용자 (비어 있으면 인증 없음)
+	Approval       ApprovalConfig  `yaml:"approval"`        // 위험 명령 승인 규칙
+	AuditFile      string          `yaml:"audit_file"`      // 감사 로그 파일 (JSON lines)
+	Terminal       TerminalConfig  `yaml:"terminal"`        // 원격 터미널 세션
+	DataDir        string          `yaml:"data_dir"`        // 스크립트 라이브러리 등 서버 데이터 저장 디렉토리
+	JobRetention   int             `yaml:"job_retention"`   // 작업 기록 보관 기간 (일, 0 = 계속 보관)
+	Metrics        MetricsConfig   `yaml:"metrics"`         // 상태 기록 보관 기간
+}
+
+// MetricsConfig 에이전트 상태 시계열의 해상도별 보관 기간
+type MetricsConfig struct {
+	RawRetention    int `yaml:"raw_retention"`    // 보고마다 남기는 원본 기록 (시간, 메모리에만 보관, 0 = 남기지 않음)
+	MinuteRetention int `yaml:"minute_retention"` // 1분 평균 (일, 0 = 계속 보관)
+	HourRetention   int `yaml:"hour_retention"`   // 1시간 평균 (일, 0 = 계속 보관)
+}
+
+// TerminalConfig 원격 터미널(pty) 세션 설정
+type TerminalConfig struct {
+	MaxSessionsPerAgent int `yaml:"max_sessions_per_agent"` // 에이전트당 동시 세션 수 (0 = 제한 없음)
+	IdleTimeout         int `yaml:"idle_timeout"`           // 입출력이 없으면 종료 (초, 0 = 사용 안 함)
+}
+
+// DashboardUser 대시보드 접속 사용자
+type DashboardUser struct {
+	Name       string `yaml:"name" json:"name"`
+	Token      string `yaml:"token" json:"-"`
+	Role       string `yaml:"role" json:"role"`               // admin | operator
+	CanApprove bool   `yaml:"can_approve" json:"can_approve"` // 위험 명령 승인 권한
+	// 명령 템플릿으로만 명령을 실행할 수 있음 (자유 입력, 스크립트, 플레이북 불가)
+	TemplatesOnly bool `yaml:"templates_only" json:"templates_only"`
+}
+
+// ApprovalConfig 2인 승인이 필요한 "위험 명령" 규칙
+type ApprovalConfig struct {
+	Timeout    int      `yaml:"timeout"`     // 승인 대기 시간 (초)
+	Patterns   []string `yaml:"patterns"`    // 명령 정규식 (대소문자 무시)
+	MaxTargets int      `yaml:"max_targets"` // 대상 수가 이 값을 넘으면 승인 필요 (0 = 사용 안 함)
+	Groups     []string `yaml:"groups"`      // 이 그룹의 에이전트가 대상에 포함되면 승인 필요
 }
 
 // DefaultConfig 기본 설정값 반환
@@ -24,6 +66,24 @@ func DefaultConfig() *Config {
 		StaticDir:    "static",
 		UpdatesDir:   "updates",
 		AgentVersion: "1.0.1",
+
+		UpdateKeyFile: "update_signing.key",
+
+		Approval: ApprovalConfig{
+			Timeout: 300,
+		},
+		AuditFile:    "audit.log",
+		DataDir:      "data",
+		JobRetention: 30,
+		Terminal: TerminalConfig{
+			MaxSessionsPerAgent: 2,
+			IdleTimeout:         600,
+		},
+		Metrics: MetricsConfig{
+			RawRetention:    1,
+			MinuteRetention: 7,
+			HourRetention:   90,
+		},
 	}
 }
 
@@ -62,6 +122,28 @@ func Load() *Config {
 	return cfg
 }
 
+// GetApprovalTimeout 승인 대기 시간을 time.Duration으로 반환
+func (c *Config) GetApprovalTimeout() time.Duration {
+	return time.Duration(c.Approval.Timeout) * time.Second
+}
+
+// GetTerminalIdleTimeout 터미널 유휴 시간을 time.Duration으로 반환
+func (c *Config) GetTerminalIdleTimeout() time.Duration {
+	return time.Duration(c.Terminal.IdleTimeout) * time.Second
+}
+
+// GetJobRetention 작업 기록 보관 기간을 time.Duration으로 반환
+func (c *Config) GetJobRetention() time.Duration {
+	return time.Duration(c.JobRetention) * 24 * time.Hour
+}
+
+// GetMetricsRetention 해상도별 상태 기록 보관 기간 (원본, 1분, 1시간)
+func (c *Config) GetMetricsRetention() (raw, minute, hour time.Duration) {
+	return time.Duration(c.Metrics.RawRetention) * time.Hour,
+		time.Duration(c.Metrics.MinuteRetention) * 24 * time.Hour,
+		time.Duration(c.Metrics.HourRetention) * 24 * time.Hour
+}
+
 // GetListenAddr 서버 리스닝 주소 반환
 func (c *Config) GetListenAddr() string {
 	return ":" + c.Port
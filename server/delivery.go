@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// 재시도 대기 기본값과 상한
+	defaultRetryBackoff = 30 * time.Second
+	maxRetryBackoff     = time.Hour
+	maxRetryAttempts    = 10
+	// 오프라인 대상을 보류할 수 있는 최대 기간 (초)
+	maxDeliverWithin = 7 * 24 * 3600
+)
+
+// RetryPolicy 실패한 대상에 명령을 다시 보내는 방법
+type RetryPolicy struct {
+	MaxAttempts int `json:"max_attempts"`      // 첫 전송을 포함한 최대 시도 횟수 (1-10)
+	Backoff     int `json:"backoff,omitempty"` // 첫 재시도까지 대기 시간 (초, 기본 30). 재시도마다 두 배, 최대 1시간
+}
+
+func (p *RetryPolicy) validate() error {
+	switch {
+	case p.MaxAttempts < 1 || p.MaxAttempts > maxRetryAttempts:
+		return fmt.Errorf("retry.max_attempts는 1-%d 사이여야 합니다", maxRetryAttempts)
+	case p.Backoff < 0:
+		return fmt.Errorf("retry.backoff는 0 이상이어야 합니다")
+	}
+	return nil
+}
+
+// delay attempts번 시도한 뒤 다음 재시도까지 기다릴 시간
+func (p *RetryPolicy) delay(attempts int) time.Duration {
+	d := defaultRetryBackoff
+	if p.Backoff > 0 {
+		d = time.Duration(p.Backoff) * time.Second
+	}
+	for i := 1; i < attempts && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
+}
+
+// deliverHeld 다시 연결된 에이전트에 보류 중인 명령을 보낸다 (agentsMutex를 잡은 상태에서 호출)
+func deliverHeld(agent *Agent) {
+	for _, job := range jobs.Claim(agent) {
+		if maintenanceDeferred(job.Request) && deferToWindow(job.ID, agent, time.Now()) {
+			continue
+		}
+		log.Printf("보류 중인 작업 %s 를 %s 에 전송", job.ID, agent.ID)
+		startDelivery(agent, job)
+	}
+}
+
+// deliverDue 재시도 시각이나 정비 시간이 된 대상에 보낸다. 연결되어 있지 않으면 보류하거나 offline으로 끝내고,
+// 그 사이 정비 시간이 바뀌어 닫혀 있으면 다음 정비 시간까지 다시 기다린다.
+func deliverDue(due []*dueTarget) {
+	agentsMutex.Lock()
+	defer agentsMutex.Unlock()
+
+	for _, rt := range due {
+		agent := findAgent(rt.agentID, rt.macAddr, rt.hostname)
+		if agent == nil {
+			jobs.Offline(rt.job.ID, rt.agentID, "agent not connected at the scheduled delivery time")
+			continue
+		}
+		if maintenanceDeferred(rt.job.Request) && deferToWindow(rt.job.ID, agent, time.Now()) {
+			continue
+		}
+		if !jobs.Release(rt.job.ID, rt.agentID, agent) {
+			continue
+		}
+		log.Printf("작업 %s 를 %s 에 다시 전송", rt.job.ID, agent.ID)
+		startDelivery(agent, rt.job)
+	}
+}
+
+// startDelivery 작업 대상 한 대에 명령 또는 플레이북을 보낸다 (agentsMutex를 잡은 상태에서 호출)
+func startDelivery(agent *Agent, job *Job) {
+	if job.Request.Playbook != nil {
+		playbookRuns.Start(job.ID, job.Request, []*Agent{agent}, job.RequestedBy, job.ApprovedBy)
+		return
+	}
+	dispatchCommand(agent, job.ID, job.Request, commandMessage(job.ID, job.Request, job.RequestedBy, job.ApprovedBy))
+}
+
+// findAgent 연결된 에이전트를 ID, MAC 주소, 호스트 이름 순으로 찾는다 (agentsMutex를 잡은 상태에서 호출)
+func findAgent(agentID, macAddr, hostname string) *Agent {
+	if agent := agentByID(agentID); agent != nil {
+		return agent
+	}
+	for _, agent := range agents {
+		if agent.Info == nil {
+			continue
+		}
+		if macAddr != "" && agent.Info.MacAddr == macAddr {
+			return agent
+		}
+		if macAddr == "" && hostname != "" && agent.Info.Hostname == hostname {
+			return agent
+		}
+	}
+	return nil
+}
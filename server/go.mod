@@ -4,4 +4,4 @@ go 1.24.3
 
 require github.com/gorilla/websocket v1.5.3
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
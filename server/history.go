@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// 히스토리 한 페이지 기본/최대 항목 수
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+	// 목록 응답에 담는 출력 최대 길이 (바이트, 내보내기는 전체)
+	maxHistoryOutput = 4096
+	// 한 번에 내보낼 수 있는 최대 항목 수
+	maxHistoryExport = 100000
+)
+
+// HistoryEntry 명령 히스토리 한 줄 (작업 대상 하나)
+type HistoryEntry struct {
+	JobID           string     `json:"job_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	Command         string     `json:"command"`
+	RequestedBy     string     `json:"requested_by"`
+	ApprovedBy      string     `json:"approved_by,omitempty"`
+	AgentID         string     `json:"agent_id,omitempty"`
+	Hostname        string     `json:"hostname,omitempty"`
+	Group           string     `json:"group,omitempty"`
+	State           string     `json:"state"`
+	ExitCode        *int       `json:"exit_code,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	Output          string     `json:"output,omitempty"`
+	OutputTruncated bool       `json:"output_truncated,omitempty"` // 목록 표시 한도로 잘림 (내보내기에는 전체)
+}
+
+// HistoryFilter 히스토리 검색 조건. 빈 값은 조건 없음.
+type HistoryFilter struct {
+	Terms    []string        // 명령, 출력, 오류에 모두 들어 있어야 하는 단어 (대소문자 무시)
+	Agent    string          // 에이전트 ID 또는 호스트 이름
+	Group    string          // 대상 그룹
+	User     string          // 요청한 사용자
+	States   map[string]bool // 대상 상태 (succeeded, failed, ...)
+	ExitCode *int
+	From     time.Time // 이 시각 이후에 만든 작업
+	To       time.Time // 이 시각 이전에 만든 작업
+}
+
+// parseHistoryDate RFC3339 시각 또는 날짜(YYYY-MM-DD, 서버 시간). endOfDay면 날짜의 끝
+func parseHistoryDate(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("잘못된 날짜 %q (YYYY-MM-DD 또는 RFC3339)", v)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// parseHistoryFilter 쿼리 문자열(q, agent, group, requested_by, status, exit_code, from, to)을 검색 조건으로 바꾼다.
+// user는 대시보드 로그인에 쓰이므로 요청한 사용자 조건은 requested_by로 받는다.
+func parseHistoryFilter(r *http.Request) (*HistoryFilter, error) {
+	q := r.URL.Query()
+	f := &HistoryFilter{
+		Terms: strings.Fields(strings.ToLower(q.Get("q"))),
+		Agent: strings.TrimSpace(q.Get("agent")),
+		Group: strings.TrimSpace(q.Get("group")),
+		User:  strings.TrimSpace(q.Get("requested_by")),
+	}
+	if v := q.Get("status"); v != "" {
+		f.States = map[string]bool{}
+		for _, state := range strings.Split(v, ",") {
+			f.States[strings.TrimSpace(state)] = true
+		}
+	}
+	if v := q.Get("exit_code"); v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("잘못된 exit_code %q", v)
+		}
+		f.ExitCode = &code
+	}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.From, err = parseHistoryDate(v, false); err != nil {
+			return nil, err
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = parseHistoryDate(v, true); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// matchJob 대상을 보기 전에 작업 목록 정보만으로 거를 수 있는 조건
+func (f *HistoryFilter) matchJob(job *Job) bool {
+	if f.User != "" && !strings.EqualFold(job.RequestedBy, f.User) {
+		return false
+	}
+	if !f.From.IsZero() && job.CreatedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && job.CreatedAt.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// matchEntry 대상별 조건과 전체 텍스트 검색
+func (f *HistoryFilter) matchEntry(e *HistoryEntry) bool {
+	if f.Agent != "" && e.AgentID != f.Agent && !strings.EqualFold(e.Hostname, f.Agent) {
+		return false
+	}
+	if f.Group != "" && !strings.EqualFold(e.Group, f.Group) {
+		return false
+	}
+	if f.States != nil && !f.States[e.State] {
+		return false
+	}
+	if f.ExitCode != nil && (e.ExitCode == nil || *e.ExitCode != *f.ExitCode) {
+		return false
+	}
+	if len(f.Terms) > 0 {
+		text := strings.ToLower(e.Command + "\n" + e.Error + "\n" + e.Output)
+		for _, term := range f.Terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// targetOutput 대상의 표준 출력과 표준 에러
+func targetOutput(t *JobTarget) string {
+	if t.Result == nil {
+		return ""
+	}
+	stdout, ok := t.Result["stdout"].(string)
+	if !ok {
+		// 이전 버전 에이전트는 stdout/stderr 대신 output만 보낸다
+		stdout, _ = t.Result["output"].(string)
+	}
+	stderr, _ := t.Result["stderr"].(string)
+	if strings.TrimSpace(stderr) != "" {
+		stdout += "\n[stderr]\n" + stderr
+	}
+	return stdout
+}
+
+// historyEntry 작업 대상 하나를 히스토리 한 줄로 바꾼다. 출력은 채우지 않는다.
+func historyEntry(job *Job, t *JobTarget) *HistoryEntry {
+	e := &HistoryEntry{
+		JobID:       job.ID,
+		CreatedAt:   job.CreatedAt,
+		EndedAt:     t.EndedAt,
+		Command:     job.Command,
+		RequestedBy: job.RequestedBy,
+		ApprovedBy:  job.ApprovedBy,
+		AgentID:     t.AgentID,
+		Hostname:    t.Hostname,
+		Group:       t.Group,
+		State:       t.State,
+		Error:       t.Error,
+	}
+	if e.Group == "" {
+		// 대상 그룹을 기록하기 전에 만든 작업
+		e.Group = job.Request.Group
+	}
+	if code, ok := t.Result["exit_code"].(float64); ok {
+		c := int(code)
+		e.ExitCode = &c
+	}
+	return e
+}
+
+// historyEntries 작업의 대상별 히스토리 항목 (출력 제외)
+func historyEntries(job *Job) []*HistoryEntry {
+	entries := make([]*HistoryEntry, len(job.Targets))
+	for i, t := range job.Targets {
+		entries[i] = historyEntry(job, t)
+	}
+	return entries
+}
+
+// snapshot 실행 중인 작업은 사본을, 끝난 작업은 파일을 읽어 반환한다 (검색으로 메모리 캐시를 채우지 않도록).
+func (s *jobStore) snapshot(id string) *Job {
+	s.mu.Lock()
+	_, live := s.live[id]
+	s.mu.Unlock()
+
+	if live {
+		job, _ := s.Get(id)
+		return job
+	}
+	var job Job
+	if err := loadJSONFile(s.path(id), &job); err != nil {
+		return nil
+	}
+	return &job
+}
+
+// History 조건에 맞는 대상별 기록을 최근 작업부터 찾아 offset부터 limit개(0 = 전부) 반환한다.
+// total은 조건에 맞는 전체 항목 수
+//
+// 전체 수는 메모리의 히스토리 항목으로 세고, 작업 파일은 현재 페이지에 들어가는 작업만 읽는다.
+// 출력을 검색하는 경우(q)에는 조건에 맞는 작업 파일을 모두 읽는다.
+func (s *jobStore) History(f *HistoryFilter, offset, limit int) (entries []*HistoryEntry, total int) {
+	type candidate struct {
+		header  *Job
+		entries []*HistoryEntry
+	}
+	s.mu.Lock()
+	candidates := make([]candidate, 0, len(s.index))
+	for id, job := range s.index {
+		if f.matchJob(job) {
+			candidates = append(candidates, candidate{job, s.history[id]})
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].header.CreatedAt.After(candidates[j].header.CreatedAt)
+	})
+
+	for _, c := range candidates {
+		if len(f.Terms) == 0 {
+			n := 0
+			for _, e := range c.entries {
+				if f.matchEntry(e) {
+					n++
+				}
+			}
+			if total+n <= offset || (limit > 0 && len(entries) >= limit) {
+				// 현재 페이지에 들어가지 않는 작업
+				total += n
+				continue
+			}
+		}
+		job := s.snapshot(c.header.ID)
+		if job == nil {
+			continue
+		}
+		for _, t := range job.Targets {
+			e := historyEntry(job, t)
+			e.Output = targetOutput(t)
+			if !f.matchEntry(e) {
+				continue
+			}
+			if total >= offset && (limit == 0 || len(entries) < limit) {
+				entries = append(entries, e)
+			}
+			total++
+		}
+	}
+	return entries, total
+}
+
+// handleHistory GET /api/history?q=&agent=&group=&requested_by=&status=&exit_code=&from=&to=&offset=&limit=
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	f, err := parseHistoryFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset, limit := 0, defaultHistoryLimit
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxHistoryLimit {
+			http.Error(w, fmt.Sprintf("limit must be 1-%d", maxHistoryLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, total := jobs.History(f, offset, limit)
+	for _, e := range entries {
+		if len(e.Output) > maxHistoryOutput {
+			e.Output = truncateUTF8(e.Output, maxHistoryOutput)
+			e.OutputTruncated = true
+		}
+	}
+	if entries == nil {
+		entries = []*HistoryEntry{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":   total,
+		"offset":  offset,
+		"limit":   limit,
+		"entries": entries,
+	})
+}
+
+// handleHistoryExport GET /api/history/export?format=csv|json&<검색 조건>
+// 조건에 맞는 항목을 출력 전체와 함께 파일로 내려받는다.
+func handleHistoryExport(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	f, err := parseHistoryFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	entries, total := jobs.History(f, 0, maxHistoryExport)
+	if entries == nil {
+		entries = []*HistoryEntry{}
+	}
+	audit.Record("history_exported", user.Name, map[string]interface{}{
+		"format":  format,
+		"query":   r.URL.Query().Get("q"),
+		"entries": len(entries),
+		"total":   total,
+	})
+
+	filename := "command-history-" + time.Now().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "json" {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	// 엑셀에서 한글이 깨지지 않도록 BOM을 붙인다
+	w.Write([]byte("\ufeff"))
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"created_at", "ended_at", "job_id", "requested_by", "approved_by", "agent_id", "hostname", "group", "state", "exit_code", "error", "command", "output"})
+	for _, e := range entries {
+		ended, exit := "", ""
+		if e.EndedAt != nil {
+			ended = e.EndedAt.Format(time.RFC3339)
+		}
+		if e.ExitCode != nil {
+			exit = strconv.Itoa(*e.ExitCode)
+		}
+		cw.Write([]string{e.CreatedAt.Format(time.RFC3339), ended, e.JobID,
+			csvText(e.RequestedBy), csvText(e.ApprovedBy), csvText(e.AgentID), csvText(e.Hostname), csvText(e.Group),
+			e.State, exit, csvText(e.Error), csvText(e.Command), csvText(e.Output)})
+	}
+	cw.Flush()
+}
+
+// csvText 스프레드시트가 수식으로 실행하지 않도록 =, +, -, @ (와 탭, CR)로 시작하는 값 앞에 '를 붙인다.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
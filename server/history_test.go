@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gopc-server/config"
+)
+
+func TestCSVText(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"echo hi":                  "echo hi",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1+1":                     "'+1+1",
+		"-2+3":                     "'-2+3",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\t=1":                     "'\t=1",
+		"a=b":                      "a=b",
+	}
+	for in, want := range tests {
+		if got := csvText(in); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	s := "ab한글"
+	for n := 0; n <= len(s)+1; n++ {
+		got := truncateUTF8(s, n)
+		if len(got) > n || !utf8.ValidString(got) {
+			t.Errorf("truncateUTF8(%q, %d) = %q", s, n, got)
+		}
+	}
+	if got := truncateUTF8(s, 4); got != "ab" {
+		t.Errorf("truncateUTF8 mid-rune = %q, want %q", got, "ab")
+	}
+	if got := truncateUTF8(s, 5); got != "ab한" {
+		t.Errorf("truncateUTF8 at boundary = %q, want %q", got, "ab한")
+	}
+}
+
+func TestHistoryPagingReadsOnlyPageJobs(t *testing.T) {
+	dir := t.TempDir()
+	jobsDir := filepath.Join(dir, "jobs")
+	if err := os.MkdirAll(jobsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		job := &Job{
+			ID:          fmt.Sprintf("job%d", i),
+			Command:     "hostname",
+			RequestedBy: "admin",
+			CreatedAt:   base.Add(time.Duration(i) * time.Minute),
+			Summary:     JobSummary{Done: true},
+			Targets: []*JobTarget{
+				{AgentID: "a", Hostname: "pc-a", State: TargetSucceeded, Result: map[string]interface{}{"stdout": fmt.Sprintf("out%d-a", i), "exit_code": 0.0}},
+				{AgentID: "b", Hostname: "pc-b", State: TargetFailed, Result: map[string]interface{}{"stdout": fmt.Sprintf("out%d-b", i), "exit_code": 1.0}},
+			},
+		}
+		if err := saveJSONFile(filepath.Join(jobsDir, job.ID+".json"), job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newJobStore(&config.Config{DataDir: dir})
+
+	code := 1
+	entries, total := s.History(&HistoryFilter{ExitCode: &code}, 0, 1)
+	if total != 5 || len(entries) != 1 || entries[0].Output != "out4-b" {
+		t.Errorf("exit_code filter: total = %d, entries = %+v", total, entries)
+	}
+
+	// 페이지 밖의 작업 파일은 읽지 않고 메모리 항목으로만 센다
+	for _, id := range []string{"job0", "job1", "job4"} {
+		os.Remove(s.path(id))
+	}
+	entries, total = s.History(&HistoryFilter{}, 2, 3)
+	if total != 10 {
+		t.Errorf("total = %d, want 10", total)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Output)
+	}
+	if want := []string{"out3-a", "out3-b", "out2-a"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("page outputs = %q, want %q", got, want)
+	}
+}
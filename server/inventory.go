@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KnownAgent 한 번이라도 등록한 에이전트
+type KnownAgent struct {
+	AgentInfo
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// agentInventory 등록한 적이 있는 에이전트 목록. 꺼져 있는 PC를 대상으로 명령을 보류할 때 쓴다.
+// MAC 주소(없으면 호스트 이름)로 구분하며 data_dir/agents.json 에 저장한다.
+type agentInventory struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]*KnownAgent
+}
+
+func newAgentInventory(path string) *agentInventory {
+	a := &agentInventory{path: path, items: make(map[string]*KnownAgent)}
+	var list []*KnownAgent
+	if err := loadJSONFile(path, &list); err != nil && !os.IsNotExist(err) {
+		log.Printf("에이전트 목록을 읽을 수 없습니다: %v", err)
+	}
+	for _, known := range list {
+		if key := inventoryKey(&known.AgentInfo); key != "" {
+			a.items[key] = known
+		}
+	}
+	return a
+}
+
+func inventoryKey(info *AgentInfo) string {
+	if info.MacAddr != "" {
+		return info.MacAddr
+	}
+	return info.Hostname
+}
+
+// Seen 등록한 에이전트 정보를 기록한다.
+func (a *agentInventory) Seen(info *AgentInfo) {
+	key := inventoryKey(info)
+	if key == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.items[key] = &KnownAgent{AgentInfo: *info, LastSeen: time.Now()}
+	list := make([]*KnownAgent, 0, len(a.items))
+	for _, known := range a.items {
+		list = append(list, known)
+	}
+	if err := saveJSONFile(a.path, list); err != nil {
+		log.Printf("에이전트 목록 저장 실패: %v", err)
+	}
+}
+
+// Offline 요청 대상에 해당하지만 지금 연결되어 있지 않은 에이전트 (agentsMutex를 잡은 상태에서 호출)
+// deliver_within이 없거나 에이전트 ID로 지정한 요청은 보류하지 않는다.
+func (a *agentInventory) Offline(req CommandRequest) []AgentInfo {
+	if req.DeliverWithin <= 0 || req.AgentID != "" {
+		return nil
+	}
+	connected := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		if agent.Info != nil {
+			connected[inventoryKey(agent.Info)] = true
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var list []AgentInfo
+	for key, known := range a.items {
+		if connected[key] || !req.matches(&known.AgentInfo) {
+			continue
+		}
+		list = append(list, known.AgentInfo)
+	}
+	return list
+}
+
+// matches 에이전트가 요청의 그룹/호스트 이름 조건에 해당하는지 여부
+func (req *CommandRequest) matches(info *AgentInfo) bool {
+	if req.Group != "" && (info == nil || info.Group != req.Group) {
+		return false
+	}
+	if len(req.Hostnames) > 0 {
+		if info == nil {
+			return false
+		}
+		for _, h := range req.Hostnames {
+			if strings.EqualFold(h, info.Hostname) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,958 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+// 대상 에이전트별 작업 상태
+// pending → sent → running → succeeded | failed | timed_out
+// 연결이 끊기면 offline (이후 결과가 도착하면 최종 상태로 바뀐다)
+// 순차 전송이 중단되어 보내지 않은 대상은 skipped
+// deliver_within: 꺼져 있는 대상은 waiting → 다시 연결되면 pending
+// retry: 실패한 대상은 retrying → 대기 시간이 지나면 pending
+// maintenance: 정비 시간이 아닌 대상은 held → 정비 시간이 되면 pending
+const (
+	TargetPending   = "pending"
+	TargetSent      = "sent"
+	TargetRunning   = "running"
+	TargetSucceeded = "succeeded"
+	TargetFailed    = "failed"
+	TargetTimedOut  = "timed_out"
+	TargetOffline   = "offline"
+	TargetSkipped   = "skipped"
+	TargetWaiting   = "waiting"
+	TargetRetrying  = "retrying"
+	TargetHeld      = "held"
+)
+
+const (
+	// 완료된 작업의 전체 결과를 메모리에 두는 시간 (이후에는 파일에서 읽음)
+	jobCacheTTL = 10 * time.Minute
+	// 에이전트 제한 시간이 지나고도 결과가 없으면 timed_out으로 보기까지의 여유
+	jobTimeoutGrace = time.Minute
+	// 변경된 작업을 파일에 저장하는 주기
+	jobFlushInterval = 2 * time.Second
+)
+
+// JobTarget 작업 대상 에이전트 한 대의 상태와 결과
+type JobTarget struct {
+	AgentID   string                 `json:"agent_id"`
+	Hostname  string                 `json:"hostname,omitempty"`
+	MacAddr   string                 `json:"mac_addr,omitempty"`
+	Group     string                 `json:"group,omitempty"`
+	State     string                 `json:"state"`
+	Error     string                 `json:"error,omitempty"`
+	SentAt    *time.Time             `json:"sent_at,omitempty"`
+	StartedAt *time.Time             `json:"started_at,omitempty"`
+	EndedAt   *time.Time             `json:"ended_at,omitempty"`
+	Result    map[string]interface{} `json:"result,omitempty"` // 에이전트가 보낸 최종 결과
+	Steps     []*StepStatus          `json:"steps,omitempty"`  // 플레이북 단계별 상태
+
+	Attempts      int        `json:"attempts,omitempty"`        // 전송 횟수 (재시도 포함)
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // retrying: 다음 재시도 시각, held: 정비 시간 시작 시각
+	HoldUntil     *time.Time `json:"hold_until,omitempty"`      // waiting: 이 시각까지 연결되면 보낸다
+}
+
+// final 더 이상 진행하지 않는 상태 여부
+func (t *JobTarget) final() bool {
+	switch t.State {
+	case TargetPending, TargetSent, TargetRunning, TargetWaiting, TargetRetrying, TargetHeld:
+		return false
+	}
+	return true
+}
+
+// JobSummary 상태별 대상 수
+type JobSummary struct {
+	Total     int  `json:"total"`
+	Pending   int  `json:"pending"`
+	Sent      int  `json:"sent"`
+	Running   int  `json:"running"`
+	Succeeded int  `json:"succeeded"`
+	Failed    int  `json:"failed"`
+	TimedOut  int  `json:"timed_out"`
+	Offline   int  `json:"offline"`
+	Skipped   int  `json:"skipped"`
+	Waiting   int  `json:"waiting"`
+	Retrying  int  `json:"retrying"`
+	Held      int  `json:"held"`
+	Done      bool `json:"done"` // 모든 대상이 최종 상태
+}
+
+// Job 여러 에이전트에 보낸 명령 하나 (ID는 명령 ID와 같다)
+type Job struct {
+	ID          string         `json:"id"`
+	Command     string         `json:"command"`
+	Request     CommandRequest `json:"request"`
+	RequestedBy string         `json:"requested_by"`
+	ApprovedBy  string         `json:"approved_by,omitempty"`
+	CanceledBy  string         `json:"canceled_by,omitempty"` // 작업 전체를 취소한 사용자. 취소된 작업은 다시 보내지 않는다
+	CreatedAt   time.Time      `json:"created_at"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
+	Summary     JobSummary     `json:"summary"`
+	Rollout     *RolloutState  `json:"rollout,omitempty"`
+	Targets     []*JobTarget   `json:"targets,omitempty"`
+}
+
+// target 에이전트 ID로 대상을 찾는다.
+func (j *Job) target(agentID string) *JobTarget {
+	for _, t := range j.Targets {
+		if t.AgentID == agentID {
+			return t
+		}
+	}
+	return nil
+}
+
+// targetOf 재접속으로 ID가 바뀐 에이전트도 MAC 주소/호스트 이름으로 찾는다.
+func (j *Job) targetOf(agentID string, info *AgentInfo) *JobTarget {
+	if t := j.target(agentID); t != nil {
+		return t
+	}
+	if info == nil {
+		return nil
+	}
+	for _, t := range j.Targets {
+		if info.MacAddr != "" && t.MacAddr == info.MacAddr {
+			return t
+		}
+		if info.MacAddr == "" && info.Hostname != "" && t.Hostname == info.Hostname {
+			return t
+		}
+	}
+	return nil
+}
+
+// summarize 대상 상태를 집계하고 모두 끝났으면 완료 시각을 기록한다.
+func (j *Job) summarize(now time.Time) {
+	s := JobSummary{Total: len(j.Targets)}
+	for _, t := range j.Targets {
+		switch t.State {
+		case TargetPending:
+			s.Pending++
+		case TargetSent:
+			s.Sent++
+		case TargetRunning:
+			s.Running++
+		case TargetSucceeded:
+			s.Succeeded++
+		case TargetFailed:
+			s.Failed++
+		case TargetTimedOut:
+			s.TimedOut++
+		case TargetOffline:
+			s.Offline++
+		case TargetSkipped:
+			s.Skipped++
+		case TargetWaiting:
+			s.Waiting++
+		case TargetRetrying:
+			s.Retrying++
+		case TargetHeld:
+			s.Held++
+		}
+	}
+	s.Done = s.Pending+s.Sent+s.Running+s.Waiting+s.Retrying+s.Held == 0
+	j.Summary = s
+	if s.Done && j.FinishedAt == nil {
+		j.FinishedAt = &now
+	}
+	if !s.Done {
+		j.FinishedAt = nil
+	}
+}
+
+// maxAttempts 대상마다 보낼 수 있는 최대 횟수
+func (j *Job) maxAttempts() int {
+	if j.Request.Retry != nil {
+		return j.Request.Retry.MaxAttempts
+	}
+	return 1
+}
+
+// holdDeadline 꺼져 있는 대상을 보류하는 기한. 보류하지 않는 작업이면 nil
+func (j *Job) holdDeadline() *time.Time {
+	if j.Request.DeliverWithin <= 0 {
+		return nil
+	}
+	deadline := j.CreatedAt.Add(time.Duration(j.Request.DeliverWithin) * time.Second)
+	return &deadline
+}
+
+// hold 기한과 남은 시도 횟수가 있으면 대상을 waiting으로 바꾼다.
+// 이미 보낸 명령은 retry로 시도 횟수가 남아 있을 때만 다시 보낸다 (종료 명령 등이 두 번 실행되지 않도록).
+func (j *Job) hold(t *JobTarget, now time.Time) bool {
+	deadline := j.holdDeadline()
+	if deadline == nil || j.CanceledBy != "" || !now.Before(*deadline) || t.Attempts >= j.maxAttempts() {
+		return false
+	}
+	t.State = TargetWaiting
+	t.HoldUntil = deadline
+	t.NextAttemptAt = nil
+	return true
+}
+
+// retry 실패한 대상에 남은 시도 횟수가 있으면 retrying으로 바꾼다. 취소된 작업은 다시 보내지 않는다.
+// 순차 전송 작업도 재시도하지 않는다 (재전송이 동시 실행 수 제한 밖에서 나가므로, 함께 쓰기를 막기 전에 저장된 작업 대비).
+func (j *Job) retry(t *JobTarget, now time.Time) bool {
+	p := j.Request.Retry
+	if p == nil || j.Rollout != nil || j.CanceledBy != "" || t.Attempts >= p.MaxAttempts {
+		return false
+	}
+	next := now.Add(p.delay(t.Attempts))
+	t.State = TargetRetrying
+	t.NextAttemptAt = &next
+	return true
+}
+
+// header 대상 목록을 뺀 목록용 사본
+func (j *Job) header() *Job {
+	h := *j
+	h.Targets = nil
+	if j.Rollout != nil {
+		r := *j.Rollout
+		h.Rollout = &r
+	}
+	return &h
+}
+
+// targetState 에이전트 결과 상태를 작업 대상 상태로 바꾼다.
+func targetState(result map[string]interface{}) string {
+	status, _ := result["status"].(string)
+	reason, _ := result["kill_reason"].(string)
+	switch {
+	case status == "succeeded":
+		return TargetSucceeded
+	case status == "" && result["error"] == nil:
+		// 상태를 보내지 않는 이전 버전 에이전트
+		return TargetSucceeded
+	case status == "killed" && reason == "timeout":
+		return TargetTimedOut
+	}
+	return TargetFailed
+}
+
+// jobStore 서버에서 보낸 명령을 작업으로 기록하고 대상별 진행 상태를 추적한다.
+// data_dir/jobs/<id>.json 에 저장한다.
+type jobStore struct {
+	mu        sync.Mutex
+	dir       string
+	retention time.Duration
+	index     map[string]*Job            // 모든 작업의 목록용 사본 (대상 제외)
+	history   map[string][]*HistoryEntry // 작업별 히스토리 항목 (출력 제외, 페이지를 넘길 때 작업 파일을 모두 읽지 않도록)
+	live      map[string]*Job            // 진행 중이거나 최근 완료된 작업 (대상 포함)
+	dirty     map[string]bool
+}
+
+func newJobStore(cfg *config.Config) *jobStore {
+	s := &jobStore{
+		dir:       filepath.Join(cfg.DataDir, "jobs"),
+		retention: cfg.GetJobRetention(),
+		index:     make(map[string]*Job),
+		history:   make(map[string][]*HistoryEntry),
+		live:      make(map[string]*Job),
+		dirty:     make(map[string]bool),
+	}
+	s.load()
+	return s
+}
+
+func (s *jobStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// load 저장된 작업 목록을 읽는다. 서버가 멈춘 동안 끝나지 않은 대상은 offline으로 바꾼다.
+// 보류 중이거나 재시도를 기다리는 대상은 그대로 두고 계속 추적한다.
+func (s *jobStore) load() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("작업 목록을 읽을 수 없습니다: %v", err)
+		}
+		return
+	}
+	now := time.Now()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		var job Job
+		if err := loadJSONFile(filepath.Join(s.dir, name), &job); err != nil {
+			log.Printf("작업 파일 %s 무시: %v", name, err)
+			continue
+		}
+		if !job.Summary.Done || (job.Rollout != nil && job.Rollout.active()) {
+			for _, t := range job.Targets {
+				switch {
+				case t.State == TargetPending && job.Rollout != nil:
+					t.State = TargetSkipped
+					t.Error = "server restarted during the rollout"
+				case t.State == TargetWaiting || t.State == TargetRetrying || t.State == TargetHeld:
+				case !t.final():
+					t.State = TargetOffline
+					t.Error = "server restarted before the result arrived"
+					for _, st := range t.Steps {
+						switch st.State {
+						case StepRunning:
+							st.State = StepFailed
+							st.Error = "server restarted"
+						case StepPending:
+							st.State = StepSkipped
+						}
+					}
+				}
+			}
+			if job.Rollout != nil && job.Rollout.active() {
+				job.Rollout.State = RolloutAborted
+				job.Rollout.Reason = "server restarted"
+				job.Rollout.NextAt = nil
+			}
+			job.summarize(now)
+			if err := saveJSONFile(s.path(job.ID), &job); err != nil {
+				log.Printf("작업 %s 저장 실패: %v", job.ID, err)
+			}
+			if !job.Summary.Done {
+				s.live[job.ID] = &job
+			}
+		}
+		s.indexJob(&job)
+	}
+	log.Printf("작업 %d개 로드", len(s.index))
+}
+
+// Create 명령 전송 직전에 작업을 만든다. 연결된 대상은 pending, 꺼져 있어 보류하는 대상(held)은 waiting 상태로 시작한다.
+func (s *jobStore) Create(id string, req CommandRequest, targets []*Agent, held []AgentInfo, requestedBy, approvedBy string) *Job {
+	now := time.Now()
+	job := &Job{
+		ID:          id,
+		Command:     req.Command,
+		Request:     req,
+		RequestedBy: requestedBy,
+		ApprovedBy:  approvedBy,
+		CreatedAt:   now,
+		Targets:     make([]*JobTarget, 0, len(targets)+len(held)),
+	}
+	for _, agent := range targets {
+		t := &JobTarget{AgentID: agent.ID, State: TargetPending}
+		if agent.Info != nil {
+			t.Hostname = agent.Info.Hostname
+			t.MacAddr = agent.Info.MacAddr
+			t.Group = agent.Info.Group
+		}
+		job.Targets = append(job.Targets, t)
+	}
+	for _, info := range held {
+		job.Targets = append(job.Targets, &JobTarget{
+			Hostname:  info.Hostname,
+			MacAddr:   info.MacAddr,
+			Group:     info.Group,
+			State:     TargetWaiting,
+			HoldUntil: job.holdDeadline(),
+		})
+	}
+	if req.Rollout != nil {
+		job.Rollout = newRolloutState(*req.Rollout, len(targets))
+	}
+	job.summarize(now)
+
+	s.mu.Lock()
+	s.live[id] = job
+	s.indexJob(job)
+	s.save(job)
+	s.mu.Unlock()
+	return job
+}
+
+// Sent 명령을 에이전트 연결에 썼다.
+func (s *jobStore) Sent(jobID, agentID string) {
+	s.update(jobID, agentID, nil, func(t *JobTarget, now time.Time) bool {
+		if t.State != TargetPending {
+			return false
+		}
+		t.State = TargetSent
+		t.SentAt = &now
+		t.StartedAt = nil
+		t.EndedAt = nil
+		t.Error = ""
+		t.HoldUntil = nil
+		t.NextAttemptAt = nil
+		t.Attempts++
+		return true
+	})
+}
+
+// Offline 에이전트에 보내지 못했거나 결과 전에 연결이 끊겼다. 보류할 수 있으면 waiting으로 바꾼다.
+func (s *jobStore) Offline(jobID, agentID, reason string) {
+	s.updateJob(jobID, agentID, nil, func(job *Job, t *JobTarget, now time.Time) bool {
+		if t.final() {
+			return false
+		}
+		if !job.hold(t, now) {
+			t.State = TargetOffline
+		}
+		t.Error = reason
+		return true
+	})
+}
+
+// Claim 다시 연결된 에이전트의 보류 중인 대상을 새 에이전트 ID의 pending으로 바꾸고
+// 해당 작업을 반환한다 (agentsMutex를 잡은 상태에서 호출).
+func (s *jobStore) Claim(agent *Agent) []*Job {
+	if agent.Info == nil {
+		return nil
+	}
+	s.mu.Lock()
+	var claimed []*Job
+	now := time.Now()
+	for _, job := range s.live {
+		t := job.targetOf(agent.ID, agent.Info)
+		if t == nil || t.State != TargetWaiting {
+			continue
+		}
+		t.AgentID = agent.ID
+		t.State = TargetPending
+		t.HoldUntil = nil
+		s.touch(job, now)
+		claimed = append(claimed, job.header())
+	}
+	s.mu.Unlock()
+
+	for _, job := range claimed {
+		broadcastJobUpdate(job)
+	}
+	return claimed
+}
+
+// Release 재시도나 정비 시간을 기다리던 대상을 연결된 에이전트의 pending으로 바꾼다.
+// 이미 다른 상태이거나 그 사이 작업이 취소되었으면 false.
+func (s *jobStore) Release(jobID, agentID string, agent *Agent) bool {
+	retried := false
+	s.updateJob(jobID, agentID, agent.Info, func(job *Job, t *JobTarget, now time.Time) bool {
+		if job.CanceledBy != "" || (t.State != TargetRetrying && t.State != TargetHeld) {
+			return false
+		}
+		t.AgentID = agent.ID
+		t.State = TargetPending
+		t.NextAttemptAt = nil
+		retried = true
+		return true
+	})
+	return retried
+}
+
+// Defer 아직 보내지 않은 대상을 정비 시간이 시작하는 until까지 held로 바꾼다.
+// 다음 정비 시간을 찾지 못했으면(until이 nil) skipped로 끝낸다.
+func (s *jobStore) Defer(jobID, agentID string, info *AgentInfo, until *time.Time) {
+	s.update(jobID, agentID, info, func(t *JobTarget, now time.Time) bool {
+		switch t.State {
+		case TargetPending, TargetRetrying, TargetHeld:
+		default:
+			return false
+		}
+		t.HoldUntil = nil
+		t.NextAttemptAt = until
+		if until == nil {
+			t.State = TargetSkipped
+			t.Error = "no maintenance window within " + strconv.Itoa(maintenanceSearchDays) + " days"
+			return true
+		}
+		t.State = TargetHeld
+		return true
+	})
+}
+
+// Running 에이전트가 명령 실행을 시작했다 (command_started 또는 첫 출력 청크).
+func (s *jobStore) Running(jobID, agentID string) {
+	s.update(jobID, agentID, nil, func(t *JobTarget, now time.Time) bool {
+		if t.State != TargetPending && t.State != TargetSent {
+			return false
+		}
+		t.State = TargetRunning
+		t.StartedAt = &now
+		return true
+	})
+}
+
+// Result 에이전트의 최종 결과를 기록한다. offline이던 대상도 늦게 도착한 결과로 끝낸다.
+// 실패했고 재시도 횟수가 남아 있으면 retrying으로 바꾼다. 운영자가 취소해서 끝난 대상은 재시도하지 않는다.
+func (s *jobStore) Result(agentID string, info *AgentInfo, result map[string]interface{}) {
+	jobID, _ := result["id"].(string)
+	if jobID == "" {
+		return
+	}
+	s.updateJob(jobID, agentID, info, func(job *Job, t *JobTarget, now time.Time) bool {
+		if t.final() && t.State != TargetOffline {
+			return false
+		}
+		t.State = targetState(result)
+		t.Error, _ = result["error"].(string)
+		t.EndedAt = &now
+		t.Result = result
+		if reason, _ := result["kill_reason"].(string); t.State != TargetSucceeded && reason != "canceled" {
+			job.retry(t, now)
+		}
+		return true
+	})
+}
+
+// StartSteps 플레이북 대상의 실행을 시작하고 단계 목록을 기록한다.
+func (s *jobStore) StartSteps(jobID, agentID string, steps []*StepStatus) {
+	s.update(jobID, agentID, nil, func(t *JobTarget, now time.Time) bool {
+		if t.final() {
+			return false
+		}
+		t.State = TargetRunning
+		t.SentAt = &now
+		t.StartedAt = &now
+		t.Steps = steps
+		return true
+	})
+}
+
+// Step 플레이북 단계 하나의 상태를 바꾼다.
+func (s *jobStore) Step(jobID, agentID string, index int, change func(st *StepStatus)) {
+	s.update(jobID, agentID, nil, func(t *JobTarget, now time.Time) bool {
+		if index < 0 || index >= len(t.Steps) {
+			return false
+		}
+		change(t.Steps[index])
+		return true
+	})
+}
+
+// FinishSteps 플레이북 대상을 최종 상태로 끝낸다. result는 마지막으로 실행한 단계의 결과.
+func (s *jobStore) FinishSteps(jobID, agentID, state, reason string, result map[string]interface{}) {
+	s.update(jobID, agentID, nil, func(t *JobTarget, now time.Time) bool {
+		if t.final() {
+			return false
+		}
+		t.State = state
+		t.Error = reason
+		t.EndedAt = &now
+		t.Result = result
+		return true
+	})
+}
+
+// SetRollout 순차 전송 진행 상태를 바꾼다.
+func (s *jobStore) SetRollout(jobID string, change func(r *RolloutState)) {
+	s.mu.Lock()
+	job := s.get(jobID)
+	if job == nil || job.Rollout == nil {
+		s.mu.Unlock()
+		return
+	}
+	change(job.Rollout)
+	s.touch(job, time.Now())
+	header := job.header()
+	s.mu.Unlock()
+
+	broadcastJobUpdate(header)
+}
+
+// Skip 아직 보내지 않은 대상(보류, 재시도 대기, 정비 시간 대기 포함)을 skipped로 바꾼다.
+func (s *jobStore) Skip(jobID, reason string) {
+	s.skip(jobID, "", reason)
+}
+
+// Cancel 작업 전체를 취소한다. 보내지 않은 대상은 skipped로 바꾸고,
+// 실행 중인 대상이 실패나 연결 끊김으로 끝나도 다시 보내지 않는다.
+func (s *jobStore) Cancel(jobID, user string) {
+	s.skip(jobID, user, "canceled by "+user)
+}
+
+func (s *jobStore) skip(jobID, canceledBy, reason string) {
+	s.mu.Lock()
+	job := s.get(jobID)
+	if job == nil {
+		s.mu.Unlock()
+		return
+	}
+	if canceledBy != "" {
+		job.CanceledBy = canceledBy
+	}
+	for _, t := range job.Targets {
+		switch t.State {
+		case TargetPending, TargetWaiting, TargetRetrying, TargetHeld:
+			t.State = TargetSkipped
+			t.Error = reason
+			t.HoldUntil = nil
+			t.NextAttemptAt = nil
+		}
+	}
+	s.touch(job, time.Now())
+	header := job.header()
+	s.mu.Unlock()
+
+	broadcastJobUpdate(header)
+}
+
+// TargetCount 작업 대상 수
+func (s *jobStore) TargetCount(jobID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job := s.get(jobID); job != nil {
+		return len(job.Targets)
+	}
+	return 0
+}
+
+// Pending 아직 보내지 않은 대상 에이전트 ID (최대 n개, 대상 순서대로)
+func (s *jobStore) Pending(jobID string, n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	job := s.get(jobID)
+	if job == nil {
+		return nil
+	}
+	for _, t := range job.Targets {
+		if len(ids) >= n {
+			break
+		}
+		if t.State == TargetPending {
+			ids = append(ids, t.AgentID)
+		}
+	}
+	return ids
+}
+
+// InFlight 보냈지만 아직 결과가 없는 대상 수
+func (s *jobStore) InFlight(jobID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.get(jobID)
+	if job == nil {
+		return 0
+	}
+	return job.Summary.Sent + job.Summary.Running
+}
+
+// Failures 실패로 끝난 대상 수. 보낸 뒤에 연결이 끊긴 대상도 실패로 센다
+// (보내기 전에 꺼진 PC는 명령 때문이 아니므로 세지 않는다).
+func (s *jobStore) Failures(jobID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.get(jobID)
+	if job == nil {
+		return 0
+	}
+	failures := 0
+	for _, t := range job.Targets {
+		switch {
+		case t.State == TargetFailed || t.State == TargetTimedOut:
+			failures++
+		case t.State == TargetOffline && t.SentAt != nil:
+			failures++
+		}
+	}
+	return failures
+}
+
+// Finished 주어진 대상이 모두 최종 상태인지 여부
+func (s *jobStore) Finished(jobID string, agentIDs []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.get(jobID)
+	if job == nil {
+		return true
+	}
+	for _, id := range agentIDs {
+		if t := job.target(id); t != nil && !t.final() {
+			return false
+		}
+	}
+	return true
+}
+
+// AgentGone 연결이 끊긴 에이전트의 끝나지 않은 대상을 offline(보류할 수 있으면 waiting)으로 바꾼다.
+// 플레이북은 재부팅 등으로 다시 연결될 수 있으므로 실행기가 직접 처리한다.
+func (s *jobStore) AgentGone(agentID string) {
+	s.mu.Lock()
+	var changed []*Job
+	now := time.Now()
+	for _, job := range s.live {
+		if job.Request.Playbook != nil {
+			continue
+		}
+		t := job.target(agentID)
+		if t == nil || t.final() || t.State == TargetWaiting || t.State == TargetRetrying || t.State == TargetHeld {
+			continue
+		}
+		if !job.hold(t, now) {
+			t.State = TargetOffline
+		}
+		t.Error = "agent disconnected"
+		s.touch(job, now)
+		changed = append(changed, job.header())
+	}
+	s.mu.Unlock()
+
+	for _, job := range changed {
+		broadcastJobUpdate(job)
+	}
+}
+
+// update 대상 하나의 상태를 바꾸고 변경되면 대시보드에 알린다.
+func (s *jobStore) update(jobID, agentID string, info *AgentInfo, change func(t *JobTarget, now time.Time) bool) {
+	s.updateJob(jobID, agentID, info, func(_ *Job, t *JobTarget, now time.Time) bool {
+		return change(t, now)
+	})
+}
+
+// updateJob 작업 설정(재시도, 보류 기한)이 필요한 update
+func (s *jobStore) updateJob(jobID, agentID string, info *AgentInfo, change func(job *Job, t *JobTarget, now time.Time) bool) {
+	s.mu.Lock()
+	job := s.get(jobID)
+	if job == nil {
+		s.mu.Unlock()
+		return
+	}
+	t := job.targetOf(agentID, info)
+	now := time.Now()
+	if t == nil || !change(job, t, now) {
+		s.mu.Unlock()
+		return
+	}
+	s.touch(job, now)
+	header := job.header()
+	s.mu.Unlock()
+
+	broadcastJobUpdate(header)
+}
+
+// touch 요약을 다시 계산하고 저장 대상으로 표시한다. 작업이 끝났으면 바로 저장한다.
+func (s *jobStore) touch(job *Job, now time.Time) {
+	job.summarize(now)
+	s.indexJob(job)
+	if job.Summary.Done {
+		s.save(job)
+		return
+	}
+	s.dirty[job.ID] = true
+}
+
+// indexJob 목록용 사본과 히스토리 항목을 갱신한다 (mu를 잡은 상태에서 호출)
+func (s *jobStore) indexJob(job *Job) {
+	s.index[job.ID] = job.header()
+	s.history[job.ID] = historyEntries(job)
+}
+
+// get 전체 작업을 메모리 또는 파일에서 찾는다 (mu를 잡은 상태에서 호출)
+func (s *jobStore) get(id string) *Job {
+	if job, ok := s.live[id]; ok {
+		return job
+	}
+	if _, ok := s.index[id]; !ok {
+		return nil
+	}
+	var job Job
+	if err := loadJSONFile(s.path(id), &job); err != nil {
+		log.Printf("작업 %s 읽기 실패: %v", id, err)
+		return nil
+	}
+	s.live[id] = &job
+	return &job
+}
+
+func (s *jobStore) save(job *Job) {
+	delete(s.dirty, job.ID)
+	if err := saveJSONFile(s.path(job.ID), job); err != nil {
+		log.Printf("작업 %s 저장 실패: %v", job.ID, err)
+	}
+}
+
+// Get 대상별 결과를 포함한 작업
+func (s *jobStore) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.get(id)
+	if job == nil {
+		return nil, false
+	}
+	copied := *job
+	copied.Targets = make([]*JobTarget, len(job.Targets))
+	for i, t := range job.Targets {
+		tc := *t
+		if t.Steps != nil {
+			tc.Steps = make([]*StepStatus, len(t.Steps))
+			for j, st := range t.Steps {
+				sc := *st
+				tc.Steps[j] = &sc
+			}
+		}
+		copied.Targets[i] = &tc
+	}
+	return &copied, true
+}
+
+// List 최근 작업부터 limit개 (대상 제외)
+func (s *jobStore) List(limit int) []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Job, 0, len(s.index))
+	for _, job := range s.index {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// dueTarget 재시도 시각이나 정비 시간이 된 대상
+type dueTarget struct {
+	job      *Job // 목록용 사본
+	agentID  string
+	macAddr  string
+	hostname string
+}
+
+// runMaintenance 변경된 작업 저장, 응답 없는 대상의 시간 초과 처리, 재시도와 정비 시간 전송, 오래된 작업 정리
+func (s *jobStore) runMaintenance() {
+	ticker := time.NewTicker(jobFlushInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		var changed []*Job
+		var due []*dueTarget
+
+		s.mu.Lock()
+		for id, job := range s.live {
+			if job.Summary.Done {
+				if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > jobCacheTTL {
+					delete(s.live, id)
+				}
+				continue
+			}
+			expired := s.expireTargets(job, now)
+			if s.expireHolds(job, now) {
+				expired = true
+			}
+			if expired {
+				s.touch(job, now)
+				changed = append(changed, job.header())
+			}
+			for _, t := range job.Targets {
+				if (t.State == TargetRetrying || t.State == TargetHeld) && t.NextAttemptAt != nil && !now.Before(*t.NextAttemptAt) {
+					due = append(due, &dueTarget{job: job.header(), agentID: t.AgentID, macAddr: t.MacAddr, hostname: t.Hostname})
+				}
+			}
+		}
+		for id := range s.dirty {
+			if job, ok := s.live[id]; ok {
+				s.save(job)
+			}
+			delete(s.dirty, id)
+		}
+		if s.retention > 0 {
+			for id, job := range s.index {
+				if _, active := s.live[id]; !active && now.Sub(job.CreatedAt) > s.retention {
+					delete(s.index, id)
+					delete(s.history, id)
+					os.Remove(s.path(id))
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		for _, job := range changed {
+			broadcastJobUpdate(job)
+		}
+		if len(due) > 0 {
+			deliverDue(due)
+		}
+	}
+}
+
+// expireHolds 보류 기한까지 연결되지 않은 대상을 offline으로 바꾼다.
+func (s *jobStore) expireHolds(job *Job, now time.Time) bool {
+	expired := false
+	for _, t := range job.Targets {
+		if t.State == TargetWaiting && t.HoldUntil != nil && now.After(*t.HoldUntil) {
+			t.State = TargetOffline
+			t.Error = "agent did not come online before the delivery deadline"
+			t.HoldUntil = nil
+			expired = true
+		}
+	}
+	return expired
+}
+
+// expireTargets 실행을 시작한 뒤 제한 시간이 지나도 결과가 없는 대상을 timed_out으로 바꾼다.
+// 에이전트 큐에서 기다리는 대상(sent)은 시작하지 않았으므로 기다린다. 에이전트의 제한 시간도 시작할 때부터 재고,
+// 기다리는 동안 연결이 끊기면 AgentGone이 offline으로 바꾼다.
+func (s *jobStore) expireTargets(job *Job, now time.Time) bool {
+	if job.Request.Timeout <= 0 || job.Request.Detached || job.Request.Playbook != nil {
+		return false
+	}
+	deadline := time.Duration(job.Request.Timeout)*time.Second + jobTimeoutGrace
+	expired := false
+	for _, t := range job.Targets {
+		if t.State != TargetRunning || t.StartedAt == nil {
+			continue
+		}
+		if now.Sub(*t.StartedAt) > deadline {
+			t.State = TargetTimedOut
+			t.Error = "no result from agent before the deadline"
+			expired = true
+		}
+	}
+	return expired
+}
+
+func broadcastJobUpdate(job *Job) {
+	broadcastToDashboards(map[string]interface{}{
+		"type": "job_update",
+		"job":  job,
+	})
+}
+
+// handleListJobs GET /api/jobs?limit=N
+func handleListJobs(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, jobs.List(limit))
+}
+
+// handleGetJob GET /api/jobs/{id}
+func handleGetJob(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gopc-server/config"
+)
+
+func TestExpireTargetsWaitsForQueuedTargets(t *testing.T) {
+	now := time.Now()
+	longAgo := now.Add(-time.Hour)
+	recent := now.Add(-time.Second)
+	job := &Job{
+		Request: CommandRequest{Timeout: 60},
+		Targets: []*JobTarget{
+			{AgentID: "queued", State: TargetSent, SentAt: &longAgo},
+			{AgentID: "slow", State: TargetRunning, SentAt: &longAgo, StartedAt: &longAgo},
+			{AgentID: "started", State: TargetRunning, SentAt: &longAgo, StartedAt: &recent},
+		},
+	}
+	s := &jobStore{}
+	if !s.expireTargets(job, now) {
+		t.Fatal("no target expired")
+	}
+	want := map[string]string{"queued": TargetSent, "slow": TargetTimedOut, "started": TargetRunning}
+	for _, target := range job.Targets {
+		if target.State != want[target.AgentID] {
+			t.Errorf("%s: state = %s, want %s", target.AgentID, target.State, want[target.AgentID])
+		}
+	}
+}
+
+// newRetryJobStore 재시도 정책이 있는 작업 하나를 대상 두 대에 보낸 상태로 만든다.
+func newRetryJobStore(t *testing.T) *jobStore {
+	t.Helper()
+	s := newJobStore(&config.Config{DataDir: t.TempDir()})
+	sent := time.Now()
+	job := &Job{
+		ID:        "job-1",
+		Request:   CommandRequest{Retry: &RetryPolicy{MaxAttempts: 3}},
+		CreatedAt: sent,
+		Targets: []*JobTarget{
+			{AgentID: "a", State: TargetRunning, SentAt: &sent, StartedAt: &sent, Attempts: 1},
+			{AgentID: "b", State: TargetRetrying, SentAt: &sent, NextAttemptAt: &sent, Attempts: 1},
+		},
+	}
+	s.live[job.ID] = job
+	s.touch(job, sent)
+	return s
+}
+
+func TestCancelStopsRetries(t *testing.T) {
+	s := newRetryJobStore(t)
+	s.Cancel("job-1", "admin")
+	s.Result("a", nil, map[string]interface{}{"id": "job-1", "status": "failed", "error": "exit status 1"})
+
+	job, _ := s.Get("job-1")
+	if job.CanceledBy != "admin" {
+		t.Errorf("canceled_by = %q, want admin", job.CanceledBy)
+	}
+	want := map[string]string{"a": TargetFailed, "b": TargetSkipped}
+	for _, target := range job.Targets {
+		if target.State != want[target.AgentID] {
+			t.Errorf("%s: state = %s, want %s", target.AgentID, target.State, want[target.AgentID])
+		}
+	}
+	if !job.Summary.Done {
+		t.Error("canceled job is still waiting for retries")
+	}
+	if s.Release("job-1", "b", &Agent{ID: "b"}) {
+		t.Error("retry released after the job was canceled")
+	}
+}
+
+func TestCanceledResultIsNotRetried(t *testing.T) {
+	s := newRetryJobStore(t)
+	// 대상 하나만 취소하면 작업은 취소 상태가 아니지만 그 대상은 다시 보내지 않는다
+	s.Result("a", nil, map[string]interface{}{"id": "job-1", "status": "killed", "kill_reason": "canceled"})
+
+	job, _ := s.Get("job-1")
+	if got := job.target("a").State; got != TargetFailed {
+		t.Errorf("canceled target state = %s, want %s", got, TargetFailed)
+	}
+	if got := job.target("b").State; got != TargetRetrying {
+		t.Errorf("other target state = %s, want %s", got, TargetRetrying)
+	}
+}
+
+func TestRolloutJobIsNotRetried(t *testing.T) {
+	s := newRetryJobStore(t)
+	s.live["job-1"].Rollout = &RolloutState{}
+	s.Result("a", nil, map[string]interface{}{"id": "job-1", "status": "failed", "error": "exit status 1"})
+
+	job, _ := s.Get("job-1")
+	if got := job.target("a").State; got != TargetFailed {
+		t.Errorf("rollout target state = %s, want %s", got, TargetFailed)
+	}
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,17 +20,39 @@ import (
 
 // 데이터 구조 정의
 type AgentInfo struct {
-	Hostname string `json:"hostname"`
-	OS       string `json:"os"`
-	Arch     string `json:"arch"`
-	MacAddr  string `json:"mac_addr"`
+	Hostname string            `json:"hostname"`
+	OS       string            `json:"os"`
+	Arch     string            `json:"arch"`
+	MacAddr  string            `json:"mac_addr"`
+	Group    string            `json:"group,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"` // 플레이북 변수 labels.*
 }
 
 type AgentStatus struct {
 	MemoryUsage float64 `json:"memory_usage"` // percent
 	CPUUsage    float64 `json:"cpu_usage"`    // percent
-	DiskUsage   float64 `json:"disk_usage"`   // percent
-	Uptime      uint64  `json:"uptime"`       // seconds
+	DiskUsage   float64 `json:"disk_usage"`   // percent (/ 또는 시스템 드라이브)
+	Uptime      uint64  `json:"uptime"`       // 호스트 가동 시간 (seconds)
+
+	MemoryTotal uint64      `json:"memory_total,omitempty"` // bytes
+	MemoryUsed  uint64      `json:"memory_used,omitempty"`  // bytes
+	Load        []float64   `json:"load,omitempty"`         // 1, 5, 15분 평균 부하 (Linux)
+	Disks       []DiskUsage `json:"disks,omitempty"`        // 마운트 지점(드라이브)별 사용량
+
+	AgentUptime uint64  `json:"agent_uptime,omitempty"` // 에이전트 실행 시간 (seconds)
+	AgentMemory float64 `json:"agent_memory,omitempty"` // 에이전트 Go 힙 (MB)
+
+	Network   []NetInterface `json:"network,omitempty"`    // 네트워크 인터페이스별 주소, 링크 상태, 송수신량
+	LatencyMs float64        `json:"latency_ms,omitempty"` // 에이전트가 잰 WebSocket 왕복 시간
+}
+
+// DiskUsage 마운트 지점(드라이브) 하나의 사용량
+type DiskUsage struct {
+	Mount   string  `json:"mount"`
+	FSType  string  `json:"fstype,omitempty"`
+	Total   uint64  `json:"total"` // bytes
+	Used    uint64  `json:"used"`  // bytes
+	Percent float64 `json:"percent"`
 }
 
 type Agent struct {
@@ -50,6 +78,129 @@ type DashboardMessage struct {
 	Result interface{} `json:"result,omitempty"`
 }
 
+// CommandRequest 대시보드에서 요청한 명령과 대상
+type CommandRequest struct {
+	Command  string `json:"command"`
+	AgentID  string `json:"agent_id,omitempty"` // 특정 에이전트 (비어 있으면 전체)
+	Group    string `json:"group,omitempty"`    // 특정 그룹
+	Timeout  int    `json:"timeout,omitempty"`  // 실행 제한 시간 (초, 0 = 제한 없음)
+	Priority string `json:"priority,omitempty"` // interactive (기본) | background
+	Detached bool   `json:"detached,omitempty"` // 에이전트 재시작에도 살아남는 분리 실행
+
+	Exec     *ExecSpec    `json:"exec,omitempty"`     // 구조화 실행 (셸 해석 없이 argv로 실행)
+	Script   *ScriptRun   `json:"script,omitempty"`   // 스크립트 라이브러리 실행
+	Playbook *PlaybookRun `json:"playbook,omitempty"` // 여러 단계 플레이북 실행 (서버에서 단계별로 전송)
+	Template *TemplateRun `json:"template,omitempty"` // 명령 템플릿 실행 (자리 표시자를 채운 명령으로 보냄)
+
+	Rollout *RolloutSpec `json:"rollout,omitempty"` // 배치 단위 순차 전송 (서버에서 처리)
+	Retry   *RetryPolicy `json:"retry,omitempty"`   // 실패한 대상 재시도 (서버에서 처리)
+
+	Hostnames     []string `json:"hostnames,omitempty"`      // 호스트 이름으로 지정 (대소문자 무시, 예약 실행 대상)
+	DeliverWithin int      `json:"deliver_within,omitempty"` // 꺼져 있는 대상이 연결되기를 기다리는 시간 (초, 0 = 기다리지 않음)
+
+	Maintenance    bool   `json:"maintenance,omitempty"`     // 대상 그룹의 정비 시간에만 실행 (그 전까지 보류)
+	OverrideReason string `json:"override_reason,omitempty"` // 긴급 실행: 정비 시간을 무시하는 이유 (감사 로그에 기록)
+}
+
+// ExecSpec 인자 배열, 작업 디렉토리, 환경 변수, 표준 입력을 지정한 구조화 명령
+// 상세 검증은 에이전트에서 수행한다.
+type ExecSpec struct {
+	Argv  []string          `json:"argv"`
+	Cwd   string            `json:"cwd,omitempty"`
+	Env   map[string]string `json:"env,omitempty"`
+	Stdin string            `json:"stdin,omitempty"`
+	Shell string            `json:"shell,omitempty"` // 비어 있으면 셸 없이 실행. sh, bash, cmd, powershell
+}
+
+// String 표시/승인 규칙 검사용 명령 문자열
+func (s *ExecSpec) String() string {
+	parts := make([]string, 0, len(s.Argv)+1)
+	if s.Shell != "" {
+		parts = append(parts, "["+s.Shell+"]")
+	}
+	for _, a := range s.Argv {
+		if a == "" || strings.ContainsAny(a, " \t\"'") {
+			a = fmt.Sprintf("%q", a)
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseCommandRequest 대시보드 메시지에서 명령 요청을 읽는다.
+func parseCommandRequest(msg map[string]interface{}) (CommandRequest, error) {
+	var req CommandRequest
+	data, _ := json.Marshal(msg)
+	if err := json.Unmarshal(data, &req); err != nil {
+		return req, err
+	}
+	if req.Timeout < 0 {
+		req.Timeout = 0
+	}
+	if req.Exec != nil && req.Script != nil {
+		return req, fmt.Errorf("exec와 script는 함께 사용할 수 없습니다")
+	}
+	if req.Template != nil {
+		if req.Exec != nil || req.Script != nil || req.Playbook != nil {
+			return req, fmt.Errorf("template은 exec, script, playbook과 함께 사용할 수 없습니다")
+		}
+		if err := templates.Resolve(&req); err != nil {
+			return req, err
+		}
+	}
+	if req.Exec != nil {
+		if len(req.Exec.Argv) == 0 || req.Exec.Argv[0] == "" {
+			return req, fmt.Errorf("exec.argv가 비어 있습니다")
+		}
+		req.Command = req.Exec.String()
+	}
+	if req.Script != nil {
+		if err := scripts.Resolve(req.Script); err != nil {
+			return req, err
+		}
+		req.Command = req.Script.String()
+	}
+	if req.Playbook != nil {
+		if req.Exec != nil || req.Script != nil {
+			return req, fmt.Errorf("playbook은 exec, script와 함께 사용할 수 없습니다")
+		}
+		if req.Detached || req.Rollout != nil || req.Retry != nil {
+			return req, fmt.Errorf("playbook은 detached, rollout, retry와 함께 사용할 수 없습니다")
+		}
+		if err := playbooks.Resolve(req.Playbook); err != nil {
+			return req, err
+		}
+		req.Command = req.Playbook.String()
+	}
+	if req.Command == "" {
+		return req, fmt.Errorf("명령이 비어 있습니다")
+	}
+	if req.Rollout != nil {
+		if err := req.Rollout.validate(); err != nil {
+			return req, err
+		}
+	}
+	if req.Retry != nil {
+		if err := req.Retry.validate(); err != nil {
+			return req, err
+		}
+	}
+	switch {
+	case req.DeliverWithin < 0 || req.DeliverWithin > maxDeliverWithin:
+		return req, fmt.Errorf("deliver_within은 0-%d초 사이여야 합니다", maxDeliverWithin)
+	case req.DeliverWithin > 0 && req.Rollout != nil:
+		return req, fmt.Errorf("deliver_within은 rollout과 함께 사용할 수 없습니다")
+	case req.Maintenance && req.Rollout != nil:
+		return req, fmt.Errorf("maintenance는 rollout과 함께 사용할 수 없습니다")
+	case req.Retry != nil && req.Rollout != nil:
+		// 재시도는 배치 동시 실행 수와 실패 한도 밖에서 다시 보내므로 함께 쓰지 않는다
+		return req, fmt.Errorf("retry는 rollout과 함께 사용할 수 없습니다")
+	case req.OverrideReason != "" && !req.Maintenance:
+		return req, fmt.Errorf("override_reason은 maintenance 작업에만 사용할 수 있습니다")
+	}
+	return req, nil
+}
+
 var (
 	upgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
@@ -59,17 +210,86 @@ var (
 
 	// 연결된 에이전트들을 저장하는 맵
 	agents = make(map[*websocket.Conn]*Agent)
-	// 연결된 대시보드들을 저장하는 맵
-	dashboards = make(map[*websocket.Conn]bool)
+	// 연결된 대시보드들과 접속 사용자를 저장하는 맵
+	dashboards = make(map[*websocket.Conn]*config.DashboardUser)
 	// 맵에 대한 동시 접근을 제어하기 위한 뮤텍스
 	agentsMutex     = sync.Mutex{}
 	dashboardsMutex = sync.Mutex{}
+
+	// 대시보드 사용자, 위험 명령 승인, 감사 로그
+	dashboardUsers []config.DashboardUser
+	approvals      *approvalManager
+	audit          *auditLog
+
+	// 실행 중 명령 출력 중계/조립
+	outputs = newOutputAssembler()
+	// 원격 터미널 세션 중계
+	terminals *terminalBridge
+	// 스크립트 라이브러리
+	scripts *scriptLibrary
+	// 잘린 명령 출력 전체 가져오기
+	fetcher *outputFetcher
+	// 에이전트 상태 시계열 (1분/1시간 평균)
+	metrics *metricsStore
+	// 프로세스 목록/종료처럼 에이전트 응답을 기다리는 요청
+	agentQueries *agentRequests
+	// 대상별 진행 상태를 추적하는 작업 기록
+	jobs *jobStore
+	// cron 예약 실행
+	schedules *scheduleStore
+	// 배치 단위 순차 전송
+	rollouts = newRolloutManager()
+	// 여러 단계 플레이북
+	playbooks    *playbookLibrary
+	playbookRuns = newPlaybookRunner()
+	// 등록한 적이 있는 에이전트 (꺼져 있는 대상 보류용)
+	inventory *agentInventory
+	// 명령 템플릿
+	templates *templateStore
+	// 그룹별 정비 시간
+	maintenance *maintenanceStore
+	// 에이전트별 네트워크 상태 기록
+	network = newNetworkStore()
 )
 
 func main() {
+	genKey := flag.Bool("gen-update-key", false, "Generate an Ed25519 key for signing agent updates and exit.")
+	flag.Parse()
+
 	// 설정 로드
 	cfg := config.Load()
 
+	if *genKey {
+		pub, err := generateUpdateKey(cfg.UpdateKeyFile)
+		if err != nil {
+			log.Fatal("generate update key: ", err)
+		}
+		fmt.Printf("Update signing key written to %s\n", cfg.UpdateKeyFile)
+		fmt.Printf("Set this in the agent config.yaml:\nupdate_public_key: \"%s\"\n", pub)
+		return
+	}
+
+	dashboardUsers = cfg.DashboardUsers
+	audit = newAuditLog(cfg.AuditFile)
+	approvals = newApprovalManager(cfg)
+	go approvals.runExpiry()
+	go outputs.runCleanup()
+	terminals = newTerminalBridge(cfg)
+	go terminals.runIdleCheck()
+	scripts = newScriptLibrary(filepath.Join(cfg.DataDir, "scripts"))
+	playbooks = newPlaybookLibrary(filepath.Join(cfg.DataDir, "playbooks"))
+	fetcher = newOutputFetcher(cfg)
+	agentQueries = newAgentRequests(cfg)
+	inventory = newAgentInventory(filepath.Join(cfg.DataDir, "agents.json"))
+	jobs = newJobStore(cfg)
+	go jobs.runMaintenance()
+	schedules = newScheduleStore(cfg)
+	go schedules.runLoop()
+	templates = newTemplateStore(cfg)
+	maintenance = newMaintenanceStore(cfg)
+	metrics = newMetricsStore(cfg)
+	go metrics.runMaintenance()
+
 	// 정적 파일 서빙
 	fs := http.FileServer(http.Dir(cfg.StaticDir))
 	http.Handle("/", fs)
@@ -77,6 +297,10 @@ func main() {
 	// 업데이트 파일 서빙
 	http.Handle("/updates/", http.StripPrefix("/updates/", http.FileServer(http.Dir(cfg.UpdatesDir))))
 
+	// 서명된 업데이트 매니페스트
+	updates := newUpdatePublisher(cfg)
+	http.HandleFunc("/updates/manifest", updates.handleManifest)
+
 	// 버전 확인 엔드포인트
 	http.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
 		handleVersion(w, r, cfg.AgentVersion)
@@ -85,6 +309,65 @@ func main() {
 	// 웹소켓 핸들러
 	http.HandleFunc("/ws-agent", handleAgentConnections)
 	http.HandleFunc("/ws-dashboard", handleDashboardConnections)
+	http.HandleFunc("/ws-terminal", terminals.handleTerminalConnections)
+
+	// 스크립트 라이브러리 API
+	http.HandleFunc("GET /api/scripts", handleListScripts)
+	http.HandleFunc("GET /api/scripts/{name}", handleGetScript)
+	http.HandleFunc("POST /api/scripts", handleSaveScript)
+
+	// 플레이북 API
+	http.HandleFunc("GET /api/playbooks", handleListPlaybooks)
+	http.HandleFunc("GET /api/playbooks/{name}", handleGetPlaybook)
+	http.HandleFunc("POST /api/playbooks", handleSavePlaybook)
+	http.HandleFunc("DELETE /api/playbooks/{name}", handleDeletePlaybook)
+
+	// 작업 목록/상세 API
+	http.HandleFunc("GET /api/jobs", handleListJobs)
+	http.HandleFunc("GET /api/jobs/{id}", handleGetJob)
+	http.HandleFunc("GET /api/jobs/{id}/buckets", handleJobBuckets)
+	http.HandleFunc("GET /api/jobs/{id}/diff", handleJobDiff)
+	http.HandleFunc("POST /api/jobs/{id}/resume", handleRolloutControl("resume"))
+	http.HandleFunc("POST /api/jobs/{id}/abort", handleRolloutControl("abort"))
+
+	// 에이전트 네트워크 상태 API
+	http.HandleFunc("GET /api/agents/{id}/network", handleAgentNetwork)
+
+	// 상태 기록 조회 API
+	http.HandleFunc("GET /api/agents/{id}/metrics", handleAgentMetrics)
+	http.HandleFunc("GET /api/groups/{group}/metrics", handleGroupMetrics)
+
+	// 원격 프로세스 목록/종료 API
+	http.HandleFunc("GET /api/agents/{id}/processes", handleAgentProcesses)
+	http.HandleFunc("POST /api/agents/{id}/processes/kill", handleKillProcess)
+
+	// 명령 히스토리 검색/내보내기 API
+	http.HandleFunc("GET /api/history", handleHistory)
+	http.HandleFunc("GET /api/history/export", handleHistoryExport)
+
+	// 예약 실행 API
+	http.HandleFunc("GET /api/schedules", handleListSchedules)
+	http.HandleFunc("GET /api/schedules/preview", handlePreviewSchedule)
+	http.HandleFunc("POST /api/schedules", handleSaveSchedule)
+	http.HandleFunc("POST /api/schedules/{id}/enable", handleEnableSchedule(true))
+	http.HandleFunc("POST /api/schedules/{id}/disable", handleEnableSchedule(false))
+	http.HandleFunc("DELETE /api/schedules/{id}", handleDeleteSchedule)
+
+	// 명령 템플릿 API
+	http.HandleFunc("GET /api/templates", handleListTemplates)
+	http.HandleFunc("GET /api/templates/{id}", handleGetTemplate)
+	http.HandleFunc("POST /api/templates", handleSaveTemplate)
+	http.HandleFunc("DELETE /api/templates/{id}", handleDeleteTemplate)
+	http.HandleFunc("PUT /api/templates/{id}/favorite", handleFavoriteTemplate(true))
+	http.HandleFunc("DELETE /api/templates/{id}/favorite", handleFavoriteTemplate(false))
+
+	// 정비 시간 API
+	http.HandleFunc("GET /api/maintenance", handleListMaintenance)
+	http.HandleFunc("POST /api/maintenance", handleSaveMaintenance)
+	http.HandleFunc("DELETE /api/maintenance/{group}", handleDeleteMaintenance)
+
+	// 잘린 명령 출력 전체 내려받기
+	http.HandleFunc("GET /api/output", fetcher.handleOutputFetch)
 
 	// 서버 시작
 	log.Printf("http server started on %s", cfg.GetListenAddr())
@@ -101,6 +384,13 @@ func handleVersion(w http.ResponseWriter, r *http.Request, version string) {
 	})
 }
 
+// writeJSON API 응답을 JSON으로 쓴다.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
 func handleAgentConnections(w http.ResponseWriter, r *http.Request) {
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -137,8 +427,12 @@ func handleAgentConnections(w http.ResponseWriter, r *http.Request) {
 			// 실제 구현에서는 ID로 추적해야 하지만, 여기서는 객체를 보냄
 			agent.Connected = false
 			broadcastAgentUpdate(agent)
+			jobs.AgentGone(agent.ID)
+			playbookRuns.AgentGone(agent.ID)
 		}
 		agentsMutex.Unlock()
+		// 대시보드 터미널 쓰기가 다른 에이전트 메시지 처리를 막지 않도록 잠금 밖에서 닫는다
+		terminals.AgentGone(agentID)
 		log.Println("Agent disconnected")
 	}()
 
@@ -151,6 +445,18 @@ func handleAgentConnections(w http.ResponseWriter, r *http.Request) {
 
 		msgType, _ := msg["type"].(string)
 
+		if msgType == "pty_opened" || msgType == "pty_output" || msgType == "pty_closed" {
+			// 느린 대시보드 터미널이 모든 에이전트 메시지를 막지 않도록 잠금 없이 전달
+			terminals.FromAgent(agentID, msgType, msg)
+			continue
+		}
+
+		if msgType == "output_fetch" {
+			// 전송 속도 조절을 위해 잠금 없이 전달
+			fetcher.Deliver(agent.ID, msg)
+			continue
+		}
+
 		agentsMutex.Lock()
 		agent.LastSeen = time.Now()
 
@@ -161,23 +467,65 @@ func handleAgentConnections(w http.ResponseWriter, r *http.Request) {
 			json.Unmarshal(infoData, &info)
 			agent.Info = &info
 			broadcastAgentUpdate(agent)
+			inventory.Seen(&info)
+			// 재부팅 등으로 다시 연결된 에이전트의 플레이북을 이어서 진행
+			playbookRuns.AgentRegistered(agent)
+			// 꺼져 있는 동안 보류한 명령을 보낸다
+			deliverHeld(agent)
 
 		case "status":
 			statusData, _ := json.Marshal(msg["status"])
 			var status AgentStatus
 			json.Unmarshal(statusData, &status)
 			agent.Status = &status
+			network.Record(agent.Info, &status)
+			metrics.Record(agent.Info, &status, time.Now())
 			broadcastAgentUpdate(agent)
 
+		case "command_started":
+			// 에이전트 큐에서 실행을 시작함
+			if job, ok := msg["job"].(map[string]interface{}); ok {
+				commandID, _ := job["id"].(string)
+				jobs.Running(commandID, agent.ID)
+			}
+
+		case "command_output":
+			// 구독 중인 대시보드에 출력 청크 전달
+			relayCommandOutput(msg, agent.ID)
+
 		case "command_result":
 			// 대시보드에 결과 전달
 			broadcastCommandResult(msg, agent.ID)
+
+		case "update_status":
+			handleUpdateStatus(msg, agent.ID)
+
+		case "jobs":
+			// 에이전트의 실행 중/대기 중 작업 목록
+			broadcastToDashboards(map[string]interface{}{
+				"type":     "agent_jobs",
+				"agent_id": agent.ID,
+				"jobs":     msg["jobs"],
+			})
+
+		case "processes":
+			agentQueries.Deliver(agent.ID, "processes", msg)
+
+		case "kill_result":
+			agentQueries.Deliver(agent.ID, "kill", msg)
 		}
 		agentsMutex.Unlock()
 	}
 }
 
 func handleDashboardConnections(w http.ResponseWriter, r *http.Request) {
+	user, ok := authenticateDashboard(r, dashboardUsers)
+	if !ok {
+		log.Printf("Dashboard authentication failed from %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Fatal(err)
@@ -185,18 +533,27 @@ func handleDashboardConnections(w http.ResponseWriter, r *http.Request) {
 	defer ws.Close()
 
 	dashboardsMutex.Lock()
-	dashboards[ws] = true
+	dashboards[ws] = user
 	dashboardsMutex.Unlock()
 
-	log.Println("New dashboard connected")
+	log.Printf("New dashboard connected (user: %s)", user.Name)
 
-	// 연결 즉시 현재 에이전트 목록 전송
+	// 연결 즉시 접속 사용자, 현재 에이전트 목록, 승인 대기 목록 전송
+	sendToDashboard(ws, map[string]interface{}{
+		"type": "session",
+		"user": user,
+	})
 	sendAgentList(ws)
+	sendToDashboard(ws, map[string]interface{}{
+		"type":      "approval_list",
+		"approvals": approvals.List(),
+	})
 
 	defer func() {
 		dashboardsMutex.Lock()
 		delete(dashboards, ws)
 		dashboardsMutex.Unlock()
+		outputs.Unsubscribe(ws)
 		log.Println("Dashboard disconnected")
 	}()
 
@@ -208,8 +565,22 @@ func handleDashboardConnections(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 
-		if msg["type"] == "command" {
-			handleCommand(msg)
+		switch msg["type"] {
+		case "command":
+			handleCommand(ws, msg, user)
+		case "approve":
+			handleApprovalDecision(ws, msg, user, true)
+		case "reject":
+			handleApprovalDecision(ws, msg, user, false)
+		case "cancel":
+			handleCancel(msg, user)
+		case "jobs":
+			handleJobsQuery(msg)
+		case "subscribe":
+			commandID, _ := msg["command_id"].(string)
+			if !outputs.Subscribe(commandID, ws) {
+				sendDashboardError(ws, "실행 중인 명령이 아닙니다: "+commandID)
+			}
 		}
 	}
 }
@@ -227,7 +598,7 @@ func sendAgentList(ws *websocket.Conn) {
 		Type:   "agent_list",
 		Agents: list,
 	}
-	ws.WriteJSON(msg)
+	sendToDashboard(ws, msg)
 }
 
 func broadcastAgentUpdate(agent *Agent) {
@@ -239,6 +610,17 @@ func broadcastAgentUpdate(agent *Agent) {
 }
 
 func broadcastCommandResult(originalMsg map[string]interface{}, agentID string) {
+	// 청크로 전송된 출력을 결과에 채움
+	if result, ok := originalMsg["result"].(map[string]interface{}); ok {
+		outputs.Complete(agentID, result)
+		var info *AgentInfo
+		if agent := agentByID(agentID); agent != nil {
+			info = agent.Info
+		}
+		jobs.Result(agentID, info, result)
+		playbookRuns.Result(result)
+	}
+
 	// 원본 메시지에 agent_id 추가하여 전송
 	resultMsg := map[string]interface{}{
 		"type":     "command_result",
@@ -248,6 +630,57 @@ func broadcastCommandResult(originalMsg map[string]interface{}, agentID string)
 	broadcastToDashboards(resultMsg)
 }
 
+// sendToDashboard 특정 대시보드에만 메시지 전송
+func sendToDashboard(ws *websocket.Conn, msg interface{}) {
+	dashboardsMutex.Lock()
+	defer dashboardsMutex.Unlock()
+
+	if err := ws.WriteJSON(msg); err != nil {
+		log.Println("write to dashboard error:", err)
+	}
+}
+
+func sendDashboardError(ws *websocket.Conn, errMsg string) {
+	sendToDashboard(ws, map[string]interface{}{
+		"type":  "error",
+		"error": errMsg,
+	})
+}
+
+// relayCommandOutput 출력 청크를 명령을 구독한 대시보드에만 전달
+func relayCommandOutput(msg map[string]interface{}, agentID string) {
+	data, _ := json.Marshal(msg["output"])
+	var chunk OutputChunk
+	if err := json.Unmarshal(data, &chunk); err != nil || chunk.ID == "" {
+		return
+	}
+
+	jobs.Running(chunk.ID, agentID)
+
+	relay := map[string]interface{}{
+		"type":     "command_output",
+		"agent_id": agentID,
+		"output":   chunk,
+	}
+	for _, ws := range outputs.Append(agentID, chunk) {
+		sendToDashboard(ws, relay)
+	}
+}
+
+// dashboardsOf 해당 사용자로 접속한 대시보드 목록
+func dashboardsOf(userName string) []*websocket.Conn {
+	dashboardsMutex.Lock()
+	defer dashboardsMutex.Unlock()
+
+	var list []*websocket.Conn
+	for ws, user := range dashboards {
+		if user.Name == userName {
+			list = append(list, ws)
+		}
+	}
+	return list
+}
+
 func broadcastToDashboards(msg interface{}) {
 	dashboardsMutex.Lock()
 	defer dashboardsMutex.Unlock()
@@ -262,31 +695,270 @@ func broadcastToDashboards(msg interface{}) {
 	}
 }
 
-func handleCommand(msg map[string]interface{}) {
-	command, _ := msg["command"].(string)
-	targetAgentID, _ := msg["agent_id"].(string)
+func handleCommand(ws *websocket.Conn, msg map[string]interface{}, user *config.DashboardUser) {
+	req, err := parseCommandRequest(msg)
+	if err == nil {
+		err = templates.Allowed(req, user)
+	}
+	if err == nil {
+		err = checkMaintenanceOverride(req, user)
+	}
+	if err != nil {
+		sendDashboardError(ws, err.Error())
+		return
+	}
+
+	agentsMutex.Lock()
+	targets := targetAgents(req)
+	reasons := approvals.Check(req, targets)
+	if len(reasons) == 0 {
+		id := sendCommandToAgents(targets, req, user.Name, "", ws)
+		audit.Record("command_sent", user.Name, map[string]interface{}{
+			"command_id": id,
+			"command":    req.Command,
+			"targets":    agentIDs(targets),
+		})
+		agentsMutex.Unlock()
+		return
+	}
+	ids := agentIDs(targets)
+	agentsMutex.Unlock()
 
+	// 위험 명령: 다른 사용자의 승인을 기다린다
+	p := approvals.Submit(req, ids, reasons, user.Name)
+	audit.Record("command_approval_requested", user.Name, map[string]interface{}{
+		"approval_id": p.ID,
+		"command":     req.Command,
+		"targets":     ids,
+		"reasons":     reasons,
+	})
+	broadcastApprovalUpdate(p)
+}
+
+// targetAgents 요청 대상에 해당하는 연결된 에이전트 목록 (agentsMutex를 잡은 상태에서 호출)
+func targetAgents(req CommandRequest) []*Agent {
+	var targets []*Agent
+	for _, agent := range agents {
+		if req.AgentID != "" && agent.ID != req.AgentID {
+			continue
+		}
+		if !req.matches(agent.Info) {
+			continue
+		}
+		targets = append(targets, agent)
+	}
+	return targets
+}
+
+// agentByID 연결된 에이전트를 ID로 찾는다 (agentsMutex를 잡은 상태에서 호출)
+func agentByID(id string) *Agent {
+	for _, agent := range agents {
+		if agent.ID == id {
+			return agent
+		}
+	}
+	return nil
+}
+
+func agentIDs(list []*Agent) []string {
+	ids := make([]string, 0, len(list))
+	for _, agent := range list {
+		ids = append(ids, agent.ID)
+	}
+	return ids
+}
+
+// sendCommandToAgents 대상 에이전트에 명령 전송 후 명령 ID 반환 (agentsMutex를 잡은 상태에서 호출)
+// subscribers 대시보드는 실행 중 출력 청크를 받는다.
+// deliver_within이 있으면 요청에 해당하지만 꺼져 있는 에이전트도 대상으로 보류한다.
+func sendCommandToAgents(targets []*Agent, req CommandRequest, requestedBy, approvedBy string, subscribers ...*websocket.Conn) string {
+	id := newID()
+	cmdMsg := commandMessage(id, req, requestedBy, approvedBy)
+
+	jobs.Create(id, req, targets, inventory.Offline(req), requestedBy, approvedBy)
+	// 정비 시간이 아닌 대상은 정비 시간이 될 때까지 보류한다
+	targets = holdOutsideWindow(id, req, targets, requestedBy)
+	outputs.Track(id, len(targets), subscribers...)
+	switch {
+	case req.Rollout != nil:
+		// 배치 단위로 나누어 보낸다
+		rollouts.Start(id, req, cmdMsg)
+	case req.Playbook != nil:
+		// 대상마다 단계를 차례로 보낸다
+		playbookRuns.Start(id, req, targets, requestedBy, approvedBy)
+	default:
+		for _, agent := range targets {
+			dispatchCommand(agent, id, req, cmdMsg)
+		}
+	}
+
+	// 대시보드에 실행 중인 명령 알림 (취소 버튼 표시용)
+	broadcastToDashboards(map[string]interface{}{
+		"type":       "command_dispatched",
+		"command_id": id,
+		"command":    req.Command,
+		"targets":    agentIDs(targets),
+		"timeout":    req.Timeout,
+	})
+	return id
+}
+
+// commandMessage 에이전트에 보낼 명령 메시지
+func commandMessage(id string, req CommandRequest, requestedBy, approvedBy string) map[string]interface{} {
 	// 설정 로드 (토큰 가져오기 위해)
 	cfg := config.Load()
 
+	cmdMsg := map[string]interface{}{
+		"type":         "command",
+		"token":        cfg.AuthToken,
+		"id":           id,
+		"command":      req.Command,
+		"requested_by": requestedBy,
+	}
+	if req.Timeout > 0 {
+		cmdMsg["timeout"] = req.Timeout
+	}
+	if req.Exec != nil {
+		cmdMsg["exec"] = req.Exec
+	}
+	if req.Priority != "" {
+		cmdMsg["priority"] = req.Priority
+	}
+	if req.Detached {
+		cmdMsg["detached"] = true
+	}
+	if approvedBy != "" {
+		cmdMsg["approved_by"] = approvedBy
+	}
+	return cmdMsg
+}
+
+// dispatchCommand 에이전트 한 대에 명령을 보내고 작업 대상 상태를 바꾼다 (agentsMutex를 잡은 상태에서 호출)
+func dispatchCommand(agent *Agent, id string, req CommandRequest, cmdMsg map[string]interface{}) {
+	if req.Script != nil {
+		// 스크립트는 에이전트 OS에 맞는 변형을 골라 보낸다
+		agentOS := ""
+		if agent.Info != nil {
+			agentOS = agent.Info.OS
+		}
+		payload, err := scripts.Payload(req.Script, agentOS)
+		if err != nil {
+			reportDispatchFailure(agent.ID, id, req, err)
+			return
+		}
+		withScript := make(map[string]interface{}, len(cmdMsg)+1)
+		for k, v := range cmdMsg {
+			withScript[k] = v
+		}
+		withScript["script"] = payload
+		cmdMsg = withScript
+	}
+
+	// JSON 형태로 전송
+	msgBytes, _ := json.Marshal(cmdMsg)
+	if err := agent.Conn.WriteMessage(websocket.TextMessage, msgBytes); err != nil {
+		log.Println("write to agent error:", err)
+		jobs.Offline(id, agent.ID, err.Error())
+		return
+	}
+	jobs.Sent(id, agent.ID)
+}
+
+// reportDispatchFailure 에이전트에 보내지 못한 명령의 실패 결과를 대시보드에 알린다.
+func reportDispatchFailure(agentID, commandID string, req CommandRequest, cause error) {
+	now := time.Now()
+	result := map[string]interface{}{
+		"id":          commandID,
+		"command":     req.Command,
+		"status":      "start_failed",
+		"exit_code":   -1,
+		"stdout":      "",
+		"stderr":      "",
+		"error":       cause.Error(),
+		"started_at":  now,
+		"ended_at":    now,
+		"duration_ms": 0,
+		"timestamp":   now,
+	}
+	if req.Script != nil {
+		result["script"] = map[string]interface{}{
+			"name":    req.Script.Name,
+			"version": req.Script.Version,
+		}
+	}
+	broadcastCommandResult(map[string]interface{}{"result": result}, agentID)
+}
+
+// handleCancel 실행 중인 명령 취소 요청을 대상 에이전트(없으면 전체)에 전달
+func handleCancel(msg map[string]interface{}, user *config.DashboardUser) {
+	commandID, _ := msg["command_id"].(string)
+	if commandID == "" {
+		return
+	}
+	req := CommandRequest{}
+	req.AgentID, _ = msg["agent_id"].(string)
+	req.Group, _ = msg["group"].(string)
+
+	cfg := config.Load()
+	cancelBytes, _ := json.Marshal(map[string]string{
+		"type":  "cancel",
+		"token": cfg.AuthToken,
+		"id":    commandID,
+	})
+
 	agentsMutex.Lock()
 	defer agentsMutex.Unlock()
 
-	// 명령 메시지 생성 (JSON)
-	cmdMsg := map[string]string{
-		"token":   cfg.AuthToken,
-		"command": command,
+	targets := targetAgents(req)
+	for _, agent := range targets {
+		if err := agent.Conn.WriteMessage(websocket.TextMessage, cancelBytes); err != nil {
+			log.Println("write to agent error:", err)
+		}
 	}
-	cmdBytes, _ := json.Marshal(cmdMsg)
+	audit.Record("command_cancel", user.Name, map[string]interface{}{
+		"command_id": commandID,
+		"targets":    agentIDs(targets),
+	})
 
-	for _, agent := range agents {
-		// 특정 에이전트에게만 전송하거나 전체 전송
-		if targetAgentID == "" || agent.ID == targetAgentID {
-			// JSON 형태로 전송
-			err := agent.Conn.WriteMessage(websocket.TextMessage, cmdBytes)
-			if err != nil {
-				log.Println("write to agent error:", err)
-			}
+	// 순차 전송 중이면 남은 배치도 보내지 않는다
+	if req.AgentID == "" && req.Group == "" {
+		rollouts.Control(commandID, rolloutControl{action: "abort", user: user.Name})
+		// 보류 중이거나 재시도를 기다리는 대상에도 보내지 않고, 이후 실패한 대상도 재시도하지 않는다
+		jobs.Cancel(commandID, user.Name)
+	}
+	// 플레이북이면 실행 중인 단계를 취소하고 남은 단계는 건너뛴다
+	if req.AgentID == "" && req.Group == "" {
+		playbookRuns.Cancel(commandID, nil, user.Name)
+	} else {
+		playbookRuns.Cancel(commandID, agentIDs(targets), user.Name)
+	}
+}
+
+// handleJobsQuery 에이전트에 작업 목록 조회 요청 전달
+func handleJobsQuery(msg map[string]interface{}) {
+	req := CommandRequest{}
+	req.AgentID, _ = msg["agent_id"].(string)
+	req.Group, _ = msg["group"].(string)
+
+	cfg := config.Load()
+	queryBytes, _ := json.Marshal(map[string]string{
+		"type":  "jobs",
+		"token": cfg.AuthToken,
+	})
+
+	agentsMutex.Lock()
+	defer agentsMutex.Unlock()
+
+	for _, agent := range targetAgents(req) {
+		if err := agent.Conn.WriteMessage(websocket.TextMessage, queryBytes); err != nil {
+			log.Println("write to agent error:", err)
 		}
 	}
 }
+
+// newID 임의의 식별자 생성
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
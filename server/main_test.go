@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestParseCommandRequestRolloutCombinations(t *testing.T) {
+	rollout := map[string]interface{}{"batch_size": 2}
+	retry := map[string]interface{}{"max_attempts": 3}
+	tests := []struct {
+		name    string
+		msg     map[string]interface{}
+		wantErr bool
+	}{
+		{"rollout", map[string]interface{}{"command": "hostname", "rollout": rollout}, false},
+		{"retry", map[string]interface{}{"command": "hostname", "retry": retry}, false},
+		{"rollout+retry", map[string]interface{}{"command": "hostname", "rollout": rollout, "retry": retry}, true},
+		{"rollout+deliver_within", map[string]interface{}{"command": "hostname", "rollout": rollout, "deliver_within": 60}, true},
+		{"rollout+maintenance", map[string]interface{}{"command": "hostname", "rollout": rollout, "maintenance": true}, true},
+	}
+	for _, tt := range tests {
+		_, err := parseCommandRequest(tt.msg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
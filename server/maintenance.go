@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+// 다음 정비 시간을 찾는 최대 기간 (일)
+const maintenanceSearchDays = 60
+
+var (
+	// errMaintenanceStorage 정비 시간 파일 저장 실패 (요청 오류가 아닌 서버 오류)
+	errMaintenanceStorage = errors.New("정비 시간 저장 실패")
+	// errMaintenanceNotFound 정비 시간이 정의되지 않은 그룹
+	errMaintenanceNotFound = errors.New("정비 시간이 없는 그룹입니다")
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// WeeklyRange 매주 반복하는 정비 시간. end가 start보다 이르면 다음 날 end까지.
+type WeeklyRange struct {
+	Days  []string `json:"days"`  // sun, mon, ... sat
+	Start string   `json:"start"` // HH:MM
+	End   string   `json:"end"`   // HH:MM (24:00 허용)
+}
+
+// Blackout 정비 시간이 있어도 실행하지 않는 날 (시험일 등)
+type Blackout struct {
+	Date   string `json:"date"` // YYYY-MM-DD (그룹 시간대 기준)
+	Reason string `json:"reason,omitempty"`
+}
+
+// MaintenanceWindow 그룹의 정비 시간. 정의되지 않은 그룹은 언제든 실행한다.
+type MaintenanceWindow struct {
+	Group     string        `json:"group"`
+	Timezone  string        `json:"timezone,omitempty"` // IANA 시간대, 비어 있으면 서버 로컬 시간
+	Weekly    []WeeklyRange `json:"weekly"`             // 비어 있으면 금지일을 뺀 모든 시간
+	Blackouts []Blackout    `json:"blackouts,omitempty"`
+	UpdatedBy string        `json:"updated_by"`
+	UpdatedAt time.Time     `json:"updated_at"`
+
+	Open     bool       `json:"open"`                // 지금 정비 시간인지 (조회 시 계산)
+	NextOpen *time.Time `json:"next_open,omitempty"` // 닫혀 있으면 다음 시작 시각 (조회 시 계산)
+}
+
+func (m *MaintenanceWindow) location() (*time.Location, error) {
+	if m.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(m.Timezone)
+}
+
+// parseClock HH:MM을 자정부터의 분으로 바꾼다.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || len(s) != 5 || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("잘못된 시각 %q (HH:MM)", s)
+	}
+	return h*60 + m, nil
+}
+
+// validate 정비 시간 내용을 검사한다.
+func (m *MaintenanceWindow) validate() error {
+	m.Group = strings.TrimSpace(m.Group)
+	if m.Group == "" {
+		return fmt.Errorf("그룹 이름이 비어 있습니다")
+	}
+	if _, err := m.location(); err != nil {
+		return fmt.Errorf("알 수 없는 시간대 %q", m.Timezone)
+	}
+	for _, r := range m.Weekly {
+		if len(r.Days) == 0 {
+			return fmt.Errorf("정비 시간에 요일(days)이 없습니다")
+		}
+		for _, d := range r.Days {
+			if _, ok := weekdayNames[strings.ToLower(d)]; !ok {
+				return fmt.Errorf("알 수 없는 요일 %q", d)
+			}
+		}
+		start, err := parseClock(r.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(r.End)
+		if err != nil {
+			return err
+		}
+		if start == end || start == 24*60 {
+			return fmt.Errorf("정비 시간 %s-%s가 올바르지 않습니다", r.Start, r.End)
+		}
+	}
+	for _, b := range m.Blackouts {
+		if _, err := time.Parse("2006-01-02", b.Date); err != nil {
+			return fmt.Errorf("잘못된 금지일 %q (YYYY-MM-DD)", b.Date)
+		}
+	}
+	if m.Weekly == nil {
+		m.Weekly = []WeeklyRange{}
+	}
+	return nil
+}
+
+// next now를 포함하거나 now 이후에 시작하는 가장 이른 정비 시간.
+// 금지일에 시작하는 정비 시간은 건너뛴다. 찾지 못하면 zero.
+func (m *MaintenanceWindow) next(now time.Time) (start, end time.Time) {
+	loc, err := m.location()
+	if err != nil {
+		return
+	}
+	blackout := map[string]bool{}
+	for _, b := range m.Blackouts {
+		blackout[b.Date] = true
+	}
+	ranges := m.Weekly
+	if len(ranges) == 0 {
+		ranges = []WeeklyRange{{Days: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}, Start: "00:00", End: "24:00"}}
+	}
+
+	local := now.In(loc)
+	// 전날 밤에 시작해 자정을 넘긴 정비 시간도 확인한다
+	day := time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, 0, 0, loc)
+	for i := 0; i <= maintenanceSearchDays; i++ {
+		date := day.AddDate(0, 0, i)
+		if blackout[date.Format("2006-01-02")] {
+			continue
+		}
+		for _, r := range ranges {
+			if !r.on(date.Weekday()) {
+				continue
+			}
+			s, _ := parseClock(r.Start)
+			e, _ := parseClock(r.End)
+			if e <= s {
+				e += 24 * 60
+			}
+			rs := time.Date(date.Year(), date.Month(), date.Day(), 0, s, 0, 0, loc)
+			re := time.Date(date.Year(), date.Month(), date.Day(), 0, e, 0, 0, loc)
+			if !re.After(now) {
+				continue
+			}
+			if start.IsZero() || rs.Before(start) {
+				start, end = rs, re
+			}
+		}
+		// 하루 단위로 확인하므로 이미 찾은 시작 시각보다 늦은 날은 볼 필요가 없다
+		if !start.IsZero() && date.After(start) {
+			break
+		}
+	}
+	return start, end
+}
+
+func (r *WeeklyRange) on(day time.Weekday) bool {
+	for _, d := range r.Days {
+		if weekdayNames[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceStore data_dir/maintenance.json 에 저장되는 그룹별 정비 시간
+type maintenanceStore struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]*MaintenanceWindow
+}
+
+func newMaintenanceStore(cfg *config.Config) *maintenanceStore {
+	s := &maintenanceStore{
+		path:  filepath.Join(cfg.DataDir, "maintenance.json"),
+		items: make(map[string]*MaintenanceWindow),
+	}
+	var list []*MaintenanceWindow
+	if err := loadJSONFile(s.path, &list); err != nil && !os.IsNotExist(err) {
+		log.Printf("정비 시간 목록을 읽을 수 없습니다: %v", err)
+	}
+	for _, item := range list {
+		s.items[item.Group] = item
+	}
+	return s
+}
+
+// save mu를 잡은 상태에서 호출
+func (s *maintenanceStore) save() error {
+	list := make([]*MaintenanceWindow, 0, len(s.items))
+	for _, item := range s.items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Group < list[j].Group })
+	if err := saveJSONFile(s.path, list); err != nil {
+		log.Printf("정비 시간 저장 실패: %v", err)
+		return fmt.Errorf("%w: %v", errMaintenanceStorage, err)
+	}
+	return nil
+}
+
+// withStatus 지금 열려 있는지와 다음 시작 시각을 채운 사본
+func (m *MaintenanceWindow) withStatus(now time.Time) *MaintenanceWindow {
+	copied := *m
+	start, _ := m.next(now)
+	copied.Open = !start.IsZero() && !start.After(now)
+	copied.NextOpen = nil
+	if !start.IsZero() && !copied.Open {
+		copied.NextOpen = &start
+	}
+	return &copied
+}
+
+// List 그룹 이름순 정비 시간 목록
+func (s *maintenanceStore) List() []*MaintenanceWindow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	list := make([]*MaintenanceWindow, 0, len(s.items))
+	for _, item := range s.items {
+		list = append(list, item.withStatus(now))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Group < list[j].Group })
+	return list
+}
+
+// Save 그룹의 정비 시간을 저장한다 (있으면 바꾼다).
+func (s *maintenanceStore) Save(item MaintenanceWindow, user string) (*MaintenanceWindow, error) {
+	if err := item.validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	item.UpdatedBy = user
+	item.UpdatedAt = now
+	item.Open = false
+	item.NextOpen = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := s.items[item.Group]
+	s.items[item.Group] = &item
+	if err := s.save(); err != nil {
+		if prev != nil {
+			s.items[item.Group] = prev
+		} else {
+			delete(s.items, item.Group)
+		}
+		return nil, err
+	}
+	return item.withStatus(now), nil
+}
+
+// Delete 그룹의 정비 시간을 지운다 (이후 언제든 실행).
+func (s *maintenanceStore) Delete(group string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[group]
+	if !ok {
+		return fmt.Errorf("%w: %s", errMaintenanceNotFound, group)
+	}
+	delete(s.items, group)
+	if err := s.save(); err != nil {
+		s.items[group] = item
+		return err
+	}
+	return nil
+}
+
+// Check 그룹이 지금 정비 시간인지 확인한다. 닫혀 있으면 다음 시작 시각 (찾지 못하면 nil).
+func (s *maintenanceStore) Check(group string, now time.Time) (open bool, next *time.Time) {
+	s.mu.Lock()
+	item, ok := s.items[group]
+	s.mu.Unlock()
+
+	if !ok {
+		return true, nil
+	}
+	start, _ := item.next(now)
+	if start.IsZero() {
+		return false, nil
+	}
+	if !start.After(now) {
+		return true, nil
+	}
+	return false, &start
+}
+
+// holdOutsideWindow 정비 작업에서 정비 시간이 아닌 대상을 held로 바꾸고 지금 보낼 대상만 반환한다.
+// 긴급 실행(override_reason)이면 모두 보내고 감사 로그에 기록한다 (agentsMutex를 잡은 상태에서 호출).
+func holdOutsideWindow(jobID string, req CommandRequest, targets []*Agent, requestedBy string) []*Agent {
+	if !req.Maintenance {
+		return targets
+	}
+	if req.OverrideReason != "" {
+		audit.Record("maintenance_override", requestedBy, map[string]interface{}{
+			"command_id": jobID,
+			"command":    req.Command,
+			"reason":     req.OverrideReason,
+			"targets":    agentIDs(targets),
+		})
+		return targets
+	}
+	now := time.Now()
+	var ready []*Agent
+	for _, agent := range targets {
+		if deferToWindow(jobID, agent, now) {
+			continue
+		}
+		ready = append(ready, agent)
+	}
+	return ready
+}
+
+// deferToWindow 정비 시간이 아니면 대상을 다음 정비 시간까지 held로 바꾸고 true
+func deferToWindow(jobID string, agent *Agent, now time.Time) bool {
+	group := ""
+	if agent.Info != nil {
+		group = agent.Info.Group
+	}
+	open, next := maintenance.Check(group, now)
+	if open {
+		return false
+	}
+	jobs.Defer(jobID, agent.ID, agent.Info, next)
+	return true
+}
+
+// checkMaintenanceOverride 긴급 실행은 관리자나 승인 권한이 있는 사용자만 할 수 있다.
+func checkMaintenanceOverride(req CommandRequest, user *config.DashboardUser) error {
+	if req.OverrideReason == "" || user.Role == "admin" || user.CanApprove {
+		return nil
+	}
+	return fmt.Errorf("%s 사용자는 정비 시간을 무시하고 실행할 수 없습니다", user.Name)
+}
+
+// maintenanceDeferred 정비 작업이고 긴급 실행이 아니면 true (보낼 때마다 정비 시간을 확인)
+func maintenanceDeferred(req CommandRequest) bool {
+	return req.Maintenance && req.OverrideReason == ""
+}
+
+func maintenanceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errMaintenanceStorage):
+		return http.StatusInternalServerError
+	case errors.Is(err, errMaintenanceNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// handleListMaintenance GET /api/maintenance
+func handleListMaintenance(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, maintenance.List())
+}
+
+// handleSaveMaintenance POST /api/maintenance
+func handleSaveMaintenance(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok || !requireAdmin(w, user, "saving maintenance windows") {
+		return
+	}
+	var item MaintenanceWindow
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&item); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	saved, err := maintenance.Save(item, user.Name)
+	if err != nil {
+		http.Error(w, err.Error(), maintenanceErrorStatus(err))
+		return
+	}
+	audit.Record("maintenance_saved", user.Name, map[string]interface{}{
+		"group":     saved.Group,
+		"timezone":  saved.Timezone,
+		"weekly":    saved.Weekly,
+		"blackouts": saved.Blackouts,
+	})
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// handleDeleteMaintenance DELETE /api/maintenance/{group}
+func handleDeleteMaintenance(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok || !requireAdmin(w, user, "deleting maintenance windows") {
+		return
+	}
+	group := r.PathValue("group")
+	if err := maintenance.Delete(group); err != nil {
+		http.Error(w, err.Error(), maintenanceErrorStatus(err))
+		return
+	}
+	audit.Record("maintenance_deleted", user.Name, map[string]interface{}{
+		"group": group,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 에이전트마다 보관하는 네트워크 기록 수 (상태 주기 5초 기준 1시간)
+const networkHistorySize = 720
+
+// NetInterface 에이전트가 보고한 네트워크 인터페이스 상태 (속도는 초당 값, 오류는 누적)
+type NetInterface struct {
+	Name     string   `json:"name"`
+	MAC      string   `json:"mac,omitempty"`
+	IPs      []string `json:"ips,omitempty"`
+	Up       bool     `json:"up"`
+	Loopback bool     `json:"loopback,omitempty"`
+	MTU      int      `json:"mtu,omitempty"`
+
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
+	RxErrors        uint64  `json:"rx_errors"`
+	TxErrors        uint64  `json:"tx_errors"`
+	RxDropped       uint64  `json:"rx_dropped"`
+	TxDropped       uint64  `json:"tx_dropped"`
+}
+
+// NetworkSample 상태 보고 한 번의 네트워크 요약 (루프백 제외 합계)
+type NetworkSample struct {
+	Time          time.Time `json:"time"`
+	LatencyMs     float64   `json:"latency_ms,omitempty"`
+	RxBytesPerSec float64   `json:"rx_bytes_per_sec"`
+	TxBytesPerSec float64   `json:"tx_bytes_per_sec"`
+	Errors        uint64    `json:"errors"`  // 누적 송수신 오류
+	Dropped       uint64    `json:"dropped"` // 누적 송수신 버림
+}
+
+// AgentNetwork 에이전트 한 대의 최근 인터페이스 상태와 기록
+type AgentNetwork struct {
+	Key        string          `json:"key"` // MAC 주소 또는 호스트 이름 (재접속해도 같음)
+	Hostname   string          `json:"hostname"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	LatencyMs  float64         `json:"latency_ms,omitempty"`
+	Interfaces []NetInterface  `json:"interfaces"`
+	Samples    []NetworkSample `json:"samples"` // 오래된 것부터
+}
+
+// networkStore 에이전트별 네트워크 상태를 메모리에 보관한다 (재접속해도 이어서 기록).
+type networkStore struct {
+	mu    sync.Mutex
+	items map[string]*AgentNetwork
+}
+
+func newNetworkStore() *networkStore {
+	return &networkStore{items: make(map[string]*AgentNetwork)}
+}
+
+// Record 상태 보고의 네트워크 정보를 기록한다.
+func (s *networkStore) Record(info *AgentInfo, status *AgentStatus) {
+	if info == nil || (status.Network == nil && status.LatencyMs == 0) {
+		return
+	}
+	key := inventoryKey(info)
+	now := time.Now()
+	sample := NetworkSample{Time: now, LatencyMs: status.LatencyMs}
+	for _, ni := range status.Network {
+		if ni.Loopback {
+			continue
+		}
+		sample.RxBytesPerSec += ni.RxBytesPerSec
+		sample.TxBytesPerSec += ni.TxBytesPerSec
+		sample.Errors += ni.RxErrors + ni.TxErrors
+		sample.Dropped += ni.RxDropped + ni.TxDropped
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		item = &AgentNetwork{Key: key}
+		s.items[key] = item
+	}
+	item.Hostname = info.Hostname
+	item.UpdatedAt = now
+	item.LatencyMs = status.LatencyMs
+	item.Interfaces = status.Network
+	item.Samples = append(item.Samples, sample)
+	if len(item.Samples) > networkHistorySize {
+		item.Samples = append(item.Samples[:0:0], item.Samples[len(item.Samples)-networkHistorySize:]...)
+	}
+}
+
+// Get 키(MAC 주소 또는 호스트 이름)로 찾은 사본
+func (s *networkStore) Get(key string) (*AgentNetwork, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	copied := *item
+	copied.Interfaces = append([]NetInterface(nil), item.Interfaces...)
+	copied.Samples = append([]NetworkSample(nil), item.Samples...)
+	return &copied, true
+}
+
+// handleAgentNetwork GET /api/agents/{id}/network
+// {id}는 연결된 에이전트 ID, 또는 꺼진 에이전트의 MAC 주소나 호스트 이름
+func handleAgentNetwork(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	key := r.PathValue("id")
+	agentsMutex.Lock()
+	if agent := agentByID(key); agent != nil && agent.Info != nil {
+		key = inventoryKey(agent.Info)
+	}
+	agentsMutex.Unlock()
+
+	item, ok := network.Get(key)
+	if !ok {
+		http.Error(w, "no network data for this agent", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+)
+
+// 결과가 오지 않은 명령 출력/구독을 정리하기까지의 시간
+const outputStreamTTL = 24 * time.Hour
+
+// 에이전트 한 대의 명령 출력을 조립할 때 stdout/stderr 각각 모아 두는 최대 크기
+// (에이전트의 max_inline_output이 0이거나 에이전트가 한도를 지키지 않아도 서버 메모리를 보호)
+const maxAssembledOutput = 1 << 20
+
+// OutputChunk 에이전트가 보낸 실행 중 출력 조각
+type OutputChunk struct {
+	ID     string `json:"id"`
+	Seq    int    `json:"seq"`
+	Stream string `json:"stream"` // stdout | stderr
+	Data   string `json:"data"`
+}
+
+// commandStream 에이전트 한 대의 명령 출력 조립 상태
+type commandStream struct {
+	stdout    strings.Builder
+	stderr    strings.Builder
+	truncated bool // 조립 한도를 넘어 버린 출력이 있음
+	nextSeq   int
+	missing   int // 누락된 청크 수
+	updated   time.Time
+}
+
+// commandSubscription 명령 출력을 받을 대시보드 세션
+type commandSubscription struct {
+	dashboards map[*websocket.Conn]bool
+	remaining  int // 결과를 기다리는 에이전트 수
+	created    time.Time
+}
+
+type streamKey struct {
+	commandID string
+	agentID   string
+}
+
+// outputAssembler 청크를 대시보드 구독자에게 중계하고 최종 결과를 조립한다.
+type outputAssembler struct {
+	mu      sync.Mutex
+	streams map[streamKey]*commandStream
+	subs    map[string]*commandSubscription
+}
+
+func newOutputAssembler() *outputAssembler {
+	return &outputAssembler{
+		streams: make(map[streamKey]*commandStream),
+		subs:    make(map[string]*commandSubscription),
+	}
+}
+
+// Track 전송된 명령을 등록하고 출력을 받을 대시보드를 구독시킨다.
+func (o *outputAssembler) Track(commandID string, targets int, dashboards ...*websocket.Conn) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sub := &commandSubscription{
+		dashboards: make(map[*websocket.Conn]bool),
+		remaining:  targets,
+		created:    time.Now(),
+	}
+	for _, ws := range dashboards {
+		if ws != nil {
+			sub.dashboards[ws] = true
+		}
+	}
+	o.subs[commandID] = sub
+}
+
+// Subscribe 대시보드가 진행 중인 명령의 출력을 구독한다.
+func (o *outputAssembler) Subscribe(commandID string, ws *websocket.Conn) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	sub, ok := o.subs[commandID]
+	if !ok {
+		return false
+	}
+	sub.dashboards[ws] = true
+	return true
+}
+
+// Unsubscribe 연결이 끊긴 대시보드를 모든 구독에서 제거한다.
+func (o *outputAssembler) Unsubscribe(ws *websocket.Conn) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, sub := range o.subs {
+		delete(sub.dashboards, ws)
+	}
+}
+
+// Append 청크를 조립 버퍼에 추가하고 구독 중인 대시보드 목록을 반환한다.
+func (o *outputAssembler) Append(agentID string, chunk OutputChunk) []*websocket.Conn {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key := streamKey{chunk.ID, agentID}
+	st, ok := o.streams[key]
+	if !ok {
+		st = &commandStream{}
+		o.streams[key] = st
+	}
+	if chunk.Seq != st.nextSeq {
+		log.Printf("output %s from %s: expected seq %d, got %d", chunk.ID, agentID, st.nextSeq, chunk.Seq)
+		if chunk.Seq > st.nextSeq {
+			st.missing += chunk.Seq - st.nextSeq
+		}
+	}
+	st.nextSeq = chunk.Seq + 1
+	st.updated = time.Now()
+	buf := &st.stdout
+	if chunk.Stream == "stderr" {
+		buf = &st.stderr
+	}
+	data := chunk.Data
+	if room := maxAssembledOutput - buf.Len(); len(data) > room {
+		data = truncateUTF8(data, max(room, 0))
+		st.truncated = true
+	}
+	buf.WriteString(data)
+
+	var list []*websocket.Conn
+	if sub, ok := o.subs[chunk.ID]; ok {
+		for ws := range sub.dashboards {
+			list = append(list, ws)
+		}
+	}
+	return list
+}
+
+// Complete 최종 결과에 조립된 출력을 채우고 조립 상태를 정리한다.
+func (o *outputAssembler) Complete(agentID string, result map[string]interface{}) {
+	commandID, _ := result["id"].(string)
+	if commandID == "" {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if streamed, _ := result["streamed"].(bool); streamed {
+		key := streamKey{commandID, agentID}
+		st := o.streams[key]
+		if st == nil {
+			st = &commandStream{}
+		}
+		result["stdout"] = st.stdout.String()
+		result["stderr"] = st.stderr.String()
+		if st.truncated {
+			result["truncated"] = true
+			log.Printf("output %s from %s: truncated at %d bytes per stream", commandID, agentID, maxAssembledOutput)
+		}
+		if chunks, ok := result["chunks"].(float64); ok && int(chunks) != st.nextSeq-st.missing {
+			result["incomplete"] = true
+			log.Printf("output %s from %s: received %d of %d chunks", commandID, agentID, st.nextSeq-st.missing, int(chunks))
+		}
+		delete(o.streams, key)
+	}
+
+	if sub, ok := o.subs[commandID]; ok {
+		sub.remaining--
+		if sub.remaining <= 0 {
+			delete(o.subs, commandID)
+		}
+	}
+}
+
+// runCleanup 결과가 오지 않은 오래된 조립 상태와 구독을 주기적으로 정리한다.
+func (o *outputAssembler) runCleanup() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		o.mu.Lock()
+		for key, st := range o.streams {
+			if now.Sub(st.updated) > outputStreamTTL {
+				delete(o.streams, key)
+			}
+		}
+		for id, sub := range o.subs {
+			if now.Sub(sub.created) > outputStreamTTL {
+				delete(o.subs, id)
+			}
+		}
+		o.mu.Unlock()
+	}
+}
+
+// truncateUTF8 s를 n바이트 이하로 자르되 멀티바이트 문자 중간에서 자르지 않는다.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+// 에이전트가 출력 파일 조각을 보내지 않으면 전송을 중단하기까지의 시간
+const fetchChunkTimeout = 30 * time.Second
+
+// FetchChunk 에이전트가 보낸 출력 파일 조각
+type FetchChunk struct {
+	RequestID string `json:"request_id"`
+	Seq       int    `json:"seq"`
+	Data      string `json:"data"` // base64
+	Size      int64  `json:"size"`
+	EOF       bool   `json:"eof"`
+	Error     string `json:"error"`
+}
+
+type outputFetch struct {
+	agentID string
+	chunks  chan FetchChunk
+	done    chan struct{} // HTTP 응답이 끝나면 닫힘
+}
+
+// outputFetcher 잘린 명령 출력 전체를 에이전트에서 받아 HTTP 응답으로 전달한다.
+type outputFetcher struct {
+	mu      sync.Mutex
+	pending map[string]*outputFetch
+	token   string
+}
+
+func newOutputFetcher(cfg *config.Config) *outputFetcher {
+	return &outputFetcher{
+		pending: make(map[string]*outputFetch),
+		token:   cfg.AuthToken,
+	}
+}
+
+// start 에이전트에 출력 파일 전송을 요청한다.
+func (f *outputFetcher) start(agentID, handle, stream string) (string, *outputFetch, error) {
+	fetch := &outputFetch{
+		agentID: agentID,
+		chunks:  make(chan FetchChunk, 4),
+		done:    make(chan struct{}),
+	}
+	id := newID()
+
+	f.mu.Lock()
+	f.pending[id] = fetch
+	f.mu.Unlock()
+
+	agentsMutex.Lock()
+	defer agentsMutex.Unlock()
+
+	for _, agent := range agents {
+		if agent.ID != agentID {
+			continue
+		}
+		err := agent.Conn.WriteJSON(map[string]string{
+			"type":       "fetch_output",
+			"token":      f.token,
+			"request_id": id,
+			"handle":     handle,
+			"stream":     stream,
+		})
+		if err != nil {
+			f.finish(id)
+			return "", nil, err
+		}
+		return id, fetch, nil
+	}
+	f.finish(id)
+	return "", nil, fmt.Errorf("agent %s not connected", agentID)
+}
+
+func (f *outputFetcher) finish(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if fetch, ok := f.pending[id]; ok {
+		close(fetch.done)
+		delete(f.pending, id)
+	}
+}
+
+// Deliver 에이전트가 보낸 조각을 기다리는 요청에 전달한다.
+// HTTP 응답 속도에 맞춰 에이전트 수신 루프를 늦추므로 agentsMutex 없이 호출해야 한다.
+func (f *outputFetcher) Deliver(agentID string, msg map[string]interface{}) {
+	data, _ := json.Marshal(msg["fetch"])
+	var chunk FetchChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return
+	}
+
+	f.mu.Lock()
+	fetch, ok := f.pending[chunk.RequestID]
+	f.mu.Unlock()
+	if !ok || fetch.agentID != agentID {
+		return
+	}
+
+	select {
+	case fetch.chunks <- chunk:
+	case <-fetch.done:
+	case <-time.After(fetchChunkTimeout):
+		log.Printf("output fetch %s: receiver stalled, dropping", chunk.RequestID)
+		f.finish(chunk.RequestID)
+	}
+}
+
+// handleOutputFetch GET /api/output?agent_id=...&handle=...&stream=stdout|stderr
+func (f *outputFetcher) handleOutputFetch(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	agentID, handle, stream := q.Get("agent_id"), q.Get("handle"), q.Get("stream")
+	if stream == "" {
+		stream = "stdout"
+	}
+	if agentID == "" || handle == "" || (stream != "stdout" && stream != "stderr") {
+		http.Error(w, "agent_id, handle and stream (stdout|stderr) required", http.StatusBadRequest)
+		return
+	}
+
+	id, fetch, err := f.start(agentID, handle, stream)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer f.finish(id)
+
+	audit.Record("output_fetched", user.Name, map[string]interface{}{
+		"agent_id": agentID,
+		"handle":   handle,
+		"stream":   stream,
+	})
+
+	started := false
+	for seq := 0; ; seq++ {
+		var chunk FetchChunk
+		select {
+		case chunk = <-fetch.chunks:
+		case <-time.After(fetchChunkTimeout):
+			if !started {
+				http.Error(w, "agent did not respond", http.StatusGatewayTimeout)
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+
+		if chunk.Error != "" && !started {
+			http.Error(w, chunk.Error, http.StatusNotFound)
+			return
+		}
+		if chunk.Seq != seq || chunk.Error != "" {
+			// 헤더를 이미 보냈으므로 연결을 끊어 불완전한 응답임을 알린다
+			log.Printf("output fetch %s: broken stream (seq %d, error %q)", id, chunk.Seq, chunk.Error)
+			panic(http.ErrAbortHandler)
+		}
+
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", handle+"."+stream+".txt"))
+			if chunk.Size > 0 {
+				w.Header().Set("Content-Length", strconv.FormatInt(chunk.Size, 10))
+			}
+		}
+		data, err := base64.StdEncoding.DecodeString(chunk.Data)
+		if err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+		if chunk.EOF {
+			return
+		}
+	}
+}
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gopc-server/config"
+)
+
+// 플레이북 단계 상태
+const (
+	StepPending   = "pending"
+	StepRunning   = "running"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	StepSkipped   = "skipped"
+)
+
+// 재부팅 명령 자체의 실행 제한 시간 (초)
+const rebootCommandTimeout = 60
+
+// StepStatus 대상 에이전트 한 대에서의 플레이북 단계 진행 상태
+type StepStatus struct {
+	Name      string                 `json:"name"`
+	Type      string                 `json:"type"`
+	State     string                 `json:"state"`
+	CommandID string                 `json:"command_id,omitempty"` // 에이전트에 보낸 단계 명령 ID
+	ExitCode  *int                   `json:"exit_code,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	StartedAt *time.Time             `json:"started_at,omitempty"`
+	EndedAt   *time.Time             `json:"ended_at,omitempty"`
+	Result    map[string]interface{} `json:"result,omitempty"`
+}
+
+// FilePayload 에이전트로 보내는 파일 배포 단계의 파일
+type FilePayload struct {
+	Path    string `json:"path"`
+	Content string `json:"content"` // base64
+	SHA256  string `json:"sha256"`
+	Mode    string `json:"mode,omitempty"`
+}
+
+// stepOutcome 실행한 단계의 결과 (다음 단계의 when 조건에 쓰인다)
+type stepOutcome struct {
+	ok       bool
+	exitCode *int
+	output   string // stdout + stderr
+	err      string
+	result   map[string]interface{}
+}
+
+// stepOutcomeOf 에이전트 결과를 단계 결과로 바꾼다.
+func stepOutcomeOf(result map[string]interface{}) *stepOutcome {
+	out := &stepOutcome{result: result, ok: targetState(result) == TargetSucceeded}
+	switch code := result["exit_code"].(type) {
+	case float64:
+		c := int(code)
+		out.exitCode = &c
+	case int:
+		out.exitCode = &code
+	}
+	stdout, _ := result["stdout"].(string)
+	stderr, _ := result["stderr"].(string)
+	out.output = stdout + stderr
+	out.err, _ = result["error"].(string)
+	if !out.ok && out.err == "" {
+		status, _ := result["status"].(string)
+		out.err = status
+		if out.exitCode != nil {
+			out.err = fmt.Sprintf("exit code %d", *out.exitCode)
+		}
+	}
+	return out
+}
+
+// match 직전에 실행한 단계의 결과가 조건에 맞는지 여부
+func (c *StepCondition) match(prev *stepOutcome) bool {
+	if prev == nil {
+		return false
+	}
+	if c.ExitCode != nil && (prev.exitCode == nil || *prev.exitCode != *c.ExitCode) {
+		return false
+	}
+	if c.ExitCodeNot != nil && prev.exitCode != nil && *prev.exitCode == *c.ExitCodeNot {
+		return false
+	}
+	if c.matches != nil && !c.matches.MatchString(prev.output) {
+		return false
+	}
+	if c.notMatches != nil && c.notMatches.MatchString(prev.output) {
+		return false
+	}
+	return true
+}
+
+// playbookTarget 에이전트 한 대에서 진행 중인 플레이북
+type playbookTarget struct {
+	jobID    string
+	key      string // 작업 대상 ID (시작할 때의 에이전트 ID)
+	os       string
+	macAddr  string
+	hostname string
+	wake     chan struct{}
+
+	// 아래는 playbookRunner.mu로 보호한다
+	agentID    string // 현재 연결의 에이전트 ID (재접속하면 바뀐다)
+	connected  bool
+	reconnects int                    // 시작 후 다시 연결된 횟수
+	stepID     string                 // 결과를 기다리는 단계 명령 ID
+	result     map[string]interface{} // stepID의 결과
+	canceledBy string
+}
+
+// notify 대기 중인 실행 고루틴을 깨운다 (playbookRunner.mu를 잡은 상태에서 호출)
+func (t *playbookTarget) notify() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// playbookRunner 대상 에이전트마다 플레이북 단계를 차례로 실행한다.
+// 재부팅 등으로 다시 연결된 에이전트는 MAC 주소/호스트 이름으로 이어서 추적한다.
+type playbookRunner struct {
+	mu      sync.Mutex
+	targets map[*playbookTarget]bool
+	steps   map[string]*playbookTarget // 결과를 기다리는 단계 명령 ID → 대상
+}
+
+func newPlaybookRunner() *playbookRunner {
+	return &playbookRunner{
+		targets: make(map[*playbookTarget]bool),
+		steps:   make(map[string]*playbookTarget),
+	}
+}
+
+// Start 작업 대상마다 플레이북 실행을 시작한다. 작업은 jobs.Create로 먼저 만들어져 있어야 한다.
+// agentsMutex를 잡은 상태에서 호출한다.
+func (m *playbookRunner) Start(jobID string, req CommandRequest, targets []*Agent, requestedBy, approvedBy string) {
+	p, err := playbooks.definition(req.Playbook)
+	for _, agent := range targets {
+		if err != nil {
+			jobs.FinishSteps(jobID, agent.ID, TargetFailed, err.Error(), nil)
+			continue
+		}
+		t := &playbookTarget{
+			jobID:     jobID,
+			key:       agent.ID,
+			wake:      make(chan struct{}, 1),
+			agentID:   agent.ID,
+			connected: true,
+		}
+		if agent.Info != nil {
+			t.os = agent.Info.OS
+			t.macAddr = agent.Info.MacAddr
+			t.hostname = agent.Info.Hostname
+		}
+		vars := p.agentVars(req.Playbook, agent.ID, agent.Info)
+
+		m.mu.Lock()
+		m.targets[t] = true
+		m.mu.Unlock()
+		go m.run(t, p, vars, req, requestedBy, approvedBy)
+	}
+}
+
+// Result 단계 명령의 결과를 기다리는 대상에 전달한다.
+func (m *playbookRunner) Result(result map[string]interface{}) {
+	id, _ := result["id"].(string)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.steps[id]; ok && t.stepID == id {
+		t.result = result
+		t.notify()
+	}
+}
+
+// AgentGone 연결이 끊긴 에이전트의 플레이북에 알린다.
+func (m *playbookRunner) AgentGone(agentID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for t := range m.targets {
+		if t.agentID == agentID && t.connected {
+			t.connected = false
+			t.notify()
+		}
+	}
+}
+
+// AgentRegistered 다시 연결된 에이전트를 MAC 주소(없으면 호스트 이름)로 찾아 이어서 추적한다.
+// 이전 연결이 아직 끊긴 것으로 보이지 않더라도 새 연결로 바꾼다 (재부팅 직후 등).
+func (m *playbookRunner) AgentRegistered(agent *Agent) {
+	if agent.Info == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for t := range m.targets {
+		if t.agentID == agent.ID {
+			continue
+		}
+		sameMac := t.macAddr != "" && t.macAddr == agent.Info.MacAddr
+		sameHost := t.macAddr == "" && t.hostname != "" && t.hostname == agent.Info.Hostname
+		if !sameMac && !sameHost {
+			continue
+		}
+		t.agentID = agent.ID
+		t.connected = true
+		t.reconnects++
+		t.notify()
+	}
+}
+
+// Cancel 작업의 플레이북 실행을 멈춘다. agentIDs가 nil이면 모든 대상. 해당 대상이 없으면 false.
+func (m *playbookRunner) Cancel(jobID string, agentIDs []string, user string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	for t := range m.targets {
+		if t.jobID != jobID || (agentIDs != nil && !slices.Contains(agentIDs, t.agentID)) {
+			continue
+		}
+		t.canceledBy = user
+		t.notify()
+		found = true
+	}
+	return found
+}
+
+func (m *playbookRunner) remove(t *playbookTarget) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.targets, t)
+	delete(m.steps, t.stepID)
+}
+
+// wait cond가 참이 될 때까지 기다린다. deadline(zero = 제한 없음)이 지나면 false.
+// cond는 m.mu를 잡은 상태에서 호출된다.
+func (m *playbookRunner) wait(t *playbookTarget, deadline time.Time, cond func() bool) bool {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		m.mu.Lock()
+		ok := cond()
+		m.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-t.wake:
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// state 현재 재연결 횟수와 취소한 사용자
+func (m *playbookRunner) state(t *playbookTarget) (reconnects int, canceledBy string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return t.reconnects, t.canceledBy
+}
+
+// run 에이전트 한 대에서 플레이북 단계를 차례로 실행한다.
+// 실패한 단계가 있으면 (continue_on_error가 아니면) 남은 단계는 건너뛴다.
+func (m *playbookRunner) run(t *playbookTarget, p *Playbook, vars map[string]string, req CommandRequest, requestedBy, approvedBy string) {
+	defer m.remove(t)
+
+	steps := make([]*StepStatus, len(p.Steps))
+	for i := range p.Steps {
+		steps[i] = &StepStatus{Name: p.Steps[i].Name, Type: p.Steps[i].kind(), State: StepPending}
+	}
+	jobs.StartSteps(t.jobID, t.key, steps)
+
+	var prev *stepOutcome
+	failure := ""
+	since := 0 // 직전 단계를 시작할 때의 재연결 횟수 (wait_reconnect 기준)
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		reconnects, canceledBy := m.state(t)
+		switch {
+		case canceledBy != "":
+			failure = "canceled by " + canceledBy
+			m.skip(t, i, failure)
+			continue
+		case failure != "":
+			m.skip(t, i, "previous step failed")
+			continue
+		case step.OS != "" && step.OS != t.os:
+			m.skip(t, i, "not for "+t.os)
+			continue
+		case step.When != nil && !step.When.match(prev):
+			m.skip(t, i, "when condition not met")
+			continue
+		}
+
+		out := m.runStep(t, i, step, vars, req, requestedBy, approvedBy, since)
+		since = reconnects
+		prev = out
+		if !out.ok && !step.ContinueOnError {
+			failure = fmt.Sprintf("step %d (%s): %s", i+1, step.Name, out.err)
+		}
+	}
+
+	state := TargetSucceeded
+	if failure != "" {
+		state = TargetFailed
+	}
+	var result map[string]interface{}
+	if prev != nil {
+		result = prev.result
+	}
+	jobs.FinishSteps(t.jobID, t.key, state, failure, result)
+}
+
+func (m *playbookRunner) skip(t *playbookTarget, index int, reason string) {
+	jobs.Step(t.jobID, t.key, index, func(s *StepStatus) {
+		s.State = StepSkipped
+		s.Error = reason
+	})
+}
+
+// runStep 단계 하나를 실행하고 결과를 작업에 기록한다.
+func (m *playbookRunner) runStep(t *playbookTarget, index int, step *PlaybookStep, vars map[string]string, req CommandRequest, requestedBy, approvedBy string, since int) *stepOutcome {
+	stepID := newID()
+	started := time.Now()
+	kind := step.kind()
+	jobs.Step(t.jobID, t.key, index, func(s *StepStatus) {
+		s.State = StepRunning
+		s.StartedAt = &started
+		if kind != StepWaitReconnect {
+			s.CommandID = stepID
+		}
+	})
+
+	var out *stepOutcome
+	if kind == StepWaitReconnect {
+		out = m.waitReconnect(t, since, step.Timeout)
+	} else {
+		out = m.runCommandStep(t, stepID, step, vars, req, requestedBy, approvedBy)
+	}
+
+	ended := time.Now()
+	jobs.Step(t.jobID, t.key, index, func(s *StepStatus) {
+		s.State = StepSucceeded
+		if !out.ok {
+			s.State = StepFailed
+		}
+		s.ExitCode = out.exitCode
+		s.Error = out.err
+		s.EndedAt = &ended
+		s.Result = out.result
+	})
+	return out
+}
+
+// runCommandStep 단계 명령을 보내고 결과를 기다린다. 재부팅 단계는 다시 연결될 때까지 기다린다.
+func (m *playbookRunner) runCommandStep(t *playbookTarget, stepID string, step *PlaybookStep, vars map[string]string, req CommandRequest, requestedBy, approvedBy string) *stepOutcome {
+	stepReq, file, err := playbookStepRequest(step, vars, t.os, req)
+	if err != nil {
+		return &stepOutcome{err: err.Error()}
+	}
+	cmdMsg := commandMessage(stepID, stepReq, requestedBy, approvedBy)
+	if file != nil {
+		cmdMsg["file"] = file
+	}
+
+	reboot := step.kind() == StepReboot
+	var deadline time.Time
+	switch {
+	case reboot:
+		d := defaultReconnectTimeout
+		if step.Timeout > 0 {
+			d = time.Duration(step.Timeout) * time.Second
+		}
+		deadline = time.Now().Add(d)
+	case stepReq.Timeout > 0:
+		deadline = time.Now().Add(time.Duration(stepReq.Timeout)*time.Second + jobTimeoutGrace)
+	}
+
+	before, _ := m.state(t)
+	if err := m.dispatch(t, stepID, stepReq, cmdMsg); err != nil {
+		return &stepOutcome{err: err.Error()}
+	}
+
+	var result map[string]interface{}
+	var gone bool
+	var canceledBy string
+	ok := m.wait(t, deadline, func() bool {
+		result = t.result
+		gone = !t.connected || t.reconnects > before
+		canceledBy = t.canceledBy
+		return result != nil || gone || canceledBy != ""
+	})
+	m.forget(t, stepID)
+
+	var out *stepOutcome
+	switch {
+	case canceledBy != "":
+		m.cancelStep(t, stepID)
+		return &stepOutcome{err: "canceled by " + canceledBy, result: result}
+	case !ok:
+		m.cancelStep(t, stepID)
+		return &stepOutcome{err: "no result from agent before the deadline"}
+	case result != nil:
+		out = stepOutcomeOf(result)
+		if !reboot || !out.ok {
+			return out
+		}
+	case !reboot:
+		return &stepOutcome{err: "agent disconnected"}
+	default:
+		out = &stepOutcome{ok: true}
+	}
+
+	// 재부팅: 에이전트가 다시 연결될 때까지 기다린다
+	ok = m.wait(t, deadline, func() bool {
+		canceledBy = t.canceledBy
+		return canceledBy != "" || (t.connected && t.reconnects > before)
+	})
+	switch {
+	case canceledBy != "":
+		return &stepOutcome{err: "canceled by " + canceledBy, result: out.result}
+	case !ok:
+		return &stepOutcome{err: "agent did not reconnect before the deadline", result: out.result}
+	}
+	return out
+}
+
+// waitReconnect 직전 단계를 시작한 뒤로 에이전트가 다시 연결될 때까지 기다린다.
+func (m *playbookRunner) waitReconnect(t *playbookTarget, since, timeout int) *stepOutcome {
+	d := defaultReconnectTimeout
+	if timeout > 0 {
+		d = time.Duration(timeout) * time.Second
+	}
+	var canceledBy string
+	ok := m.wait(t, time.Now().Add(d), func() bool {
+		canceledBy = t.canceledBy
+		return canceledBy != "" || (t.connected && t.reconnects > since)
+	})
+	switch {
+	case canceledBy != "":
+		return &stepOutcome{err: "canceled by " + canceledBy}
+	case !ok:
+		return &stepOutcome{err: "agent did not reconnect before the deadline"}
+	}
+	return &stepOutcome{ok: true}
+}
+
+// dispatch 단계 명령을 현재 연결된 에이전트에 보낸다.
+func (m *playbookRunner) dispatch(t *playbookTarget, stepID string, req CommandRequest, cmdMsg map[string]interface{}) error {
+	agentsMutex.Lock()
+	defer agentsMutex.Unlock()
+
+	m.mu.Lock()
+	agentID, connected := t.agentID, t.connected
+	m.mu.Unlock()
+
+	agent := agentByID(agentID)
+	if !connected || agent == nil {
+		return fmt.Errorf("agent is not connected")
+	}
+
+	m.mu.Lock()
+	t.stepID = stepID
+	t.result = nil
+	m.steps[stepID] = t
+	m.mu.Unlock()
+
+	dispatchCommand(agent, stepID, req, cmdMsg)
+	return nil
+}
+
+// forget 결과를 다 기다린 단계 명령을 정리한다.
+func (m *playbookRunner) forget(t *playbookTarget, stepID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.steps, stepID)
+	t.stepID = ""
+	t.result = nil
+}
+
+// cancelStep 실행 중인 단계 명령의 취소를 에이전트에 요청한다.
+func (m *playbookRunner) cancelStep(t *playbookTarget, stepID string) {
+	cfg := config.Load()
+	cancelBytes, _ := json.Marshal(map[string]string{
+		"type":  "cancel",
+		"token": cfg.AuthToken,
+		"id":    stepID,
+	})
+
+	agentsMutex.Lock()
+	defer agentsMutex.Unlock()
+
+	m.mu.Lock()
+	agentID := t.agentID
+	m.mu.Unlock()
+
+	if agent := agentByID(agentID); agent != nil {
+		if err := agent.Conn.WriteMessage(websocket.TextMessage, cancelBytes); err != nil {
+			log.Println("write to agent error:", err)
+		}
+	}
+}
+
+// playbookStepRequest 변수를 치환해 에이전트에 보낼 단계 명령을 만든다.
+func playbookStepRequest(step *PlaybookStep, vars map[string]string, agentOS string, req CommandRequest) (CommandRequest, *FilePayload, error) {
+	stepReq := CommandRequest{Timeout: step.Timeout, Priority: req.Priority}
+	if stepReq.Timeout == 0 {
+		stepReq.Timeout = req.Timeout
+	}
+
+	var err error
+	switch step.kind() {
+	case StepShell:
+		if stepReq.Command, err = expandPlaybookVars(step.Shell, vars); err != nil {
+			return stepReq, nil, err
+		}
+
+	case StepScript:
+		run := &ScriptRun{
+			Name:    step.Script.Name,
+			Version: step.Script.Version,
+			Params:  make(map[string]string, len(step.Script.Params)),
+		}
+		for k, v := range step.Script.Params {
+			if run.Params[k], err = expandPlaybookVars(v, vars); err != nil {
+				return stepReq, nil, err
+			}
+		}
+		if err := scripts.Resolve(run); err != nil {
+			return stepReq, nil, err
+		}
+		stepReq.Script = run
+		stepReq.Command = run.String()
+
+	case StepFile:
+		path, err := expandPlaybookVars(step.File.Path, vars)
+		if err != nil {
+			return stepReq, nil, err
+		}
+		var data []byte
+		if step.File.Source != "" {
+			if data, err = playbooks.readFile(step.File.Source); err != nil {
+				return stepReq, nil, err
+			}
+		} else {
+			content, err := expandPlaybookVars(step.File.Content, vars)
+			if err != nil {
+				return stepReq, nil, err
+			}
+			data = []byte(content)
+		}
+		sum := sha256.Sum256(data)
+		stepReq.Command = "file:" + path
+		return stepReq, &FilePayload{
+			Path:    path,
+			Content: base64.StdEncoding.EncodeToString(data),
+			SHA256:  hex.EncodeToString(sum[:]),
+			Mode:    step.File.Mode,
+		}, nil
+
+	case StepReboot:
+		stepReq.Exec = &ExecSpec{Argv: rebootArgv(agentOS)}
+		stepReq.Command = stepReq.Exec.String()
+		stepReq.Timeout = rebootCommandTimeout
+
+	default:
+		return stepReq, nil, fmt.Errorf("지원하지 않는 단계: %s", step.Name)
+	}
+	return stepReq, nil, nil
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 플레이북 단계 종류
+const (
+	StepShell         = "shell"          // 셸 명령
+	StepScript        = "script"         // 스크립트 라이브러리 실행
+	StepFile          = "file"           // 파일 배포
+	StepReboot        = "reboot"         // 재부팅 후 다시 연결될 때까지 대기
+	StepWaitReconnect = "wait_reconnect" // 에이전트가 다시 연결될 때까지 대기
+)
+
+const (
+	// 플레이북 하나의 최대 단계 수
+	maxPlaybookSteps = 100
+	// 파일 배포 단계에서 보낼 수 있는 최대 크기
+	maxPlaybookFileSize = 8 << 20
+	// reboot/wait_reconnect 단계의 기본 제한 시간
+	defaultReconnectTimeout = 10 * time.Minute
+)
+
+// errPlaybookStorage 플레이북 파일 저장 실패 (요청 오류가 아닌 서버 오류)
+var errPlaybookStorage = errors.New("플레이북 저장 실패")
+
+// 에이전트 정보에서 채워지는 변수 (vars로 선언할 수 없음)
+var builtinPlaybookVars = map[string]bool{
+	"agent_id": true, "hostname": true, "os": true, "arch": true, "mac_addr": true, "group": true,
+}
+
+// {{name}}, {{labels.room}} 형식의 변수
+var playbookVarPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z0-9_-]+)?)\s*\}\}`)
+
+// StepCondition 직전에 실행한 단계의 결과에 따른 실행 조건 (모두 만족해야 실행)
+type StepCondition struct {
+	ExitCode         *int   `yaml:"exit_code,omitempty" json:"exit_code,omitempty"`
+	ExitCodeNot      *int   `yaml:"exit_code_not,omitempty" json:"exit_code_not,omitempty"`
+	OutputMatches    string `yaml:"output_matches,omitempty" json:"output_matches,omitempty"`         // stdout/stderr 정규식
+	OutputNotMatches string `yaml:"output_not_matches,omitempty" json:"output_not_matches,omitempty"` // stdout/stderr 정규식
+
+	matches, notMatches *regexp.Regexp
+}
+
+// PlaybookFile 파일 배포 단계. content와 source 중 하나를 사용한다.
+type PlaybookFile struct {
+	Path    string `yaml:"path" json:"path"`                           // 에이전트의 절대 경로 (변수 치환)
+	Content string `yaml:"content,omitempty" json:"content,omitempty"` // 인라인 내용 (변수 치환)
+	Source  string `yaml:"source,omitempty" json:"source,omitempty"`   // data_dir/playbooks/files 아래 파일 (그대로 전송)
+	Mode    string `yaml:"mode,omitempty" json:"mode,omitempty"`       // 8진수 권한 (예: "0644")
+}
+
+// PlaybookStep 플레이북 단계. shell, script, file, reboot, wait_reconnect 중 하나를 지정한다.
+type PlaybookStep struct {
+	Name            string         `yaml:"name" json:"name"`
+	Shell           string         `yaml:"shell,omitempty" json:"shell,omitempty"`
+	Script          *ScriptRun     `yaml:"script,omitempty" json:"script,omitempty"`
+	File            *PlaybookFile  `yaml:"file,omitempty" json:"file,omitempty"`
+	Reboot          bool           `yaml:"reboot,omitempty" json:"reboot,omitempty"`
+	WaitReconnect   bool           `yaml:"wait_reconnect,omitempty" json:"wait_reconnect,omitempty"`
+	OS              string         `yaml:"os,omitempty" json:"os,omitempty"`           // 이 OS의 에이전트에서만 실행
+	Timeout         int            `yaml:"timeout,omitempty" json:"timeout,omitempty"` // 초
+	When            *StepCondition `yaml:"when,omitempty" json:"when,omitempty"`
+	ContinueOnError bool           `yaml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"`
+}
+
+// kind 단계 종류. 지정한 동작이 하나가 아니면 빈 문자열.
+func (s *PlaybookStep) kind() string {
+	var kinds []string
+	if s.Shell != "" {
+		kinds = append(kinds, StepShell)
+	}
+	if s.Script != nil {
+		kinds = append(kinds, StepScript)
+	}
+	if s.File != nil {
+		kinds = append(kinds, StepFile)
+	}
+	if s.Reboot {
+		kinds = append(kinds, StepReboot)
+	}
+	if s.WaitReconnect {
+		kinds = append(kinds, StepWaitReconnect)
+	}
+	if len(kinds) != 1 {
+		return ""
+	}
+	return kinds[0]
+}
+
+// Playbook 에이전트마다 차례로 실행하는 단계 목록
+type Playbook struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Vars        map[string]string `yaml:"vars,omitempty" json:"vars,omitempty"` // 변수 기본값 (실행 시 덮어쓸 수 있음)
+	Steps       []PlaybookStep    `yaml:"steps" json:"steps"`
+}
+
+// PlaybookSummary 플레이북 목록 항목
+type PlaybookSummary struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Vars        map[string]string `json:"vars,omitempty"`
+	Steps       int               `json:"steps"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	Error       string            `json:"error,omitempty"` // 파일을 읽을 수 없거나 올바르지 않음
+}
+
+// PlaybookRun 대시보드가 요청한 플레이북 실행
+type PlaybookRun struct {
+	Name string            `json:"name"`
+	Vars map[string]string `json:"vars,omitempty"`
+
+	// 요청을 해석할 때 읽은 정의 (승인 후에도 승인한 내용 그대로 실행)와 그 요약값
+	playbook *Playbook
+	digest   string
+}
+
+// String 표시/승인 규칙 검사용 명령 문자열
+func (r *PlaybookRun) String() string {
+	keys := make([]string, 0, len(r.Vars))
+	for k := range r.Vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{"playbook:" + r.Name}
+	for _, k := range keys {
+		v := r.Vars[k]
+		if v == "" || strings.ContainsAny(v, " \t\"'") {
+			v = fmt.Sprintf("%q", v)
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// parsePlaybook YAML 정의를 읽고 검증한다. 알 수 없는 키는 오류로 본다.
+func parsePlaybook(data []byte) (*Playbook, error) {
+	var p Playbook
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("YAML 형식 오류: %v", err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Playbook) validate() error {
+	if !scriptNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("플레이북 이름은 영문, 숫자, _, - 만 사용할 수 있습니다")
+	}
+	for name := range p.Vars {
+		if !paramNamePattern.MatchString(name) {
+			return fmt.Errorf("잘못된 변수 이름: %q", name)
+		}
+		if builtinPlaybookVars[name] {
+			return fmt.Errorf("변수 %s는 에이전트 정보로 채워지므로 선언할 수 없습니다", name)
+		}
+	}
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("단계(steps)가 없습니다")
+	}
+	if len(p.Steps) > maxPlaybookSteps {
+		return fmt.Errorf("단계는 최대 %d개까지 사용할 수 있습니다", maxPlaybookSteps)
+	}
+
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+		if err := p.validateStep(i, step); err != nil {
+			return fmt.Errorf("단계 %d (%s): %v", i+1, step.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p *Playbook) validateStep(i int, step *PlaybookStep) error {
+	kind := step.kind()
+	if kind == "" {
+		return fmt.Errorf("shell, script, file, reboot, wait_reconnect 중 하나만 지정해야 합니다")
+	}
+	if step.Timeout < 0 {
+		return fmt.Errorf("timeout은 0 이상이어야 합니다")
+	}
+	switch step.OS {
+	case "", "windows", "linux", "darwin":
+	default:
+		return fmt.Errorf("지원하지 않는 OS: %q", step.OS)
+	}
+
+	var templates []string
+	switch kind {
+	case StepShell:
+		templates = append(templates, step.Shell)
+	case StepScript:
+		if _, ok := scripts.Get(step.Script.Name); !ok {
+			return fmt.Errorf("스크립트를 찾을 수 없습니다: %s", step.Script.Name)
+		}
+		for _, v := range step.Script.Params {
+			templates = append(templates, v)
+		}
+	case StepFile:
+		f := step.File
+		if f.Path == "" {
+			return fmt.Errorf("file.path가 비어 있습니다")
+		}
+		if (f.Content == "") == (f.Source == "") {
+			return fmt.Errorf("file.content와 file.source 중 하나만 지정해야 합니다")
+		}
+		if f.Source != "" && !filepath.IsLocal(f.Source) {
+			return fmt.Errorf("file.source는 files 디렉토리 안의 상대 경로여야 합니다: %q", f.Source)
+		}
+		if f.Mode != "" {
+			if m, err := strconv.ParseUint(f.Mode, 8, 32); err != nil || m > 0777 {
+				return fmt.Errorf("잘못된 file.mode: %q", f.Mode)
+			}
+		}
+		templates = append(templates, f.Path, f.Content)
+	}
+	for _, t := range templates {
+		if err := p.checkVars(t); err != nil {
+			return err
+		}
+	}
+
+	if c := step.When; c != nil {
+		if i == 0 {
+			return fmt.Errorf("첫 단계에는 when 조건을 쓸 수 없습니다")
+		}
+		var err error
+		if c.OutputMatches != "" {
+			if c.matches, err = regexp.Compile(c.OutputMatches); err != nil {
+				return fmt.Errorf("when.output_matches: %v", err)
+			}
+		}
+		if c.OutputNotMatches != "" {
+			if c.notMatches, err = regexp.Compile(c.OutputNotMatches); err != nil {
+				return fmt.Errorf("when.output_not_matches: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkVars 문자열에 쓰인 변수가 선언된 변수, 에이전트 정보 또는 라벨인지 확인한다.
+func (p *Playbook) checkVars(s string) error {
+	for _, m := range playbookVarPattern.FindAllStringSubmatch(s, -1) {
+		name := m[1]
+		if _, ok := p.Vars[name]; ok || builtinPlaybookVars[name] || strings.HasPrefix(name, "labels.") {
+			continue
+		}
+		return fmt.Errorf("선언되지 않은 변수: {{%s}}", name)
+	}
+	return nil
+}
+
+// agentVars 에이전트 한 대에 적용할 변수 (기본값 < 실행 시 값, 에이전트 정보와 labels.*)
+func (p *Playbook) agentVars(run *PlaybookRun, agentID string, info *AgentInfo) map[string]string {
+	vars := make(map[string]string, len(p.Vars)+8)
+	for k, v := range p.Vars {
+		vars[k] = v
+	}
+	for k, v := range run.Vars {
+		vars[k] = v
+	}
+	vars["agent_id"] = agentID
+	if info != nil {
+		vars["hostname"] = info.Hostname
+		vars["os"] = info.OS
+		vars["arch"] = info.Arch
+		vars["mac_addr"] = info.MacAddr
+		vars["group"] = info.Group
+		for k, v := range info.Labels {
+			vars["labels."+k] = v
+		}
+	}
+	return vars
+}
+
+// expandPlaybookVars {{name}}을 값으로 바꾼다. 에이전트에 없는 라벨은 오류.
+func expandPlaybookVars(s string, vars map[string]string) (string, error) {
+	var missing string
+	out := playbookVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := playbookVarPattern.FindStringSubmatch(m)[1]
+		v, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("에이전트에 변수 {{%s}} 값이 없습니다", missing)
+	}
+	return out, nil
+}
+
+// playbookLibrary data_dir/playbooks/<name>.yaml 에 저장되는 플레이북
+// 파일을 직접 고쳐도 다음 조회/실행부터 반영되도록 매번 파일에서 읽는다.
+type playbookLibrary struct {
+	mu  sync.Mutex // 저장/삭제 직렬화
+	dir string
+}
+
+func newPlaybookLibrary(dir string) *playbookLibrary {
+	return &playbookLibrary{dir: dir}
+}
+
+func (l *playbookLibrary) path(name string) string {
+	return filepath.Join(l.dir, name+".yaml")
+}
+
+// List 플레이북 목록 (이름순). 올바르지 않은 파일도 오류와 함께 보여준다.
+func (l *playbookLibrary) List() []PlaybookSummary {
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.yaml"))
+	if err != nil {
+		log.Printf("플레이북: 목록 읽기 오류: %v", err)
+	}
+	list := make([]PlaybookSummary, 0, len(files))
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+		summary := PlaybookSummary{Name: name}
+		if st, err := os.Stat(path); err == nil {
+			summary.UpdatedAt = st.ModTime()
+		}
+		p, _, err := l.Get(name)
+		if err != nil {
+			summary.Error = err.Error()
+		} else {
+			summary.Description = p.Description
+			summary.Vars = p.Vars
+			summary.Steps = len(p.Steps)
+		}
+		list = append(list, summary)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get 플레이북 정의와 원본 YAML
+func (l *playbookLibrary) Get(name string) (*Playbook, string, error) {
+	if !scriptNamePattern.MatchString(name) {
+		return nil, "", fmt.Errorf("플레이북을 찾을 수 없습니다: %s", name)
+	}
+	data, err := os.ReadFile(l.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("플레이북을 찾을 수 없습니다: %s", name)
+		}
+		return nil, "", err
+	}
+	p, err := parsePlaybook(data)
+	if err != nil {
+		return nil, string(data), err
+	}
+	if p.Name != name {
+		return nil, string(data), fmt.Errorf("파일 이름(%s)과 플레이북 이름(%s)이 다릅니다", name, p.Name)
+	}
+	return p, string(data), nil
+}
+
+// Save YAML 정의를 검증한 뒤 저장한다.
+func (l *playbookLibrary) Save(data []byte) (*Playbook, error) {
+	p, err := parsePlaybook(data)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return nil, fmt.Errorf("%w: %v", errPlaybookStorage, err)
+	}
+	tmp := l.path(p.Name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, fmt.Errorf("%w: %v", errPlaybookStorage, err)
+	}
+	if err := os.Rename(tmp, l.path(p.Name)); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("%w: %v", errPlaybookStorage, err)
+	}
+	return p, nil
+}
+
+// Delete 플레이북 파일을 지운다.
+func (l *playbookLibrary) Delete(name string) error {
+	if !scriptNamePattern.MatchString(name) {
+		return fmt.Errorf("플레이북을 찾을 수 없습니다: %s", name)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.Remove(l.path(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("플레이북을 찾을 수 없습니다: %s", name)
+		}
+		return fmt.Errorf("%w: %v", errPlaybookStorage, err)
+	}
+	return nil
+}
+
+// Resolve 실행 요청의 플레이북을 읽고 변수 값을 검증한다.
+func (l *playbookLibrary) Resolve(run *PlaybookRun) error {
+	p, source, err := l.Get(run.Name)
+	if err != nil {
+		return err
+	}
+	for name, value := range run.Vars {
+		if _, ok := p.Vars[name]; !ok {
+			return fmt.Errorf("선언되지 않은 변수: %s", name)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("변수 %s 값에 NUL 문자가 있습니다", name)
+		}
+	}
+	run.playbook = p
+	run.digest = l.digest(p, source)
+	return nil
+}
+
+// digest 정의 YAML과 단계가 참조하는 스크립트 본문, 배포 파일의 SHA-256 (예약이 저장 후 바뀐 정의를 실행하지 않도록)
+func (l *playbookLibrary) digest(p *Playbook, source string) string {
+	h := sha256.New()
+	io.WriteString(h, source)
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		switch step.kind() {
+		case StepScript:
+			io.WriteString(h, "\x00script:"+scripts.Text(step.Script))
+		case StepFile:
+			if step.File.Source != "" {
+				data, err := l.readFile(step.File.Source)
+				if err != nil {
+					io.WriteString(h, "\x00file-error:"+err.Error())
+					continue
+				}
+				io.WriteString(h, "\x00file:")
+				h.Write(data)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// definition 요청을 해석할 때 읽은 정의. 파일에서 다시 읽은 요청(예약 실행 등)은 지금 다시 읽는다.
+func (l *playbookLibrary) definition(run *PlaybookRun) (*Playbook, error) {
+	if run.playbook == nil {
+		if err := l.Resolve(run); err != nil {
+			return nil, err
+		}
+	}
+	return run.playbook, nil
+}
+
+// Text 승인 규칙 검사용으로 모든 단계의 명령을 이어 붙인다.
+// 변수가 들어간 값은 정의 그대로와 함께 대상 에이전트마다 실제로 보낼 값으로 치환한 결과도 넣는다
+// (대상이 없으면 실행 시 값과 기본값만 치환). agentsMutex를 잡은 상태에서 호출한다.
+func (l *playbookLibrary) Text(run *PlaybookRun, targets []*Agent, held []AgentInfo) string {
+	p, err := l.definition(run)
+	if err != nil {
+		return ""
+	}
+	varSets := make([]map[string]string, 0, len(targets)+len(held))
+	for _, agent := range targets {
+		varSets = append(varSets, p.agentVars(run, agent.ID, agent.Info))
+	}
+	for i := range held {
+		varSets = append(varSets, p.agentVars(run, "", &held[i]))
+	}
+	if len(varSets) == 0 {
+		varSets = append(varSets, p.agentVars(run, "", nil))
+	}
+
+	var lines []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			lines = append(lines, s)
+		}
+	}
+	addExpanded := func(prefix, s string) {
+		add(prefix + s)
+		for _, vars := range varSets {
+			add(prefix + expandKnownVars(s, vars))
+		}
+	}
+	for i := range p.Steps {
+		step := &p.Steps[i]
+		switch step.kind() {
+		case StepShell:
+			addExpanded("", step.Shell)
+		case StepScript:
+			add(scripts.Text(step.Script))
+			for k, v := range step.Script.Params {
+				addExpanded(k+"=", v)
+			}
+		case StepFile:
+			addExpanded("file:", step.File.Path)
+			if step.File.Content != "" {
+				addExpanded("", step.File.Content)
+			}
+		case StepReboot:
+			add(strings.Join(rebootArgv(""), " "))
+			add(strings.Join(rebootArgv("windows"), " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// expandKnownVars 값이 있는 변수만 치환하고 나머지는 그대로 둔다 (승인 규칙 검사용).
+func expandKnownVars(s string, vars map[string]string) string {
+	return playbookVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := vars[playbookVarPattern.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// readFile 파일 배포 단계의 source 파일 (data_dir/playbooks/files 기준)
+func (l *playbookLibrary) readFile(source string) ([]byte, error) {
+	if !filepath.IsLocal(source) {
+		return nil, fmt.Errorf("잘못된 file.source: %q", source)
+	}
+	f, err := os.Open(filepath.Join(l.dir, "files", source))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxPlaybookFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPlaybookFileSize {
+		return nil, fmt.Errorf("파일이 %d바이트를 넘습니다: %s", maxPlaybookFileSize, source)
+	}
+	return data, nil
+}
+
+// rebootArgv 에이전트 OS별 재부팅 명령. 결과를 보고할 수 있도록 잠시 뒤에 재부팅한다.
+func rebootArgv(agentOS string) []string {
+	if agentOS == "windows" {
+		return []string{"shutdown", "/r", "/t", "15"}
+	}
+	return []string{"shutdown", "-r", "+1"}
+}
+
+// handleListPlaybooks GET /api/playbooks
+func handleListPlaybooks(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, playbooks.List())
+}
+
+// handleGetPlaybook GET /api/playbooks/{name} - 정의와 원본 YAML
+func handleGetPlaybook(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	p, source, err := playbooks.Get(r.PathValue("name"))
+	if err != nil && source == "" {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp := map[string]interface{}{
+		"playbook": p,
+		"source":   source,
+	}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSavePlaybook POST /api/playbooks - YAML 본문을 검증해 저장 (admin 역할)
+func handleSavePlaybook(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	if !requireAdmin(w, user, "saving playbooks") {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, err := playbooks.Save(data)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errPlaybookStorage) {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	audit.Record("playbook_saved", user.Name, map[string]interface{}{
+		"playbook": p.Name,
+		"steps":    len(p.Steps),
+	})
+	writeJSON(w, http.StatusOK, p)
+}
+
+// handleDeletePlaybook DELETE /api/playbooks/{name} (admin 역할)
+func handleDeletePlaybook(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	if !requireAdmin(w, user, "deleting playbooks") {
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := playbooks.Delete(name); err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, errPlaybookStorage) {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	audit.Record("playbook_deleted", user.Name, map[string]interface{}{"playbook": name})
+	w.WriteHeader(http.StatusNoContent)
+}
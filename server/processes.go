@@ -0,0 +1,342 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+// 에이전트가 프로세스 목록이나 종료 결과를 보내기까지 기다리는 시간
+const processReplyTimeout = 15 * time.Second
+
+// 한 번에 돌려주는 프로세스 수 기본값과 최대값
+const (
+	defaultProcessLimit = 200
+	maxProcessLimit     = 5000
+)
+
+// ProcessInfo 에이전트가 보고한 프로세스 하나
+type ProcessInfo struct {
+	PID           int     `json:"pid"`
+	PPID          int     `json:"ppid"`
+	Name          string  `json:"name"`
+	User          string  `json:"user,omitempty"`
+	Cmdline       string  `json:"cmdline,omitempty"`
+	CPU           float64 `json:"cpu"`            // 이전 조회 이후 CPU 사용률 (%, 전체 코어 기준)
+	Memory        uint64  `json:"memory"`         // 상주 메모리 (bytes)
+	MemoryPercent float64 `json:"memory_percent"` // 전체 메모리 대비 (%)
+}
+
+// ProcessList 에이전트의 processes 응답
+type ProcessList struct {
+	RequestID string        `json:"request_id"`
+	Processes []ProcessInfo `json:"processes"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// KillResult 에이전트의 kill_process 처리 결과
+type KillResult struct {
+	RequestID string   `json:"request_id"`
+	Signal    string   `json:"signal"`
+	Killed    []int    `json:"killed"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// ProcessFilter 프로세스 목록 필터와 정렬
+type ProcessFilter struct {
+	Query string // 이름이나 명령줄에 포함된 문자열 (대소문자 무시)
+	Owner string // 실행 사용자
+	PPID  int    // 이 프로세스의 자식만 (0 = 전체)
+	Sort  string // cpu (기본) | memory | pid | name | user
+	Asc   bool
+	Limit int
+}
+
+var processSorts = map[string]func(a, b ProcessInfo) int{
+	"cpu":    func(a, b ProcessInfo) int { return cmp.Compare(a.CPU, b.CPU) },
+	"memory": func(a, b ProcessInfo) int { return cmp.Compare(a.Memory, b.Memory) },
+	"pid":    func(a, b ProcessInfo) int { return cmp.Compare(a.PID, b.PID) },
+	"name":   func(a, b ProcessInfo) int { return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+	"user":   func(a, b ProcessInfo) int { return cmp.Compare(strings.ToLower(a.User), strings.ToLower(b.User)) },
+}
+
+var processSignals = map[string]bool{"term": true, "kill": true, "int": true, "hup": true}
+
+var errAgentNotConnected = errors.New("agent not connected")
+
+// processFilterFromQuery ?q=&owner=&ppid=&sort=&order=asc|desc&limit=
+// 실행 사용자 필터는 로그인용 user 파라미터와 겹치지 않도록 owner로 받는다.
+func processFilterFromQuery(q map[string][]string) (ProcessFilter, error) {
+	get := func(key string) string {
+		if v := q[key]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	f := ProcessFilter{
+		Query: strings.ToLower(get("q")),
+		Owner: get("owner"),
+		Sort:  cmp.Or(get("sort"), "cpu"),
+		Limit: defaultProcessLimit,
+	}
+	if _, ok := processSorts[f.Sort]; !ok {
+		return f, fmt.Errorf("sort must be one of cpu, memory, pid, name, user")
+	}
+	switch get("order") {
+	case "asc":
+		f.Asc = true
+	case "", "desc":
+	default:
+		return f, fmt.Errorf("order must be asc or desc")
+	}
+	if v := get("ppid"); v != "" {
+		ppid, err := strconv.Atoi(v)
+		if err != nil || ppid < 0 {
+			return f, fmt.Errorf("invalid ppid")
+		}
+		f.PPID = ppid
+	}
+	if v := get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return f, fmt.Errorf("invalid limit")
+		}
+		f.Limit = min(limit, maxProcessLimit)
+	}
+	return f, nil
+}
+
+// Apply 필터에 맞는 프로세스를 정렬해 최대 Limit개와 필터 후 전체 수를 돌려준다.
+// 정렬 값이 같으면 PID 순서로 둔다.
+func (f ProcessFilter) Apply(procs []ProcessInfo) ([]ProcessInfo, int) {
+	matched := make([]ProcessInfo, 0, len(procs))
+	for _, p := range procs {
+		if f.Query != "" && !strings.Contains(strings.ToLower(p.Name), f.Query) && !strings.Contains(strings.ToLower(p.Cmdline), f.Query) {
+			continue
+		}
+		if f.Owner != "" && !strings.EqualFold(p.User, f.Owner) {
+			continue
+		}
+		if f.PPID != 0 && p.PPID != f.PPID {
+			continue
+		}
+		matched = append(matched, p)
+	}
+	compare := processSorts[f.Sort]
+	slices.SortStableFunc(matched, func(a, b ProcessInfo) int {
+		c := compare(a, b)
+		if !f.Asc {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.PID, b.PID))
+	})
+	total := len(matched)
+	if f.Limit > 0 && total > f.Limit {
+		matched = matched[:f.Limit]
+	}
+	return matched, total
+}
+
+// agentRequests 에이전트에 보낸 요청(프로세스 목록, 종료)의 응답을 기다리는 HTTP 요청과 연결한다.
+type agentRequests struct {
+	mu      sync.Mutex
+	pending map[string]*agentRequest
+	token   string
+}
+
+type agentRequest struct {
+	agentID string
+	reply   chan map[string]interface{}
+}
+
+func newAgentRequests(cfg *config.Config) *agentRequests {
+	return &agentRequests{
+		pending: make(map[string]*agentRequest),
+		token:   cfg.AuthToken,
+	}
+}
+
+// Send 에이전트에 요청을 보내고 응답을 기다린다. 응답 메시지의 field 아래 request_id로 짝을 맞춘다.
+func (a *agentRequests) Send(r *http.Request, agentID string, msg map[string]interface{}) (map[string]interface{}, error) {
+	id := newID()
+	req := &agentRequest{agentID: agentID, reply: make(chan map[string]interface{}, 1)}
+
+	a.mu.Lock()
+	a.pending[id] = req
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, id)
+		a.mu.Unlock()
+	}()
+
+	msg["token"] = a.token
+	msg["request_id"] = id
+	agentsMutex.Lock()
+	agent := agentByID(agentID)
+	var err error
+	if agent == nil {
+		err = errAgentNotConnected
+	} else {
+		err = agent.Conn.WriteJSON(msg)
+	}
+	agentsMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-req.reply:
+		return reply, nil
+	case <-time.After(processReplyTimeout):
+		return nil, errors.New("agent did not respond")
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+// Deliver 에이전트 응답을 기다리는 요청에 전달한다 (기다리는 요청이 없으면 버린다).
+func (a *agentRequests) Deliver(agentID, field string, msg map[string]interface{}) {
+	body, _ := msg[field].(map[string]interface{})
+	id, _ := body["request_id"].(string)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	req, ok := a.pending[id]
+	if !ok || req.agentID != agentID {
+		return
+	}
+	select {
+	case req.reply <- body:
+	default:
+	}
+}
+
+// decodeReply 응답 본문을 구조체로 옮긴다.
+func decodeReply(body map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func agentRequestStatus(err error) int {
+	if errors.Is(err, errAgentNotConnected) {
+		return http.StatusNotFound
+	}
+	return http.StatusGatewayTimeout
+}
+
+// handleAgentProcesses GET /api/agents/{id}/processes?q=&owner=&ppid=&sort=&order=&limit=
+func handleAgentProcesses(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	filter, err := processFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	agentID := r.PathValue("id")
+	body, err := agentQueries.Send(r, agentID, map[string]interface{}{"type": "processes"})
+	if err != nil {
+		http.Error(w, err.Error(), agentRequestStatus(err))
+		return
+	}
+	var list ProcessList
+	if err := decodeReply(body, &list); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if list.Error != "" && len(list.Processes) == 0 {
+		http.Error(w, list.Error, http.StatusBadGateway)
+		return
+	}
+	procs, total := filter.Apply(list.Processes)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"agent_id":     agentID,
+		"collected_at": time.Now(),
+		"total":        total,
+		"processes":    procs,
+	})
+}
+
+// handleKillProcess POST /api/agents/{id}/processes/kill {"pid": 123} 또는 {"name": "app", "signal": "kill"}
+// 관리자나 승인 권한이 있는 사용자만 쓸 수 있고 결과와 함께 감사 로그에 남긴다.
+func handleKillProcess(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != "admin" && !user.CanApprove {
+		http.Error(w, "killing processes requires admin role or approval permission", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		PID    int    `json:"pid"`
+		Name   string `json:"name"`
+		Signal string `json:"signal"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Signal = cmp.Or(req.Signal, "term")
+	if (req.PID == 0) == (req.Name == "") || req.PID < 0 {
+		http.Error(w, "exactly one of pid or name required", http.StatusBadRequest)
+		return
+	}
+	if !processSignals[req.Signal] {
+		http.Error(w, "signal must be one of term, kill, int, hup", http.StatusBadRequest)
+		return
+	}
+
+	agentID := r.PathValue("id")
+	fields := map[string]interface{}{
+		"agent_id": agentID,
+		"pid":      req.PID,
+		"name":     req.Name,
+		"signal":   req.Signal,
+	}
+	body, err := agentQueries.Send(r, agentID, map[string]interface{}{
+		"type":   "kill_process",
+		"pid":    req.PID,
+		"name":   req.Name,
+		"signal": req.Signal,
+	})
+	if err != nil {
+		fields["error"] = err.Error()
+		audit.Record("process_kill", user.Name, fields)
+		http.Error(w, err.Error(), agentRequestStatus(err))
+		return
+	}
+	var result KillResult
+	if err := decodeReply(body, &result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	fields["killed"] = result.Killed
+	if len(result.Errors) > 0 {
+		fields["errors"] = result.Errors
+	}
+	audit.Record("process_kill", user.Name, fields)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"agent_id": agentID,
+		"signal":   result.Signal,
+		"killed":   result.Killed,
+		"errors":   result.Errors,
+	})
+}
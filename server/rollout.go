@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 순차 전송 상태
+const (
+	RolloutRunning   = "running"   // 배치 전송 또는 완료 대기 중
+	RolloutWaiting   = "waiting"   // 배치 사이 대기 중
+	RolloutHalted    = "halted"    // 실패가 기준을 넘어 멈춤 (재개 또는 중단 필요)
+	RolloutAborted   = "aborted"   // 중단됨, 남은 대상은 skipped
+	RolloutCompleted = "completed" // 모든 대상에 전송함
+)
+
+// 순차 전송 중 대상 상태를 확인하는 주기
+const rolloutPollInterval = time.Second
+
+// RolloutSpec 대상을 배치로 나누어 보내는 방법
+type RolloutSpec struct {
+	BatchSize     int  `json:"batch_size,omitempty"`     // 배치당 대상 수
+	BatchPercent  int  `json:"batch_percent,omitempty"`  // 전체 대상 대비 배치 비율 (1-100)
+	Pause         int  `json:"pause,omitempty"`          // 배치 사이 대기 시간 (초)
+	MaxConcurrent int  `json:"max_concurrent,omitempty"` // 동시에 실행 중인 대상 수 상한 (0 = 배치 크기)
+	MaxFailures   *int `json:"max_failures,omitempty"`   // 실패가 이 수를 넘으면 멈춤 (없으면 멈추지 않음)
+}
+
+func (s *RolloutSpec) validate() error {
+	switch {
+	case s.BatchSize < 0 || s.Pause < 0 || s.MaxConcurrent < 0:
+		return fmt.Errorf("rollout 값은 0 이상이어야 합니다")
+	case s.BatchSize > 0 && s.BatchPercent > 0:
+		return fmt.Errorf("batch_size와 batch_percent는 함께 사용할 수 없습니다")
+	case s.BatchPercent < 0 || s.BatchPercent > 100:
+		return fmt.Errorf("batch_percent는 1-100 사이여야 합니다")
+	case s.MaxFailures != nil && *s.MaxFailures < 0:
+		return fmt.Errorf("max_failures는 0 이상이어야 합니다")
+	}
+	return nil
+}
+
+// batchSize 전체 대상 수에 대한 배치 크기 (비율은 올림)
+func (s *RolloutSpec) batchSize(total int) int {
+	size := total
+	switch {
+	case s.BatchSize > 0:
+		size = s.BatchSize
+	case s.BatchPercent > 0:
+		size = (total*s.BatchPercent + 99) / 100
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// RolloutState 작업에 기록하는 순차 전송 진행 상태
+type RolloutState struct {
+	RolloutSpec
+	State     string     `json:"state"`
+	Batch     int        `json:"batch"`   // 마지막으로 시작한 배치 번호 (1부터)
+	Batches   int        `json:"batches"` // 전체 배치 수
+	Reason    string     `json:"reason,omitempty"`
+	ResumedBy string     `json:"resumed_by,omitempty"`
+	AbortedBy string     `json:"aborted_by,omitempty"`
+	NextAt    *time.Time `json:"next_at,omitempty"` // 대기 중일 때 다음 배치 시각
+}
+
+func newRolloutState(spec RolloutSpec, total int) *RolloutState {
+	size := spec.batchSize(total)
+	return &RolloutState{
+		RolloutSpec: spec,
+		State:       RolloutRunning,
+		Batches:     (total + size - 1) / size,
+	}
+}
+
+// active 아직 진행 중인 순차 전송 여부
+func (r *RolloutState) active() bool {
+	return r.State == RolloutRunning || r.State == RolloutWaiting || r.State == RolloutHalted
+}
+
+// rolloutControl 대시보드의 재개/중단 요청
+type rolloutControl struct {
+	action string // resume | abort
+	user   string
+}
+
+// rolloutManager 진행 중인 순차 전송과 제어 채널
+type rolloutManager struct {
+	mu       sync.Mutex
+	controls map[string]chan rolloutControl
+}
+
+func newRolloutManager() *rolloutManager {
+	return &rolloutManager{controls: make(map[string]chan rolloutControl)}
+}
+
+// Start 작업의 순차 전송을 시작한다. 작업은 jobs.Create로 먼저 만들어져 있어야 한다.
+func (m *rolloutManager) Start(jobID string, req CommandRequest, cmdMsg map[string]interface{}) {
+	ctl := make(chan rolloutControl, 1)
+	m.mu.Lock()
+	m.controls[jobID] = ctl
+	m.mu.Unlock()
+
+	go m.run(jobID, req, cmdMsg, ctl)
+}
+
+// Control 진행 중인 순차 전송에 재개/중단을 요청한다.
+func (m *rolloutManager) Control(jobID string, c rolloutControl) error {
+	m.mu.Lock()
+	ctl, ok := m.controls[jobID]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("진행 중인 순차 전송이 아닙니다")
+	}
+	select {
+	case ctl <- c:
+		return nil
+	default:
+		return fmt.Errorf("이전 요청을 처리하는 중입니다")
+	}
+}
+
+func (m *rolloutManager) run(jobID string, req CommandRequest, cmdMsg map[string]interface{}, ctl chan rolloutControl) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.controls, jobID)
+		m.mu.Unlock()
+	}()
+
+	spec := *req.Rollout
+	total := jobs.TargetCount(jobID)
+	size := spec.batchSize(total)
+	maxConcurrent := spec.MaxConcurrent
+	if maxConcurrent <= 0 || maxConcurrent > size {
+		maxConcurrent = size
+	}
+	baseline := 0 // 재개한 시점의 실패 수 (재개 후 새 실패만 센다)
+
+	// wait ctl 요청이나 d가 지나기를 기다린다. 중단 요청이면 false.
+	wait := func(d time.Duration) bool {
+		select {
+		case c := <-ctl:
+			if c.action == "abort" {
+				m.abort(jobID, c.user)
+				return false
+			}
+		case <-time.After(d):
+		}
+		return true
+	}
+	// halted 실패가 기준을 넘었으면 멈추고 재개/중단을 기다린다. 중단되면 false.
+	halted := func() bool {
+		failures := jobs.Failures(jobID)
+		if spec.MaxFailures == nil || failures-baseline <= *spec.MaxFailures {
+			return true
+		}
+		reason := fmt.Sprintf("실패 %d건이 기준 %d건을 넘음", failures-baseline, *spec.MaxFailures)
+		jobs.SetRollout(jobID, func(r *RolloutState) {
+			r.State = RolloutHalted
+			r.Reason = reason
+			r.NextAt = nil
+		})
+		log.Printf("rollout %s halted: %s", jobID, reason)
+		requestedBy, _ := cmdMsg["requested_by"].(string)
+		audit.Record("rollout_halted", requestedBy, map[string]interface{}{
+			"command_id": jobID,
+			"reason":     reason,
+		})
+		for {
+			c := <-ctl
+			if c.action == "abort" {
+				m.abort(jobID, c.user)
+				return false
+			}
+			if c.action == "resume" {
+				baseline = jobs.Failures(jobID)
+				jobs.SetRollout(jobID, func(r *RolloutState) {
+					r.State = RolloutRunning
+					r.Reason = ""
+					r.ResumedBy = c.user
+				})
+				audit.Record("rollout_resumed", c.user, map[string]interface{}{"command_id": jobID})
+				return true
+			}
+		}
+	}
+
+	for batch := 1; ; batch++ {
+		ids := jobs.Pending(jobID, size)
+		if len(ids) == 0 {
+			jobs.SetRollout(jobID, func(r *RolloutState) {
+				r.State = RolloutCompleted
+				r.NextAt = nil
+			})
+			return
+		}
+		jobs.SetRollout(jobID, func(r *RolloutState) {
+			r.State = RolloutRunning
+			r.Batch = batch
+			r.NextAt = nil
+		})
+
+		for _, agentID := range ids {
+			for jobs.InFlight(jobID) >= maxConcurrent {
+				if !wait(rolloutPollInterval) {
+					return
+				}
+			}
+			if !halted() {
+				return
+			}
+			rolloutDispatch(agentID, jobID, req, cmdMsg)
+		}
+
+		// 배치가 모두 끝날 때까지 기다린다
+		for !jobs.Finished(jobID, ids) {
+			if !wait(rolloutPollInterval) {
+				return
+			}
+		}
+		if !halted() {
+			return
+		}
+
+		if spec.Pause > 0 && len(jobs.Pending(jobID, 1)) > 0 {
+			next := time.Now().Add(time.Duration(spec.Pause) * time.Second)
+			jobs.SetRollout(jobID, func(r *RolloutState) {
+				r.State = RolloutWaiting
+				r.NextAt = &next
+			})
+			// 대기 중 재개 요청은 대기를 건너뛴다
+			if !wait(time.Until(next)) {
+				return
+			}
+		}
+	}
+}
+
+// abort 남은 대상을 건너뛰고 순차 전송을 끝낸다.
+func (m *rolloutManager) abort(jobID, user string) {
+	jobs.Skip(jobID, "rollout aborted by "+user)
+	jobs.SetRollout(jobID, func(r *RolloutState) {
+		r.State = RolloutAborted
+		r.AbortedBy = user
+		r.NextAt = nil
+	})
+	audit.Record("rollout_aborted", user, map[string]interface{}{"command_id": jobID})
+}
+
+// rolloutDispatch 순차 전송 대상 한 대에 명령을 보낸다. 그 사이 연결이 끊겼으면 offline.
+func rolloutDispatch(agentID, jobID string, req CommandRequest, cmdMsg map[string]interface{}) {
+	agentsMutex.Lock()
+	defer agentsMutex.Unlock()
+
+	agent := agentByID(agentID)
+	if agent == nil {
+		jobs.Offline(jobID, agentID, "agent disconnected before its batch")
+		return
+	}
+	dispatchCommand(agent, jobID, req, cmdMsg)
+}
+
+// handleRolloutControl POST /api/jobs/{id}/resume, /abort (요청자 또는 admin)
+func handleRolloutControl(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireDashboardUser(w, r)
+		if !ok {
+			return
+		}
+		id := r.PathValue("id")
+		job, ok := jobs.Get(id)
+		if !ok {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		if user.Role != "admin" && user.Name != job.RequestedBy {
+			http.Error(w, "only the requester or an admin can control this rollout", http.StatusForbidden)
+			return
+		}
+		if err := rollouts.Control(id, rolloutControl{action: action, user: user.Name}); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
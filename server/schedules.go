@@ -0,0 +1,592 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+// 서버가 멈춘 동안 놓친 실행 처리 방법
+const (
+	MissedSkip    = "skip"     // 건너뛰고 다음 예정 시각부터 실행 (기본값)
+	MissedRunOnce = "run_once" // 서버가 다시 시작되면 한 번만 실행
+)
+
+const (
+	// 예정 시각보다 이만큼 늦게 발견하면 놓친 실행으로 본다
+	scheduleMissGrace = 2 * time.Minute
+	// 예약 실행 확인 주기
+	scheduleTickInterval = 15 * time.Second
+	// 목록/미리보기에 보여 주는 다음 실행 시각 수
+	schedulePreviewCount = 5
+)
+
+var (
+	// errScheduleStorage 예약 파일 저장 실패 (요청 오류가 아닌 서버 오류)
+	errScheduleStorage = errors.New("예약 저장 실패")
+	// errScheduleNotFound 없는 예약 ID
+	errScheduleNotFound = errors.New("예약을 찾을 수 없습니다")
+)
+
+// ScheduleTarget 예약 실행 대상. 모두 비어 있으면 연결된 전체 에이전트.
+type ScheduleTarget struct {
+	Group     string   `json:"group,omitempty"`
+	Hostnames []string `json:"hostnames,omitempty"` // 재접속해도 바뀌지 않는 호스트 이름으로 지정
+}
+
+// Schedule cron 식에 따라 반복 실행하는 명령
+type Schedule struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Cron      string         `json:"cron"`     // 분 시 일 월 요일 (@daily 등 단축형 허용)
+	Timezone  string         `json:"timezone"` // IANA 시간대 (예: Asia/Seoul), 비어 있으면 서버 로컬 시간
+	Enabled   bool           `json:"enabled"`
+	Missed    string         `json:"missed"` // skip | run_once
+	Target    ScheduleTarget `json:"target"`
+	Request   CommandRequest `json:"request"`
+	CreatedBy string         `json:"created_by"`
+	UpdatedBy string         `json:"updated_by"`
+	UpdatedAt time.Time      `json:"updated_at"`
+
+	// PlaybookSHA256 저장할 때 검토한 플레이북 정의의 요약값. 정의가 바뀌면 다시 저장할 때까지 실행하지 않는다.
+	PlaybookSHA256 string `json:"playbook_sha256,omitempty"`
+
+	// Since 이 시각 이후의 예정 시각만 실행한다 (저장, 활성화, 실행, 건너뛰기 때 갱신)
+	Since     time.Time  `json:"since"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastJobID string     `json:"last_job_id,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+
+	NextRuns []time.Time `json:"next_runs,omitempty"` // 조회 시 계산
+}
+
+// location 예약 시간대
+func (s *Schedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+// nextRuns after 이후 예정 시각 count개
+func nextRuns(spec *cronSpec, loc *time.Location, after time.Time, count int) []time.Time {
+	var list []time.Time
+	for len(list) < count {
+		next := spec.Next(after, loc)
+		if next.IsZero() {
+			break
+		}
+		list = append(list, next)
+		after = next
+	}
+	return list
+}
+
+// scheduleStore 예약 목록을 data_dir/schedules.json 에 저장하고 예정 시각에 명령을 보낸다.
+type scheduleStore struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]*Schedule
+}
+
+func newScheduleStore(cfg *config.Config) *scheduleStore {
+	s := &scheduleStore{
+		path:  filepath.Join(cfg.DataDir, "schedules.json"),
+		items: make(map[string]*Schedule),
+	}
+	var list []*Schedule
+	if err := loadJSONFile(s.path, &list); err != nil && !os.IsNotExist(err) {
+		log.Printf("예약 목록을 읽을 수 없습니다: %v", err)
+	}
+	for _, item := range list {
+		s.items[item.ID] = item
+	}
+	return s
+}
+
+// save mu를 잡은 상태에서 호출
+func (s *scheduleStore) save() error {
+	list := make([]*Schedule, 0, len(s.items))
+	for _, item := range s.items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if err := saveJSONFile(s.path, list); err != nil {
+		log.Printf("예약 목록 저장 실패: %v", err)
+		return fmt.Errorf("%w: %v", errScheduleStorage, err)
+	}
+	return nil
+}
+
+// withNextRuns 다음 실행 시각을 채운 사본
+func (s *Schedule) withNextRuns(now time.Time) *Schedule {
+	copied := *s
+	copied.NextRuns = nil
+	if !s.Enabled {
+		return &copied
+	}
+	spec, err := parseCron(s.Cron)
+	if err != nil {
+		return &copied
+	}
+	loc, err := s.location()
+	if err != nil {
+		return &copied
+	}
+	after := s.Since
+	if after.Before(now) {
+		// 놓친 실행은 다음 확인 때 처리되므로 미래 시각만 보여 준다
+		after = now
+	}
+	copied.NextRuns = nextRuns(spec, loc, after, schedulePreviewCount)
+	return &copied
+}
+
+// List 이름순 예약 목록
+func (s *scheduleStore) List() []*Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	list := make([]*Schedule, 0, len(s.items))
+	for _, item := range s.items {
+		list = append(list, item.withNextRuns(now))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// validate 예약 내용을 검사하고 기본값을 채운다.
+func (s *Schedule) validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("예약 이름이 비어 있습니다")
+	}
+	if _, err := parseCron(s.Cron); err != nil {
+		return err
+	}
+	if _, err := s.location(); err != nil {
+		return fmt.Errorf("알 수 없는 시간대 %q", s.Timezone)
+	}
+	switch s.Missed {
+	case "":
+		s.Missed = MissedSkip
+	case MissedSkip, MissedRunOnce:
+	default:
+		return fmt.Errorf("missed는 skip 또는 run_once 여야 합니다")
+	}
+	// 대상은 Target으로만 지정한다 (에이전트 ID는 재접속하면 바뀜)
+	s.Request.AgentID = ""
+	s.Request.Group = s.Target.Group
+	s.Request.Hostnames = s.Target.Hostnames
+	return nil
+}
+
+// Save 예약을 새로 만들거나 (ID가 비어 있으면) 기존 예약을 바꾼다.
+func (s *scheduleStore) Save(item Schedule, user string) (*Schedule, error) {
+	if err := item.validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item.ID == "" {
+		item.ID = newID()
+		item.CreatedBy = user
+	} else {
+		old, ok := s.items[item.ID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errScheduleNotFound, item.ID)
+		}
+		item.CreatedBy = old.CreatedBy
+		item.LastRun = old.LastRun
+		item.LastJobID = old.LastJobID
+	}
+	item.UpdatedBy = user
+	item.UpdatedAt = now
+	item.Since = now
+	item.LastError = ""
+	item.NextRuns = nil
+
+	prev := s.items[item.ID]
+	s.items[item.ID] = &item
+	if err := s.save(); err != nil {
+		if prev != nil {
+			s.items[item.ID] = prev
+		} else {
+			delete(s.items, item.ID)
+		}
+		return nil, err
+	}
+	return item.withNextRuns(now), nil
+}
+
+// SetEnabled 예약을 켜거나 끈다. 다시 켜면 그 시각 이후부터 실행한다.
+func (s *scheduleStore) SetEnabled(id string, enabled bool, user string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errScheduleNotFound, id)
+	}
+	now := time.Now()
+	if enabled && !item.Enabled {
+		item.Since = now
+	}
+	item.Enabled = enabled
+	item.UpdatedBy = user
+	item.UpdatedAt = now
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return item.withNextRuns(now), nil
+}
+
+// Delete 예약을 지운다.
+func (s *scheduleStore) Delete(id string) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errScheduleNotFound, id)
+	}
+	delete(s.items, id)
+	if err := s.save(); err != nil {
+		s.items[id] = item
+		return nil, err
+	}
+	return item, nil
+}
+
+// due 실행할 예약을 고르고, 놓친 실행은 missed 정책에 따라 건너뛰거나 한 번만 실행한다.
+func (s *scheduleStore) due(now time.Time) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var runs []Schedule
+	changed := false
+	for _, item := range s.items {
+		if !item.Enabled {
+			continue
+		}
+		spec, err := parseCron(item.Cron)
+		if err != nil {
+			continue
+		}
+		loc, err := item.location()
+		if err != nil {
+			continue
+		}
+		next := spec.Next(item.Since, loc)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		if now.Sub(next) > scheduleMissGrace {
+			// 서버가 멈춰 있던 동안의 예정 시각 (여러 번 놓쳤어도 한 번으로 본다)
+			audit.Record("schedule_missed", "schedule:"+item.Name, map[string]interface{}{
+				"schedule_id": item.ID,
+				"missed_at":   next,
+				"policy":      item.Missed,
+			})
+			item.Since = now
+			changed = true
+			if item.Missed != MissedRunOnce {
+				item.LastError = fmt.Sprintf("%s 실행을 놓쳐 건너뜀", next.In(loc).Format("2006-01-02 15:04"))
+				continue
+			}
+		} else {
+			item.Since = next
+			changed = true
+		}
+		runs = append(runs, *item)
+	}
+	if changed {
+		s.save()
+	}
+	return runs
+}
+
+// recordRun 실행 결과(작업 ID)를 예약에 기록한다.
+func (s *scheduleStore) recordRun(id string, at time.Time, jobID, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return
+	}
+	item.LastRun = &at
+	item.LastJobID = jobID
+	item.LastError = errMsg
+	s.save()
+}
+
+// runLoop 예정 시각이 된 예약을 실행한다.
+func (s *scheduleStore) runLoop() {
+	ticker := time.NewTicker(scheduleTickInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, item := range s.due(now) {
+			s.run(item, now)
+		}
+	}
+}
+
+// run 예약 명령을 현재 연결된 대상 에이전트에 보내고 작업으로 기록한다.
+func (s *scheduleStore) run(item Schedule, now time.Time) {
+	requestedBy := "schedule:" + item.Name
+	if item.Request.Playbook != nil {
+		// 플레이북은 실행할 때마다 현재 정의를 읽고, 예약을 저장할 때 검토한 정의와 같을 때만 실행한다
+		run := &PlaybookRun{Name: item.Request.Playbook.Name, Vars: item.Request.Playbook.Vars}
+		err := playbooks.Resolve(run)
+		if err == nil && run.digest != item.PlaybookSHA256 {
+			err = fmt.Errorf("플레이북 %s 정의가 예약 저장 후 바뀌어 실행하지 않았습니다 (예약을 다시 저장하면 실행)", run.Name)
+		}
+		if err != nil {
+			log.Printf("예약 %s 실행 안 함: %v", item.Name, err)
+			audit.Record("schedule_blocked", requestedBy, map[string]interface{}{
+				"schedule_id": item.ID,
+				"playbook":    run.Name,
+				"error":       err.Error(),
+			})
+			s.recordRun(item.ID, now, "", err.Error())
+			return
+		}
+		item.Request.Playbook = run
+	}
+
+	agentsMutex.Lock()
+	targets := scheduleTargets(item.Target)
+	ids := agentIDs(targets)
+	// 실행할 때마다 현재 대상과 명령으로 위험 명령 규칙을 평가한다
+	reasons := approvals.Check(item.Request, targets)
+	if len(reasons) > 0 {
+		agentsMutex.Unlock()
+		s.submitForApproval(item, ids, reasons, now)
+		return
+	}
+	jobID := sendCommandToAgents(targets, item.Request, requestedBy, "")
+	agentsMutex.Unlock()
+
+	errMsg := ""
+	if len(targets) == 0 {
+		errMsg = "연결된 대상 에이전트가 없습니다"
+	}
+	log.Printf("예약 %s 실행: 작업 %s, 대상 %d대", item.Name, jobID, len(ids))
+	audit.Record("command_sent", requestedBy, map[string]interface{}{
+		"command_id":  jobID,
+		"schedule_id": item.ID,
+		"command":     item.Request.Command,
+		"targets":     ids,
+	})
+	s.recordRun(item.ID, now, jobID, errMsg)
+}
+
+// submitForApproval 위험 명령 규칙에 해당하는 예약 실행을 보내지 않고 승인 대기로 등록한다.
+// 승인되면 승인한 사용자가 보낸 것처럼 요청 시점의 대상에 실행된다.
+func (s *scheduleStore) submitForApproval(item Schedule, ids []string, reasons []string, now time.Time) {
+	requestedBy := "schedule:" + item.Name
+	p, added := approvals.SubmitScheduled(&item, ids, reasons)
+	if !added {
+		log.Printf("예약 %s 실행 건너뜀: 이전 실행 승인 대기 중 (%s)", item.Name, p.ID)
+		s.recordRun(item.ID, now, "", "이전 실행이 아직 승인을 기다리고 있어 건너뜀")
+		return
+	}
+	log.Printf("예약 %s 실행: 승인 대기 %s, 대상 %d대", item.Name, p.ID, len(ids))
+	audit.Record("command_approval_requested", requestedBy, map[string]interface{}{
+		"approval_id": p.ID,
+		"schedule_id": item.ID,
+		"command":     item.Request.Command,
+		"targets":     ids,
+		"reasons":     reasons,
+	})
+	broadcastApprovalUpdate(p)
+	s.recordRun(item.ID, now, "", "승인 대기 중: "+strings.Join(reasons, ", "))
+}
+
+// scheduleTargets 예약 대상에 해당하는 연결된 에이전트 (agentsMutex를 잡은 상태에서 호출)
+func scheduleTargets(target ScheduleTarget) []*Agent {
+	hosts := make(map[string]bool, len(target.Hostnames))
+	for _, h := range target.Hostnames {
+		hosts[strings.ToLower(h)] = true
+	}
+
+	var list []*Agent
+	for _, agent := range agents {
+		if target.Group != "" && (agent.Info == nil || agent.Info.Group != target.Group) {
+			continue
+		}
+		if len(hosts) > 0 && (agent.Info == nil || !hosts[strings.ToLower(agent.Info.Hostname)]) {
+			continue
+		}
+		list = append(list, agent)
+	}
+	return list
+}
+
+// scheduleApprovalReasons 지금 실행하면 승인이 필요한 이유 (감사 로그용).
+// 예약 실행은 실행할 때마다 다시 평가해 해당하면 승인 대기로 등록한다.
+func scheduleApprovalReasons(item *Schedule) []string {
+	agentsMutex.Lock()
+	defer agentsMutex.Unlock()
+	return approvals.Check(item.Request, scheduleTargets(item.Target))
+}
+
+// requireAdmin admin 역할이 아니면 403을 응답한다.
+func requireAdmin(w http.ResponseWriter, user *config.DashboardUser, action string) bool {
+	if user.Role != "admin" {
+		http.Error(w, action+" requires admin role", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func scheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errScheduleStorage):
+		return http.StatusInternalServerError
+	case errors.Is(err, errScheduleNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// handleListSchedules GET /api/schedules
+func handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, schedules.List())
+}
+
+// handlePreviewSchedule GET /api/schedules/preview?cron=...&timezone=...&count=N
+func handlePreviewSchedule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	spec, err := parseCron(q.Get("cron"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item := Schedule{Timezone: q.Get("timezone")}
+	loc, err := item.location()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("알 수 없는 시간대 %q", item.Timezone), http.StatusBadRequest)
+		return
+	}
+	count := schedulePreviewCount
+	if n, err := strconv.Atoi(q.Get("count")); err == nil && n > 0 && n <= 50 {
+		count = n
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"timezone":  loc.String(),
+		"next_runs": nextRuns(spec, loc, time.Now(), count),
+	})
+}
+
+// handleSaveSchedule POST /api/schedules (id가 있으면 수정)
+func handleSaveSchedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok || !requireAdmin(w, user, "saving schedules") {
+		return
+	}
+
+	var body struct {
+		Schedule
+		Request map[string]interface{} `json:"request"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := parseCommandRequest(body.Request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item := body.Schedule
+	item.Request = req
+	item.PlaybookSHA256 = ""
+	if req.Playbook != nil {
+		item.PlaybookSHA256 = req.Playbook.digest
+	}
+	if err := item.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reasons := scheduleApprovalReasons(&item)
+
+	saved, err := schedules.Save(item, user.Name)
+	if err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+	audit.Record("schedule_saved", user.Name, map[string]interface{}{
+		"schedule_id": saved.ID,
+		"name":        saved.Name,
+		"cron":        saved.Cron,
+		"timezone":    saved.Timezone,
+		"command":     saved.Request.Command,
+		"enabled":     saved.Enabled,
+		"reasons":     reasons,
+	})
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// handleEnableSchedule POST /api/schedules/{id}/enable, /disable
+func handleEnableSchedule(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireDashboardUser(w, r)
+		if !ok || !requireAdmin(w, user, "changing schedules") {
+			return
+		}
+		item, err := schedules.SetEnabled(r.PathValue("id"), enabled, user.Name)
+		if err != nil {
+			http.Error(w, err.Error(), scheduleErrorStatus(err))
+			return
+		}
+		audit.Record("schedule_enabled", user.Name, map[string]interface{}{
+			"schedule_id": item.ID,
+			"name":        item.Name,
+			"enabled":     enabled,
+		})
+		writeJSON(w, http.StatusOK, item)
+	}
+}
+
+// handleDeleteSchedule DELETE /api/schedules/{id}
+func handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok || !requireAdmin(w, user, "deleting schedules") {
+		return
+	}
+	item, err := schedules.Delete(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+	audit.Record("schedule_deleted", user.Name, map[string]interface{}{
+		"schedule_id": item.ID,
+		"name":        item.Name,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopc-server/config"
+)
+
+func TestScheduledPlaybookChangedIsBlocked(t *testing.T) {
+	dir := t.TempDir()
+	savedPlaybooks, savedAudit := playbooks, audit
+	playbooks = newPlaybookLibrary(filepath.Join(dir, "playbooks"))
+	audit = newAuditLog(filepath.Join(dir, "audit.log"))
+	t.Cleanup(func() { playbooks, audit = savedPlaybooks, savedAudit })
+
+	definition := "name: nightly\nsteps:\n  - name: clean\n    shell: echo clean\n"
+	if _, err := playbooks.Save([]byte(definition)); err != nil {
+		t.Fatal(err)
+	}
+	run := &PlaybookRun{Name: "nightly"}
+	if err := playbooks.Resolve(run); err != nil {
+		t.Fatal(err)
+	}
+	again := &PlaybookRun{Name: "nightly"}
+	playbooks.Resolve(again)
+	if run.digest == "" || run.digest != again.digest {
+		t.Fatalf("digest not stable: %q, %q", run.digest, again.digest)
+	}
+
+	s := newScheduleStore(&config.Config{DataDir: dir})
+	item, err := s.Save(Schedule{
+		Name:           "nightly",
+		Cron:           "@daily",
+		Request:        CommandRequest{Playbook: run},
+		PlaybookSHA256: run.digest,
+	}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 저장 후 다른 사용자가 정의를 바꿨다
+	if _, err := playbooks.Save([]byte(strings.Replace(definition, "echo clean", "rm -rf /data", 1))); err != nil {
+		t.Fatal(err)
+	}
+	s.run(*item, time.Now())
+
+	got := s.List()[0]
+	if got.LastJobID != "" || !strings.Contains(got.LastError, "바뀌어") {
+		t.Errorf("changed playbook ran: job %q, error %q", got.LastJobID, got.LastError)
+	}
+}
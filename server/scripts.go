@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	scriptNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	paramNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+)
+
+// errScriptStorage 스크립트 파일 저장 실패 (요청 오류가 아닌 서버 오류)
+var errScriptStorage = errors.New("스크립트 저장 실패")
+
+// 파라미터 이름으로 쓸 수 없는 환경 변수 (실행 환경이 깨지는 것을 방지)
+var reservedParamNames = map[string]bool{
+	"PATH": true, "PATHEXT": true, "COMSPEC": true, "SYSTEMROOT": true, "WINDIR": true,
+	"HOME": true, "USERPROFILE": true, "TEMP": true, "TMP": true, "SHELL": true,
+}
+
+// ScriptParam 스크립트 파라미터 선언. 값은 같은 이름의 환경 변수로 전달된다.
+type ScriptParam struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"` // string | int | bool | choice
+	Required    bool     `json:"required,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Choices     []string `json:"choices,omitempty"` // type이 choice일 때 허용 값
+	Description string   `json:"description,omitempty"`
+}
+
+// ScriptVariant OS별 스크립트 본문
+type ScriptVariant struct {
+	OS    string `json:"os"`    // windows | linux | darwin | "" (모든 OS)
+	Shell string `json:"shell"` // sh | bash | cmd | powershell
+	Body  string `json:"body"`
+}
+
+// ScriptVersion 저장된 스크립트의 한 버전 (저장 후 변경하지 않음)
+type ScriptVersion struct {
+	Version   int             `json:"version"`
+	Params    []ScriptParam   `json:"params"`
+	Variants  []ScriptVariant `json:"variants"`
+	Author    string          `json:"author"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Script 이름이 붙은 스크립트와 버전 이력
+type Script struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Versions    []ScriptVersion `json:"versions"`
+}
+
+// latest 최신 버전
+func (s *Script) latest() *ScriptVersion {
+	return &s.Versions[len(s.Versions)-1]
+}
+
+// ScriptSummary 스크립트 목록 항목
+type ScriptSummary struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Latest      ScriptVersion `json:"latest"`
+}
+
+// ScriptRun 대시보드가 요청한 스크립트 실행
+type ScriptRun struct {
+	Name    string            `json:"name"`
+	Version int               `json:"version,omitempty"` // 0 = 최신 (요청 해석 후 실제 버전으로 채워짐)
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// String 표시/승인 규칙 검사용 명령 문자열
+func (r *ScriptRun) String() string {
+	keys := make([]string, 0, len(r.Params))
+	for k := range r.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{fmt.Sprintf("script:%s@v%d", r.Name, r.Version)}
+	for _, k := range keys {
+		v := r.Params[k]
+		if v == "" || strings.ContainsAny(v, " \t\"'") {
+			v = fmt.Sprintf("%q", v)
+		}
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ScriptPayload 에이전트로 보내는 스크립트 (에이전트 OS에 맞는 변형)
+type ScriptPayload struct {
+	Name    string            `json:"name"`
+	Version int               `json:"version"`
+	Shell   string            `json:"shell"`
+	Body    string            `json:"body"`
+	SHA256  string            `json:"sha256"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// scriptLibrary data_dir/scripts/<name>.json 에 저장되는 스크립트 라이브러리
+type scriptLibrary struct {
+	mu      sync.Mutex
+	dir     string
+	scripts map[string]*Script
+}
+
+func newScriptLibrary(dir string) *scriptLibrary {
+	l := &scriptLibrary{
+		dir:     dir,
+		scripts: make(map[string]*Script),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		log.Printf("스크립트: 목록 읽기 오류: %v", err)
+	}
+	for _, path := range files {
+		var s Script
+		if err := loadJSONFile(path, &s); err != nil {
+			log.Printf("스크립트: %s 읽기 오류: %v", path, err)
+			continue
+		}
+		if !scriptNamePattern.MatchString(s.Name) || len(s.Versions) == 0 {
+			log.Printf("스크립트: %s 무시 (이름 또는 버전 없음)", path)
+			continue
+		}
+		l.scripts[s.Name] = &s
+	}
+	log.Printf("스크립트: %d개 로드", len(l.scripts))
+	return l
+}
+
+// List 스크립트 목록 (이름순)
+func (l *scriptLibrary) List() []ScriptSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list := make([]ScriptSummary, 0, len(l.scripts))
+	for _, s := range l.scripts {
+		list = append(list, ScriptSummary{
+			Name:        s.Name,
+			Description: s.Description,
+			Latest:      *s.latest(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get 버전 이력을 포함한 스크립트
+func (l *scriptLibrary) Get(name string) (Script, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.scripts[name]
+	if !ok {
+		return Script{}, false
+	}
+	return *s, true
+}
+
+// Save 스크립트의 새 버전을 저장한다. 최신 버전과 내용이 같으면 최신 버전을 그대로 반환한다.
+func (l *scriptLibrary) Save(name, description string, params []ScriptParam, variants []ScriptVariant, author string) (ScriptVersion, error) {
+	if !scriptNamePattern.MatchString(name) {
+		return ScriptVersion{}, fmt.Errorf("스크립트 이름은 영문, 숫자, _, - 만 사용할 수 있습니다")
+	}
+	if err := validateScriptParams(params); err != nil {
+		return ScriptVersion{}, err
+	}
+	if err := validateScriptVariants(variants); err != nil {
+		return ScriptVersion{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, exists := l.scripts[name]
+	if !exists {
+		s = &Script{Name: name}
+	}
+	sameContent := exists && sameScriptContent(s.latest(), params, variants)
+	if sameContent && description == s.Description {
+		return *s.latest(), nil
+	}
+
+	updated := *s
+	updated.Description = description
+	if !sameContent {
+		updated.Versions = append(append([]ScriptVersion{}, s.Versions...), ScriptVersion{
+			Version:   len(s.Versions) + 1,
+			Params:    params,
+			Variants:  variants,
+			Author:    author,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	if err := saveJSONFile(filepath.Join(l.dir, name+".json"), &updated); err != nil {
+		return ScriptVersion{}, fmt.Errorf("%w: %v", errScriptStorage, err)
+	}
+	l.scripts[name] = &updated
+	return *updated.latest(), nil
+}
+
+func sameScriptContent(v *ScriptVersion, params []ScriptParam, variants []ScriptVariant) bool {
+	a, _ := json.Marshal([]interface{}{v.Params, v.Variants})
+	b, _ := json.Marshal([]interface{}{params, variants})
+	return bytes.Equal(a, b)
+}
+
+// version 이름과 버전(0 = 최신)으로 스크립트 버전을 찾는다. l.mu를 잡은 상태에서 호출한다.
+func (l *scriptLibrary) version(name string, version int) (*ScriptVersion, error) {
+	s, ok := l.scripts[name]
+	if !ok {
+		return nil, fmt.Errorf("스크립트를 찾을 수 없습니다: %s", name)
+	}
+	if version == 0 {
+		return s.latest(), nil
+	}
+	if version < 0 || version > len(s.Versions) {
+		return nil, fmt.Errorf("스크립트 %s에 버전 %d이 없습니다", name, version)
+	}
+	return &s.Versions[version-1], nil
+}
+
+// Resolve 실행 요청의 버전을 확정하고 파라미터 값을 검증/정규화한다.
+func (l *scriptLibrary) Resolve(run *ScriptRun) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v, err := l.version(run.Name, run.Version)
+	if err != nil {
+		return err
+	}
+	params, err := resolveScriptParams(v.Params, run.Params)
+	if err != nil {
+		return err
+	}
+	run.Version = v.Version
+	run.Params = params
+	return nil
+}
+
+// Payload 에이전트 OS에 맞는 변형을 골라 전송할 스크립트를 만든다.
+func (l *scriptLibrary) Payload(run *ScriptRun, agentOS string) (*ScriptPayload, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v, err := l.version(run.Name, run.Version)
+	if err != nil {
+		return nil, err
+	}
+	variant := v.variantFor(agentOS)
+	if variant == nil {
+		return nil, fmt.Errorf("스크립트 %s v%d에 %s용 변형이 없습니다", run.Name, v.Version, agentOS)
+	}
+	sum := sha256.Sum256([]byte(variant.Body))
+	return &ScriptPayload{
+		Name:    run.Name,
+		Version: v.Version,
+		Shell:   variant.Shell,
+		Body:    variant.Body,
+		SHA256:  hex.EncodeToString(sum[:]),
+		Params:  run.Params,
+	}, nil
+}
+
+// Text 승인 규칙 검사용으로 모든 변형 본문을 이어 붙인다.
+func (l *scriptLibrary) Text(run *ScriptRun) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v, err := l.version(run.Name, run.Version)
+	if err != nil {
+		return ""
+	}
+	bodies := make([]string, 0, len(v.Variants))
+	for _, variant := range v.Variants {
+		bodies = append(bodies, variant.Body)
+	}
+	return strings.Join(bodies, "\n")
+}
+
+// variantFor OS가 정확히 일치하는 변형, 없으면 모든 OS용 변형
+func (v *ScriptVersion) variantFor(agentOS string) *ScriptVariant {
+	var fallback *ScriptVariant
+	for i := range v.Variants {
+		switch v.Variants[i].OS {
+		case agentOS:
+			return &v.Variants[i]
+		case "":
+			fallback = &v.Variants[i]
+		}
+	}
+	return fallback
+}
+
+func validateScriptParams(params []ScriptParam) error {
+	seen := map[string]bool{}
+	for _, p := range params {
+		if !paramNamePattern.MatchString(p.Name) {
+			return fmt.Errorf("잘못된 파라미터 이름: %q", p.Name)
+		}
+		upper := strings.ToUpper(p.Name)
+		if reservedParamNames[upper] {
+			return fmt.Errorf("파라미터 이름 %s는 시스템 환경 변수와 겹칩니다", p.Name)
+		}
+		if seen[upper] {
+			return fmt.Errorf("중복된 파라미터: %s", p.Name)
+		}
+		seen[upper] = true
+
+		switch p.Type {
+		case "string", "int", "bool":
+		case "choice":
+			if len(p.Choices) == 0 {
+				return fmt.Errorf("파라미터 %s: choice 타입은 choices가 필요합니다", p.Name)
+			}
+		default:
+			return fmt.Errorf("파라미터 %s: 지원하지 않는 타입 %q", p.Name, p.Type)
+		}
+		if p.Default != "" {
+			if _, err := checkParamValue(p, p.Default); err != nil {
+				return fmt.Errorf("파라미터 %s 기본값: %v", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateScriptVariants(variants []ScriptVariant) error {
+	if len(variants) == 0 {
+		return fmt.Errorf("스크립트 본문(variants)이 없습니다")
+	}
+	seen := map[string]bool{}
+	for _, v := range variants {
+		switch v.OS {
+		case "", "windows", "linux", "darwin":
+		default:
+			return fmt.Errorf("지원하지 않는 OS: %q", v.OS)
+		}
+		if seen[v.OS] {
+			return fmt.Errorf("OS %q 변형이 중복되었습니다", v.OS)
+		}
+		seen[v.OS] = true
+
+		switch v.Shell {
+		case "sh", "bash", "powershell":
+		case "cmd":
+			if v.OS != "windows" {
+				return fmt.Errorf("cmd 스크립트는 windows 변형에만 사용할 수 있습니다")
+			}
+		default:
+			return fmt.Errorf("지원하지 않는 셸: %q", v.Shell)
+		}
+		if strings.TrimSpace(v.Body) == "" {
+			return fmt.Errorf("OS %q 변형의 본문이 비어 있습니다", v.OS)
+		}
+		if strings.ContainsRune(v.Body, 0) {
+			return fmt.Errorf("OS %q 변형의 본문에 NUL 문자가 있습니다", v.OS)
+		}
+	}
+	return nil
+}
+
+// resolveScriptParams 선언에 맞춰 값을 검증하고 생략된 값은 기본값으로 채운다.
+func resolveScriptParams(decl []ScriptParam, values map[string]string) (map[string]string, error) {
+	known := map[string]bool{}
+	resolved := make(map[string]string, len(decl))
+	for _, p := range decl {
+		known[p.Name] = true
+		value, ok := values[p.Name]
+		if !ok || value == "" {
+			if p.Required && p.Default == "" {
+				return nil, fmt.Errorf("필수 파라미터 %s가 없습니다", p.Name)
+			}
+			value = p.Default
+		}
+		if value != "" {
+			normalized, err := checkParamValue(p, value)
+			if err != nil {
+				return nil, fmt.Errorf("파라미터 %s: %v", p.Name, err)
+			}
+			value = normalized
+		}
+		resolved[p.Name] = value
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("선언되지 않은 파라미터: %s", name)
+		}
+	}
+	return resolved, nil
+}
+
+// checkParamValue 타입에 맞는지 확인하고 정규화한 값을 반환한다.
+func checkParamValue(p ScriptParam, value string) (string, error) {
+	if strings.ContainsRune(value, 0) {
+		return "", fmt.Errorf("값에 NUL 문자가 있습니다")
+	}
+	switch p.Type {
+	case "int":
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("정수가 아닙니다: %q", value)
+		}
+		return strconv.Itoa(n), nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("true/false 값이 아닙니다: %q", value)
+		}
+		return strconv.FormatBool(b), nil
+	case "choice":
+		for _, c := range p.Choices {
+			if c == value {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("허용되지 않은 값: %q", value)
+	}
+	return value, nil
+}
+
+// handleListScripts GET /api/scripts
+func handleListScripts(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, scripts.List())
+}
+
+// handleGetScript GET /api/scripts/{name}
+func handleGetScript(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	s, ok := scripts.Get(r.PathValue("name"))
+	if !ok {
+		http.Error(w, "script not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// handleSaveScript POST /api/scripts - 새 버전 저장 (admin 역할)
+func handleSaveScript(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != "admin" {
+		http.Error(w, "saving scripts requires admin role", http.StatusForbidden)
+		return
+	}
+
+	var body struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Params      []ScriptParam   `json:"params"`
+		Variants    []ScriptVariant `json:"variants"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Params == nil {
+		body.Params = []ScriptParam{}
+	}
+
+	v, err := scripts.Save(body.Name, body.Description, body.Params, body.Variants, user.Name)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errScriptStorage) {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	audit.Record("script_saved", user.Name, map[string]interface{}{
+		"script":  body.Name,
+		"version": v.Version,
+	})
+	writeJSON(w, http.StatusOK, v)
+}
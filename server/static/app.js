@@ -11,8 +11,17 @@ const resultsEmpty = document.getElementById('results-empty');
 let agents = new Map();
 let selectedAgentId = null;
 
-// WebSocket 연결
-const socket = new WebSocket(`ws://${location.host}/ws-dashboard`);
+// 승인 대기 명령 저장
+let approvals = new Map();
+let currentUser = null;
+
+// 실행 중인 명령 (command_id -> {command, targets, done})
+let runningCommands = new Map();
+
+// WebSocket 연결 (저장된 사용자/토큰으로 인증)
+const loginUser = localStorage.getItem('gopc_user') || '';
+const loginToken = localStorage.getItem('gopc_token') || '';
+const socket = new WebSocket(`ws://${location.host}/ws-dashboard?user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`);
 
 socket.onopen = () => {
     console.log('대시보드 WebSocket 연결됨');
@@ -29,6 +38,9 @@ socket.onmessage = (event) => {
 
 socket.onclose = () => {
     console.log('대시보드 WebSocket 연결 종료');
+    if (!currentUser) {
+        document.getElementById('session-user').textContent = '인증 실패 또는 연결 끊김 - 로그인하세요';
+    }
 };
 
 socket.onerror = (error) => {
@@ -47,6 +59,35 @@ function handleMessage(msg) {
         case 'command_result':
             handleCommandResult(msg);
             break;
+        case 'update_status':
+            handleUpdateStatus(msg);
+            break;
+        case 'session':
+            handleSession(msg.user);
+            break;
+        case 'approval_list':
+            approvals.clear();
+            (msg.approvals || []).forEach(a => approvals.set(a.id, a));
+            updateApprovalsDisplay();
+            break;
+        case 'approval_update':
+            handleApprovalUpdate(msg.approval);
+            break;
+        case 'command_output':
+            handleCommandOutput(msg);
+            break;
+        case 'command_dispatched':
+            handleCommandDispatched(msg);
+            break;
+        case 'agent_jobs':
+            handleAgentJobs(msg);
+            break;
+        case 'job_update':
+            handleJobUpdate(msg.job);
+            break;
+        case 'error':
+            alert(msg.error);
+            break;
         default:
             console.log('알 수 없는 메시지 타입:', msg.type);
     }
@@ -102,20 +143,20 @@ function createAgentCard(agent) {
     if (agent.status) {
         statusMetrics = `
             <div class="status-metrics">
-                <div class="metric">
+                <div class="metric" title="${agent.status.load ? '평균 부하 ' + agent.status.load.map(v => v.toFixed(2)).join(' / ') : ''}">
                     <div class="metric-label">CPU 사용률</div>
                     <div class="metric-value">${agent.status.cpu_usage.toFixed(1)}%</div>
                 </div>
-                <div class="metric">
+                <div class="metric" title="${agent.status.memory_total ? `${formatBytes(agent.status.memory_used)} / ${formatBytes(agent.status.memory_total)}` : ''}">
                     <div class="metric-label">메모리 사용률</div>
-                    <div class="metric-value">${agent.status.memory_usage.toFixed(1)} MB</div>
+                    <div class="metric-value">${agent.status.memory_usage.toFixed(1)}%</div>
                 </div>
-                <div class="metric">
+                <div class="metric" title="${escapeHtml((agent.status.disks || []).map(d => `${d.mount} ${d.percent.toFixed(1)}% (${formatBytes(d.used)} / ${formatBytes(d.total)})`).join('\n'))}">
                     <div class="metric-label">디스크 사용률</div>
                     <div class="metric-value">${agent.status.disk_usage.toFixed(1)}%</div>
                 </div>
-                <div class="metric">
-                    <div class="metric-label">업타임</div>
+                <div class="metric" title="${agent.status.agent_uptime ? '에이전트 실행 ' + formatUptime(agent.status.agent_uptime) : ''}">
+                    <div class="metric-label">가동 시간</div>
                     <div class="metric-value">${formatUptime(agent.status.uptime)}</div>
                 </div>
             </div>
@@ -127,7 +168,7 @@ function createAgentCard(agent) {
             <div class="agent-id">
                 ${agent.info ? agent.info.hostname : agent.id}
             </div>
-            <span class="agent-status ${statusClass}">${statusText}</span>
+            <span class="agent-status ${statusClass}">${statusText}${killReason}</span>
         </div>
         <div class="agent-info">
             <div class="agent-info-item">
@@ -144,14 +185,64 @@ function createAgentCard(agent) {
                     <span>${agent.info.mac_addr}</span>
                 </div>
             ` : ''}
+            ${agent.status?.latency_ms ? `
+                <div class="agent-info-item">
+                    <span class="agent-info-label">서버 지연:</span>
+                    <span>${agent.status.latency_ms.toFixed(1)} ms</span>
+                </div>
+            ` : ''}
             <div class="agent-info-item">
                 <span class="agent-info-label">마지막 확인:</span>
                 <span>${lastSeen}</span>
             </div>
         </div>
         ${statusMetrics}
+        ${agent.connected ? `<div style="margin-top: 10px;"><button class="terminal-btn">터미널 열기</button> <button class="jobs-btn">작업 보기</button> <button class="network-btn">네트워크</button> <button class="processes-btn">프로세스</button> <button class="metrics-btn">상태 기록</button></div>` : ''}
     `;
 
+    const terminalBtn = card.querySelector('.terminal-btn');
+    if (terminalBtn) {
+        terminalBtn.addEventListener('click', (e) => {
+            e.stopPropagation();
+            openTerminal(agent);
+        });
+    }
+
+    const jobsBtn = card.querySelector('.jobs-btn');
+    if (jobsBtn) {
+        jobsBtn.addEventListener('click', (e) => {
+            e.stopPropagation();
+            socket.send(JSON.stringify({ type: 'jobs', agent_id: agent.id }));
+        });
+    }
+
+    const networkBtn = card.querySelector('.network-btn');
+    if (networkBtn) {
+        networkBtn.addEventListener('click', (e) => {
+            e.stopPropagation();
+            showAgentNetwork(agent.id);
+        });
+    }
+
+    const processesBtn = card.querySelector('.processes-btn');
+    if (processesBtn) {
+        processesBtn.addEventListener('click', (e) => {
+            e.stopPropagation();
+            processesAgentId = agent.id;
+            loadProcesses();
+        });
+    }
+
+    const metricsBtn = card.querySelector('.metrics-btn');
+    if (metricsBtn) {
+        metricsBtn.addEventListener('click', (e) => {
+            e.stopPropagation();
+            document.getElementById('metrics-kind').value = 'agent';
+            document.getElementById('metrics-target').value = agent.info ? agent.info.hostname : agent.id;
+            loadMetrics();
+        });
+    }
+
     // 카드 클릭 시 선택
     card.addEventListener('click', () => {
         if (agent.connected) {
@@ -172,6 +263,13 @@ function createAgentCard(agent) {
     return card;
 }
 
+// 원격 터미널 창 열기
+function openTerminal(agent) {
+    const name = agent.info ? agent.info.hostname : agent.id;
+    window.open(`terminal.html?agent_id=${encodeURIComponent(agent.id)}&name=${encodeURIComponent(name)}`,
+        `terminal-${agent.id}`, 'width=900,height=600');
+}
+
 // 업타임 포맷팅
 function formatUptime(seconds) {
     const days = Math.floor(seconds / 86400);
@@ -195,29 +293,583 @@ function sendCommand() {
         return;
     }
 
-    const guiMode = document.getElementById('gui-mode').checked;
-    if (guiMode) {
-        command = 'gui:' + command;
+    const msg = {
+        type: 'command'
+    };
+
+    // 구조화 실행: ["prog", "arg1"] 또는 {"argv": [...], "cwd": "...", "env": {...}, "stdin": "...", "shell": "bash"}
+    const mode = document.getElementById('command-mode').value;
+    if (mode === 'exec') {
+        let spec;
+        try {
+            spec = JSON.parse(command);
+        } catch (e) {
+            alert('구조화 실행은 JSON 배열 또는 객체로 입력하세요.\n예: ["ping", "-n", "1", "localhost"]');
+            return;
+        }
+        msg.exec = Array.isArray(spec) ? { argv: spec } : spec;
+    } else {
+        const guiMode = document.getElementById('gui-mode').checked;
+        if (guiMode) {
+            command = 'gui:' + command;
+        }
+        msg.command = command;
+    }
+
+    if (!applyCommandOptions(msg)) {
+        return;
     }
 
+    socket.send(JSON.stringify(msg));
+    commandInput.value = '';
+}
+
+// 대상, 제한 시간, 우선순위를 명령 메시지에 채운다. 대상이 잘못되면 false.
+function applyCommandOptions(msg) {
     const target = document.querySelector('input[name="target"]:checked').value;
 
-    const msg = {
-        type: 'command',
-        command: command
-    };
+    const timeout = parseInt(document.getElementById('command-timeout').value, 10);
+    if (timeout > 0) {
+        msg.timeout = timeout;
+    }
+    if (document.getElementById('background-mode').checked) {
+        msg.priority = 'background';
+    }
+    if (document.getElementById('detached-mode').checked) {
+        msg.detached = true;
+    }
+    const rollout = rolloutOptions();
+    if (rollout) {
+        msg.rollout = rollout;
+    }
+    const attempts = parseInt(document.getElementById('retry-attempts').value, 10);
+    if (attempts > 1) {
+        msg.retry = { max_attempts: attempts };
+        const backoff = parseInt(document.getElementById('retry-backoff').value, 10);
+        if (backoff > 0) {
+            msg.retry.backoff = backoff;
+        }
+    }
+    const deliverWithin = parseInt(document.getElementById('deliver-within').value, 10);
+    if (deliverWithin > 0) {
+        msg.deliver_within = deliverWithin * 60;
+    }
+    if (document.getElementById('maintenance-mode').checked) {
+        msg.maintenance = true;
+        const reason = document.getElementById('maintenance-override').value.trim();
+        if (reason) {
+            msg.override_reason = reason;
+        }
+    }
 
     if (target === 'selected' && selectedAgentId) {
         msg.agent_id = selectedAgentId;
     } else if (target === 'selected' && !selectedAgentId) {
         alert('에이전트를 선택하세요.');
+        return false;
+    } else if (target === 'group') {
+        const group = document.getElementById('target-group').value.trim();
+        if (!group) {
+            alert('그룹 이름을 입력하세요.');
+            return false;
+        }
+        msg.group = group;
+    }
+    return true;
+}
+
+// 순차 배포 옵션 (배치를 지정하지 않으면 null)
+function rolloutOptions() {
+    const batch = document.getElementById('rollout-batch').value.trim();
+    const concurrency = parseInt(document.getElementById('rollout-concurrency').value, 10);
+    if (!batch && !(concurrency > 0)) {
+        return null;
+    }
+    const rollout = {};
+    if (batch.endsWith('%')) {
+        rollout.batch_percent = parseInt(batch, 10);
+    } else if (batch) {
+        rollout.batch_size = parseInt(batch, 10);
+    }
+    const pause = parseInt(document.getElementById('rollout-pause').value, 10);
+    if (pause > 0) {
+        rollout.pause = pause;
+    }
+    if (concurrency > 0) {
+        rollout.max_concurrent = concurrency;
+    }
+    const failures = parseInt(document.getElementById('rollout-failures').value, 10);
+    if (failures >= 0) {
+        rollout.max_failures = failures;
+    }
+    return rollout;
+}
+
+// 스크립트 라이브러리 (name -> 목록 항목)
+let scriptLibrary = new Map();
+
+// 스크립트 API 호출 (대시보드 사용자/토큰으로 인증)
+async function scriptsApi(path, options) {
+    const sep = path.includes('?') ? '&' : '?';
+    const res = await fetch(`/api/scripts${path}${sep}user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`, options);
+    if (!res.ok) {
+        throw new Error(await res.text());
+    }
+    return res.json();
+}
+
+// 스크립트 목록 불러오기
+async function loadScripts() {
+    try {
+        const list = await scriptsApi('');
+        scriptLibrary.clear();
+        list.forEach(s => scriptLibrary.set(s.name, s));
+    } catch (e) {
+        console.error('스크립트 목록 오류:', e);
+        return;
+    }
+
+    const select = document.getElementById('script-select');
+    const current = select.value;
+    select.innerHTML = '<option value="">스크립트 선택</option>';
+    scriptLibrary.forEach(s => {
+        const option = document.createElement('option');
+        option.value = s.name;
+        option.textContent = `${s.name} (v${s.latest.version})${s.description ? ' - ' + s.description : ''}`;
+        select.appendChild(option);
+    });
+    select.value = scriptLibrary.has(current) ? current : '';
+    renderScriptParams();
+}
+
+// 선택한 스크립트의 파라미터 입력 표시
+function renderScriptParams() {
+    const container = document.getElementById('script-params');
+    const script = scriptLibrary.get(document.getElementById('script-select').value);
+    container.innerHTML = '';
+    if (!script) {
+        return;
+    }
+
+    renderParamInputs(container, script.latest.params);
+
+    const oses = script.latest.variants.map(v => `${v.os || '모든 OS'}/${v.shell}`).join(', ');
+    const info = document.createElement('span');
+    info.style.cssText = 'color: #666; font-size: 0.9em;';
+    info.textContent = `변형: ${oses}`;
+    container.appendChild(info);
+}
+
+// 파라미터 선언(스크립트, 명령 템플릿)에 맞는 입력 칸을 추가한다. 값은 data-param으로 읽는다.
+function renderParamInputs(container, params) {
+    params.forEach(p => {
+        const label = document.createElement('label');
+        label.style.cssText = 'display: flex; align-items: center; gap: 5px;';
+        label.title = p.description || '';
+        label.append(`${p.name}${p.required ? ' *' : ''}`);
+
+        let input;
+        if (p.type === 'choice') {
+            input = document.createElement('select');
+            p.choices.forEach(c => {
+                const option = document.createElement('option');
+                option.value = c;
+                option.textContent = c;
+                input.appendChild(option);
+            });
+            input.value = p.default || p.choices[0];
+        } else if (p.type === 'bool') {
+            input = document.createElement('input');
+            input.type = 'checkbox';
+            input.checked = p.default === 'true';
+        } else {
+            input = document.createElement('input');
+            input.type = p.type === 'int' ? 'number' : 'text';
+            input.value = p.default || '';
+            input.size = 12;
+        }
+        input.dataset.param = p.name;
+        label.appendChild(input);
+        container.appendChild(label);
+    });
+}
+
+// 입력 칸의 파라미터 값
+function paramValues(container) {
+    const params = {};
+    container.querySelectorAll('[data-param]').forEach(input => {
+        params[input.dataset.param] = input.type === 'checkbox' ? String(input.checked) : input.value;
+    });
+    return params;
+}
+
+// 선택한 스크립트 실행
+function runScript() {
+    const name = document.getElementById('script-select').value;
+    if (!name) {
+        alert('스크립트를 선택하세요.');
         return;
     }
 
+    const params = paramValues(document.getElementById('script-params'));
+
+    const msg = {
+        type: 'command',
+        script: { name, params }
+    };
+    if (!applyCommandOptions(msg)) {
+        return;
+    }
     socket.send(JSON.stringify(msg));
-    commandInput.value = '';
 }
 
+// 스크립트 편집기 열기 (선택한 스크립트의 최신 버전 또는 새 스크립트 예시)
+function editScript() {
+    const script = scriptLibrary.get(document.getElementById('script-select').value);
+    const def = script ? {
+        name: script.name,
+        description: script.description || '',
+        params: script.latest.params,
+        variants: script.latest.variants
+    } : {
+        name: 'cleanup-temp',
+        description: '임시 파일 정리',
+        params: [{ name: 'DAYS', type: 'int', default: '7', description: '보관 일수' }],
+        variants: [
+            { os: 'windows', shell: 'powershell', body: 'Get-ChildItem $env:TEMP | Where-Object { $_.LastWriteTime -lt (Get-Date).AddDays(-[int]$env:DAYS) } | Remove-Item -Recurse -Force' },
+            { os: 'linux', shell: 'sh', body: 'find /tmp -mindepth 1 -mtime +"$DAYS" -delete' }
+        ]
+    };
+    document.getElementById('script-editor-text').value = JSON.stringify(def, null, 2);
+    document.getElementById('script-editor').style.display = 'block';
+}
+
+// 편집한 스크립트를 새 버전으로 저장
+async function saveScript() {
+    let def;
+    try {
+        def = JSON.parse(document.getElementById('script-editor-text').value);
+    } catch (e) {
+        alert('JSON 형식이 올바르지 않습니다: ' + e.message);
+        return;
+    }
+    try {
+        const version = await scriptsApi('', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(def)
+        });
+        alert(`${def.name} v${version.version} 저장됨`);
+        document.getElementById('script-editor').style.display = 'none';
+        await loadScripts();
+        document.getElementById('script-select').value = def.name;
+        renderScriptParams();
+    } catch (e) {
+        alert('스크립트 저장 실패: ' + e.message);
+    }
+}
+
+document.getElementById('script-select').addEventListener('change', renderScriptParams);
+loadScripts();
+
+// 플레이북 목록 (name -> 목록 항목)
+let playbookLibrary = new Map();
+
+// 플레이북 API 호출 (대시보드 사용자/토큰으로 인증)
+async function playbooksApi(path, options) {
+    const res = await fetch(`/api/playbooks${path}?user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`, options);
+    if (!res.ok) {
+        throw new Error(await res.text());
+    }
+    return res.status === 204 ? null : res.json();
+}
+
+// 플레이북 목록 불러오기
+async function loadPlaybooks() {
+    try {
+        const list = await playbooksApi('');
+        playbookLibrary.clear();
+        list.forEach(p => playbookLibrary.set(p.name, p));
+    } catch (e) {
+        console.error('플레이북 목록 오류:', e);
+        return;
+    }
+
+    const select = document.getElementById('playbook-select');
+    const current = select.value;
+    select.innerHTML = '<option value="">플레이북 선택</option>';
+    playbookLibrary.forEach(p => {
+        const option = document.createElement('option');
+        option.value = p.name;
+        option.textContent = p.error
+            ? `${p.name} (오류)`
+            : `${p.name} (${p.steps}단계)${p.description ? ' - ' + p.description : ''}`;
+        select.appendChild(option);
+    });
+    select.value = playbookLibrary.has(current) ? current : '';
+    renderPlaybookVars();
+}
+
+// 선택한 플레이북의 변수 입력 표시 (기본값으로 채움)
+function renderPlaybookVars() {
+    const container = document.getElementById('playbook-vars');
+    const playbook = playbookLibrary.get(document.getElementById('playbook-select').value);
+    container.innerHTML = '';
+    if (!playbook) {
+        return;
+    }
+    if (playbook.error) {
+        container.innerHTML = `<span class="result-error-inline">${escapeHtml(playbook.error)}</span>`;
+        return;
+    }
+
+    Object.entries(playbook.vars || {}).sort(([a], [b]) => a.localeCompare(b)).forEach(([name, value]) => {
+        const label = document.createElement('label');
+        label.style.cssText = 'display: flex; align-items: center; gap: 5px;';
+        label.append(name);
+        const input = document.createElement('input');
+        input.type = 'text';
+        input.value = value;
+        input.size = 12;
+        input.dataset.var = name;
+        label.appendChild(input);
+        container.appendChild(label);
+    });
+}
+
+// 선택한 플레이북 실행
+function runPlaybook() {
+    const name = document.getElementById('playbook-select').value;
+    if (!name) {
+        alert('플레이북을 선택하세요.');
+        return;
+    }
+
+    const vars = {};
+    document.querySelectorAll('#playbook-vars [data-var]').forEach(input => {
+        vars[input.dataset.var] = input.value;
+    });
+
+    const msg = {
+        type: 'command',
+        playbook: { name, vars }
+    };
+    if (!applyCommandOptions(msg)) {
+        return;
+    }
+    socket.send(JSON.stringify(msg));
+}
+
+// 플레이북 편집기 열기 (선택한 플레이북의 YAML 또는 새 플레이북 예시)
+async function editPlaybook() {
+    const name = document.getElementById('playbook-select').value;
+    let source = `name: install-app
+description: 설치 후 재부팅하고 확인
+vars:
+  version: "1.0"
+steps:
+  - name: check installed
+    shell: "app --version"
+    continue_on_error: true
+  - name: install
+    when:
+      exit_code_not: 0
+    shell: "install-app {{version}}"
+    timeout: 600
+  - name: config
+    file:
+      path: "/etc/app/room.conf"
+      content: "room={{labels.room}}\n"
+  - name: reboot
+    reboot: true
+  - name: verify
+    shell: "app --version"
+`;
+    if (name) {
+        try {
+            source = (await playbooksApi('/' + encodeURIComponent(name))).source;
+        } catch (e) {
+            alert('플레이북을 불러올 수 없습니다: ' + e.message);
+            return;
+        }
+    }
+    document.getElementById('playbook-editor-text').value = source;
+    document.getElementById('playbook-editor').style.display = 'block';
+}
+
+// 편집한 플레이북 저장 (서버에서 검증)
+async function savePlaybook() {
+    try {
+        const playbook = await playbooksApi('', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/yaml' },
+            body: document.getElementById('playbook-editor-text').value
+        });
+        alert(`${playbook.name} 저장됨 (${playbook.steps.length}단계)`);
+        document.getElementById('playbook-editor').style.display = 'none';
+        await loadPlaybooks();
+        document.getElementById('playbook-select').value = playbook.name;
+        renderPlaybookVars();
+    } catch (e) {
+        alert('플레이북 저장 실패: ' + e.message);
+    }
+}
+
+document.getElementById('playbook-select').addEventListener('change', renderPlaybookVars);
+loadPlaybooks();
+
+// 명령 템플릿 (id -> 템플릿)
+let commandTemplates = new Map();
+
+// 템플릿 API 호출 (대시보드 사용자/토큰으로 인증)
+async function templatesApi(path, options) {
+    const res = await fetch(`/api/templates${path}?user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`, options);
+    if (!res.ok) {
+        throw new Error(await res.text());
+    }
+    return res.status === 204 ? null : res.json();
+}
+
+// 템플릿 목록 불러오기 (즐겨찾기 먼저)
+async function loadTemplates() {
+    try {
+        const list = await templatesApi('');
+        commandTemplates.clear();
+        list.forEach(t => commandTemplates.set(t.id, t));
+    } catch (e) {
+        console.error('템플릿 목록 오류:', e);
+        return;
+    }
+
+    const select = document.getElementById('template-select');
+    const current = select.value;
+    select.innerHTML = '<option value="">템플릿 선택</option>';
+    commandTemplates.forEach(t => {
+        const option = document.createElement('option');
+        option.value = t.id;
+        option.textContent = `${t.favorite ? '★ ' : ''}${t.name}${t.description ? ' - ' + t.description : ''}`;
+        select.appendChild(option);
+    });
+    select.value = commandTemplates.has(current) ? current : '';
+    renderTemplateParams();
+}
+
+// 선택한 템플릿의 자리 표시자 입력과 명령 미리보기 표시
+function renderTemplateParams() {
+    const container = document.getElementById('template-params');
+    const tpl = commandTemplates.get(document.getElementById('template-select').value);
+    container.innerHTML = '';
+    document.getElementById('template-favorite').textContent = tpl && tpl.favorite ? '★' : '☆';
+    if (!tpl) {
+        return;
+    }
+
+    renderParamInputs(container, tpl.params);
+
+    const target = tpl.target.group ? `그룹 ${tpl.target.group}` : (tpl.target.hostnames || []).join(', ');
+    const info = document.createElement('span');
+    info.style.cssText = 'color: #666; font-size: 0.9em;';
+    info.textContent = `${tpl.gui ? '[GUI] ' : ''}${tpl.command}${target ? ' · 기본 대상: ' + target : ''}`;
+    container.appendChild(info);
+}
+
+// 선택한 템플릿 실행
+function runTemplate() {
+    const id = document.getElementById('template-select').value;
+    if (!id) {
+        alert('템플릿을 선택하세요.');
+        return;
+    }
+    const msg = {
+        type: 'command',
+        template: { id, params: paramValues(document.getElementById('template-params')) }
+    };
+    if (!applyCommandOptions(msg)) {
+        return;
+    }
+    socket.send(JSON.stringify(msg));
+}
+
+// 선택한 템플릿 즐겨찾기 추가/해제
+async function toggleTemplateFavorite() {
+    const tpl = commandTemplates.get(document.getElementById('template-select').value);
+    if (!tpl) {
+        return;
+    }
+    try {
+        await templatesApi(`/${encodeURIComponent(tpl.id)}/favorite`, { method: tpl.favorite ? 'DELETE' : 'PUT' });
+        await loadTemplates();
+    } catch (e) {
+        alert('즐겨찾기 변경 실패: ' + e.message);
+    }
+}
+
+// 템플릿 편집기 열기 (선택한 템플릿 또는 입력 중인 명령으로 새 템플릿)
+function editTemplate() {
+    const tpl = commandTemplates.get(document.getElementById('template-select').value);
+    const def = tpl ? {
+        id: tpl.id,
+        name: tpl.name,
+        description: tpl.description || '',
+        command: tpl.command,
+        gui: !!tpl.gui,
+        params: tpl.params,
+        target: tpl.target,
+        roles: tpl.roles || []
+    } : {
+        name: 'ping-host',
+        description: '지정한 호스트로 ping',
+        command: document.getElementById('command').value.trim() || 'ping -n {{count}} {{host}}',
+        gui: document.getElementById('gui-mode').checked,
+        params: [{ name: 'count', type: 'int', default: '4' }],
+        target: {},
+        roles: []
+    };
+    document.getElementById('template-editor-text').value = JSON.stringify(def, null, 2);
+    document.getElementById('template-editor').style.display = 'block';
+}
+
+// 편집한 템플릿 저장
+async function saveTemplate() {
+    let def;
+    try {
+        def = JSON.parse(document.getElementById('template-editor-text').value);
+    } catch (e) {
+        alert('JSON 형식이 올바르지 않습니다: ' + e.message);
+        return;
+    }
+    try {
+        const saved = await templatesApi('', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(def)
+        });
+        document.getElementById('template-editor').style.display = 'none';
+        await loadTemplates();
+        document.getElementById('template-select').value = saved.id;
+        renderTemplateParams();
+    } catch (e) {
+        alert('템플릿 저장 실패: ' + e.message);
+    }
+}
+
+// 선택한 템플릿 삭제
+async function deleteTemplate() {
+    const tpl = commandTemplates.get(document.getElementById('template-select').value);
+    if (!tpl || !confirm(`템플릿 ${tpl.name}을(를) 삭제할까요?`)) {
+        return;
+    }
+    try {
+        await templatesApi('/' + encodeURIComponent(tpl.id), { method: 'DELETE' });
+        await loadTemplates();
+    } catch (e) {
+        alert('템플릿 삭제 실패: ' + e.message);
+    }
+}
+
+document.getElementById('template-select').addEventListener('change', renderTemplateParams);
+loadTemplates();
+
 
 
 // Enter 키로 명령 전송
@@ -227,9 +879,11 @@ commandInput.addEventListener('keypress', (e) => {
     }
 });
 
-// 명령 실행 결과 처리
-function handleCommandResult(msg) {
+// 결과 카드 (command_id:agent_id -> element), 실행 중 출력이 있으면 재사용
+let resultCards = new Map();
 
+// 결과 카드를 맨 위에 추가
+function prependResultItem(resultItem) {
     if (resultsEmpty) {
         resultsEmpty.style.display = 'none';
     }
@@ -237,43 +891,1144 @@ function handleCommandResult(msg) {
         resultsContainer.style.display = 'block';
     }
 
-    const resultItem = document.createElement('div');
+    // 최신 결과를 맨 위에 추가
+    if (resultsContainer.firstChild) {
+        resultsContainer.insertBefore(resultItem, resultsContainer.firstChild);
+    } else {
+        resultsContainer.appendChild(resultItem);
+    }
+
+    // 결과가 너무 많으면 오래된 것 제거 (최대 50개)
+    while (resultsContainer.children.length > 50) {
+        const last = resultsContainer.lastChild;
+        resultCards.forEach((el, key) => {
+            if (el === last) {
+                resultCards.delete(key);
+            }
+        });
+        resultsContainer.removeChild(last);
+    }
+}
+
+// 실행 중 출력 청크 처리
+function handleCommandOutput(msg) {
+    const chunk = msg.output;
+    const key = `${chunk.id}:${msg.agent_id}`;
+    let card = resultCards.get(key);
+    if (!card) {
+        const agentName = agents.get(msg.agent_id)?.info?.hostname || msg.agent_id;
+        const command = runningCommands.get(chunk.id)?.command || '';
+        card = document.createElement('div');
+        card.className = 'result-item';
+        card.innerHTML = `
+            <div class="result-header">
+                <div>
+                    <span class="result-agent">${agentName}</span>
+                    <span class="result-command">${escapeHtml(command)}</span>
+                </div>
+                <div class="result-timestamp">실행 중...</div>
+            </div>
+            <div class="result-output live-output"></div>
+        `;
+        resultCards.set(key, card);
+        prependResultItem(card);
+    }
+
+    const output = card.querySelector('.live-output');
+    if (!output) {
+        return;
+    }
+    const span = document.createElement('span');
+    if (chunk.stream === 'stderr') {
+        span.className = 'live-stderr';
+    }
+    span.textContent = chunk.data;
+    output.appendChild(span);
+    output.scrollTop = output.scrollHeight;
+}
+
+// 명령 실행 결과 처리
+function handleCommandResult(msg) {
+    const key = `${msg.result.id}:${msg.agent_id}`;
+    const existing = resultCards.get(key);
+    const resultItem = existing || document.createElement('div');
     resultItem.className = 'result-item';
 
-    const timestamp = new Date(msg.result.timestamp).toLocaleString('ko-KR');
+    const result = msg.result;
+    markCommandDone(result.id, msg.agent_id);
     const agentName = agents.get(msg.agent_id)?.info?.hostname || msg.agent_id;
+    resultItem.innerHTML = resultHtml(agentName, msg.agent_id, result);
+
+    if (!existing) {
+        prependResultItem(resultItem);
+    }
+    if (result.id) {
+        resultCards.set(key, resultItem);
+    }
+}
+
+// 결과 카드 내용
+function resultHtml(agentName, agentId, result) {
+    const timestamp = new Date(result.timestamp).toLocaleString('ko-KR');
 
     let errorSection = '';
-    if (msg.result.error) {
-        errorSection = `<div class="result-error">오류: ${msg.result.error}</div>`;
+    if (result.error) {
+        errorSection = `<div class="result-error">오류: ${escapeHtml(result.error)}</div>`;
+    }
+    if (result.incomplete) {
+        errorSection += `<div class="result-error">일부 출력 청크가 누락되었습니다.</div>`;
+    }
+    if (result.truncated) {
+        errorSection += `<div class="result-error">출력이 너무 커서 앞부분만 표시합니다.${outputDownloadLinks(agentId, result)}</div>`;
     }
 
-    resultItem.innerHTML = `
+    // 이전 버전 에이전트는 stdout/stderr 대신 output만 보냄
+    const stdout = result.stdout ?? result.output ?? '';
+    let stderrSection = '';
+    if (result.stderr) {
+        stderrSection = `<div class="result-output result-stderr">${escapeHtml(result.stderr)}</div>`;
+    }
+
+    const statusText = {
+        succeeded: '성공',
+        start_failed: '시작 실패',
+        exit_nonzero: '실패',
+        killed: '강제 종료',
+        rejected: '거부됨 (큐 가득 참)'
+    }[result.status] || result.status || '';
+    const killReason = {
+        timeout: ' (시간 초과)',
+        canceled: ' (취소됨)',
+        lost: ' (감독 프로세스 사라짐)'
+    }[result.kill_reason] || '';
+    const duration = result.duration_ms !== undefined ? ` · 소요 ${(result.duration_ms / 1000).toFixed(2)}초` : '';
+
+    return `
         <div class="result-header">
             <div>
-                <span class="result-agent">${agentName}</span>
-                <span class="result-command">${msg.result.command}</span>
+                <span class="result-agent">${escapeHtml(agentName)}</span>
+                <span class="result-command">${escapeHtml(result.command)}</span>
+                ${result.script ? `<span class="result-status">스크립트 ${escapeHtml(result.script.name)} v${result.script.version}</span>` : ''}
             </div>
             <div class="result-timestamp">${timestamp}</div>
         </div>
-        <div class="result-output">${escapeHtml(msg.result.output)}</div>
+        <div class="result-output">${escapeHtml(stdout)}</div>
+        ${stderrSection}
         ${errorSection}
         <div style="margin-top: 10px; color: #666; font-size: 0.9em;">
-            종료 코드: ${msg.result.exit_code}
+            <span class="result-status result-status-${result.status}">${statusText}${killReason}</span>
+            종료 코드: ${result.exit_code}${duration}
         </div>
     `;
+}
 
-    // 최신 결과를 맨 위에 추가
-    if (resultsContainer.firstChild) {
-        resultsContainer.insertBefore(resultItem, resultsContainer.firstChild);
-    } else {
-        resultsContainer.appendChild(resultItem);
+// 잘린 출력 전체 내려받기 링크
+function outputDownloadLinks(agentId, result) {
+    if (!result.output_handle) {
+        return '';
     }
+    const link = (stream, bytes) => {
+        if (!bytes) {
+            return '';
+        }
+        const url = `/api/output?agent_id=${encodeURIComponent(agentId)}&handle=${encodeURIComponent(result.output_handle)}&stream=${stream}` +
+            `&user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`;
+        return ` <a href="${url}" target="_blank">전체 ${stream} (${formatBytes(bytes)})</a>`;
+    };
+    return link('stdout', result.stdout_bytes) + link('stderr', result.stderr_bytes);
+}
 
-    // 결과가 너무 많으면 오래된 것 제거 (최대 50개)
-    while (resultsContainer.children.length > 50) {
-        resultsContainer.removeChild(resultsContainer.lastChild);
+// 바이트 크기 포맷팅
+function formatBytes(bytes) {
+    if (bytes >= 1024 * 1024 * 1024) {
+        return `${(bytes / 1024 / 1024 / 1024).toFixed(1)}GB`;
+    }
+    if (bytes >= 1024 * 1024) {
+        return `${(bytes / 1024 / 1024).toFixed(1)}MB`;
+    }
+    if (bytes >= 1024) {
+        return `${(bytes / 1024).toFixed(1)}KB`;
     }
+    return `${bytes}B`;
+}
+
+// 에이전트 작업 큐 표시
+function handleAgentJobs(msg) {
+    const jobs = msg.jobs || {};
+    const agentName = agents.get(msg.agent_id)?.info?.hostname || msg.agent_id;
+    const priorityText = p => p === 'background' ? '백그라운드' : '대화형';
+    const row = (job, state) => {
+        const since = new Date(job.started_at || job.queued_at).toLocaleTimeString('ko-KR');
+        return `<div class="result-item">
+            <span class="result-status">${state}</span>
+            <span class="result-command">${escapeHtml(job.command)}</span>
+            <span style="color: #666; font-size: 0.9em;"> · ${priorityText(job.priority)} · ${since}</span>
+        </div>`;
+    };
+
+    const running = jobs.running || [];
+    const queued = jobs.queued || [];
+    const detachedJobs = jobs.detached || [];
+    document.getElementById('jobs').innerHTML = `
+        <div style="margin-bottom: 10px;">
+            <strong>${agentName}</strong>
+            — 실행 중 ${running.length}/${jobs.max_concurrent}, 대기 ${queued.length}/${jobs.max_queued}
+        </div>
+        ${running.map(j => row(j, '실행 중')).join('')}
+        ${queued.map(j => row(j, '대기')).join('')}
+        ${detachedJobs.map(j => row(j, '분리 실행')).join('')}
+        ${running.length + queued.length + detachedJobs.length === 0 ? '<div class="empty-state">작업이 없습니다.</div>' : ''}
+    `;
+    document.getElementById('jobs-section').style.display = 'block';
+}
+
+// 에이전트의 네트워크 인터페이스와 최근 송수신량/지연 시간 표시
+async function showAgentNetwork(agentId) {
+    let data;
+    try {
+        const res = await fetch(`/api/agents/${encodeURIComponent(agentId)}/network?user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`);
+        if (!res.ok) {
+            throw new Error(await res.text());
+        }
+        data = await res.json();
+    } catch (e) {
+        alert('네트워크 정보 오류: ' + e.message);
+        return;
+    }
+
+    const rateText = bytes => `${formatBytes(Math.round(bytes))}/s`;
+    const latencies = data.samples.map(s => s.latency_ms).filter(v => v > 0);
+    const latencySummary = latencies.length === 0 ? '' :
+        ` · 지연 평균 ${(latencies.reduce((a, b) => a + b, 0) / latencies.length).toFixed(1)} ms, 최대 ${Math.max(...latencies).toFixed(1)} ms`;
+    const recent = data.samples.slice(-12).reverse();
+    document.getElementById('network').innerHTML = `
+        <div style="margin-bottom: 10px;">
+            <strong>${escapeHtml(data.hostname)}</strong>
+            <span style="color: #666; font-size: 0.9em;">
+                ${new Date(data.updated_at).toLocaleTimeString('ko-KR')} 기준 · 기록 ${data.samples.length}개${latencySummary}
+            </span>
+        </div>
+        ${data.interfaces.filter(i => !i.loopback).map(i => `
+            <div class="result-item">
+                <span class="result-status ${i.up ? 'result-status-succeeded' : 'result-status-offline'}">${i.up ? '연결' : '끊김'}</span>
+                <strong>${escapeHtml(i.name)}</strong>
+                <span style="color: #666; font-size: 0.9em;">
+                    ${escapeHtml(i.mac || '')} ${escapeHtml((i.ips || []).join(', '))}
+                    · 수신 ${rateText(i.rx_bytes_per_sec)} (${i.rx_packets_per_sec.toFixed(0)} pkt/s)
+                    · 송신 ${rateText(i.tx_bytes_per_sec)} (${i.tx_packets_per_sec.toFixed(0)} pkt/s)
+                    · 오류 ${i.rx_errors + i.tx_errors} · 버림 ${i.rx_dropped + i.tx_dropped}
+                </span>
+            </div>
+        `).join('')}
+        <div style="margin-top: 10px; font-size: 0.9em; color: #666;">
+            ${recent.map(s => `${new Date(s.time).toLocaleTimeString('ko-KR')} 수신 ${rateText(s.rx_bytes_per_sec)} 송신 ${rateText(s.tx_bytes_per_sec)}${s.latency_ms ? ` 지연 ${s.latency_ms.toFixed(1)} ms` : ''}`).join('<br>')}
+        </div>
+    `;
+    document.getElementById('network-section').style.display = 'block';
+}
+
+// 에이전트나 그룹의 CPU/메모리/디스크 기록을 선 그래프로 표시
+async function loadMetrics() {
+    const kind = document.getElementById('metrics-kind').value;
+    const target = document.getElementById('metrics-target').value.trim();
+    const container = document.getElementById('metrics');
+    if (!target && kind === 'agent') {
+        alert('호스트 이름을 입력하세요.');
+        return;
+    }
+    const hours = Number(document.getElementById('metrics-range').value);
+    const params = new URLSearchParams({
+        from: new Date(Date.now() - hours * 3600 * 1000).toISOString(),
+        user: loginUser,
+        token: loginToken
+    });
+    const path = kind === 'agent' ? `agents/${encodeURIComponent(target)}` : `groups/${encodeURIComponent(target || '-')}`;
+
+    let data;
+    try {
+        const res = await fetch(`/api/${path}/metrics?${params}`);
+        if (!res.ok) {
+            throw new Error(await res.text());
+        }
+        data = await res.json();
+    } catch (e) {
+        container.innerHTML = `<div class="empty-state">상태 기록 오류: ${escapeHtml(e.message)}</div>`;
+        return;
+    }
+
+    const resolutionText = { raw: '보고마다', minute: '1분 평균', hour: '1시간 평균' }[data.resolution];
+    const title = kind === 'agent'
+        ? `${escapeHtml(data.agent.hostname || data.agent.key)}`
+        : `그룹 ${escapeHtml(data.group || '(없음)')} · 에이전트 ${data.agents.length}대`;
+    const chart = (label, key, maxKey) => {
+        const values = data.points.map(p => p[key]);
+        if (values.length === 0) {
+            return '';
+        }
+        const w = 600, h = 60;
+        const x = i => values.length === 1 ? w : (i / (values.length - 1)) * w;
+        const y = v => h - (Math.min(Math.max(v, 0), 100) / 100) * h;
+        const line = values.map((v, i) => `${x(i).toFixed(1)},${y(v).toFixed(1)}`).join(' ');
+        const peaks = maxKey ? data.points.map((p, i) => `${x(i).toFixed(1)},${y(p[maxKey]).toFixed(1)}`).join(' ') : '';
+        const avg = values.reduce((a, b) => a + b, 0) / values.length;
+        const peak = Math.max(...(maxKey ? data.points.map(p => p[maxKey]) : values));
+        return `
+            <div style="margin-bottom: 8px;">
+                <div style="font-size: 0.9em;">${label} · 평균 ${avg.toFixed(1)}% · 최대 ${peak.toFixed(1)}%</div>
+                <svg viewBox="0 0 ${w} ${h}" preserveAspectRatio="none" style="width: 100%; height: ${h}px; background: #f8f9fa;">
+                    ${peaks ? `<polyline points="${peaks}" fill="none" stroke="#f5a5a5" stroke-width="1"/>` : ''}
+                    <polyline points="${line}" fill="none" stroke="#667eea" stroke-width="1.5"/>
+                </svg>
+            </div>
+        `;
+    };
+    const first = data.points[0], last = data.points[data.points.length - 1];
+    container.innerHTML = `
+        <div style="margin-bottom: 10px;">
+            <strong>${title}</strong>
+            <span style="color: #666; font-size: 0.9em;">
+                ${resolutionText} · ${data.points.length}개${first ? ` · ${new Date(first.time).toLocaleString('ko-KR')} ~ ${new Date(last.time).toLocaleString('ko-KR')}` : ''}
+                ${kind === 'group' ? ' · 연한 선은 그룹 내 최대값' : ''}
+            </span>
+        </div>
+        ${data.points.length === 0 ? '<div class="empty-state">이 기간의 기록이 없습니다.</div>' : ''}
+        ${chart('CPU', 'cpu', kind === 'group' ? 'cpu_max' : '')}
+        ${chart('메모리', 'memory', kind === 'group' ? 'memory_max' : '')}
+        ${chart('디스크', 'disk', kind === 'group' ? 'disk_max' : '')}
+    `;
+}
+
+// 프로세스 목록을 보고 있는 에이전트
+let processesAgentId = null;
+
+async function processesApi(path, query, options) {
+    const params = new URLSearchParams(query);
+    params.set('user', loginUser);
+    params.set('token', loginToken);
+    const res = await fetch(`/api/agents/${encodeURIComponent(processesAgentId)}/processes${path}?${params}`, options);
+    if (!res.ok) {
+        throw new Error(await res.text());
+    }
+    return res.json();
+}
+
+// 에이전트의 프로세스 목록 (필터와 정렬은 서버에서)
+async function loadProcesses() {
+    if (!processesAgentId) {
+        return;
+    }
+    const query = { sort: document.getElementById('processes-sort').value };
+    const q = document.getElementById('processes-q').value.trim();
+    const owner = document.getElementById('processes-owner').value.trim();
+    if (q) query.q = q;
+    if (owner) query.owner = owner;
+    if (query.sort === 'pid' || query.sort === 'name' || query.sort === 'user') query.order = 'asc';
+
+    const container = document.getElementById('processes');
+    document.getElementById('processes-section').style.display = 'block';
+    container.innerHTML = '<div class="empty-state">불러오는 중...</div>';
+    let data;
+    try {
+        data = await processesApi('', query);
+    } catch (e) {
+        container.innerHTML = `<div class="empty-state">프로세스 목록 오류: ${escapeHtml(e.message)}</div>`;
+        return;
+    }
+
+    container.innerHTML = `
+        <div style="margin-bottom: 6px; color: #666; font-size: 0.9em;">
+            ${new Date(data.collected_at).toLocaleTimeString('ko-KR')} 기준 · ${data.total}개 중 ${data.processes.length}개
+        </div>
+        ${data.processes.map(p => `
+            <div class="result-item" data-pid="${p.pid}">
+                <strong>${escapeHtml(p.name)}</strong>
+                <span style="color: #666; font-size: 0.9em;">
+                    PID ${p.pid} · 부모 ${p.ppid} · ${escapeHtml(p.user || '-')}
+                    · CPU ${p.cpu.toFixed(1)}% · 메모리 ${formatBytes(p.memory)} (${p.memory_percent.toFixed(1)}%)
+                </span>
+                <button class="process-kill" data-signal="term">종료</button>
+                <button class="process-kill" data-signal="kill">강제 종료</button>
+                ${p.cmdline ? `<div style="color: #888; font-size: 0.85em; word-break: break-all;">${escapeHtml(p.cmdline)}</div>` : ''}
+            </div>
+        `).join('')}
+        ${data.processes.length === 0 ? '<div class="empty-state">프로세스가 없습니다.</div>' : ''}
+    `;
+    container.querySelectorAll('.process-kill').forEach(btn => {
+        btn.addEventListener('click', () => {
+            killProcess(Number(btn.closest('.result-item').dataset.pid), btn.dataset.signal);
+        });
+    });
+}
+
+// PID로 프로세스 종료 (term: 정상 종료 요청, kill: 강제 종료)
+async function killProcess(pid, signal) {
+    if (!confirm(`PID ${pid} 프로세스를 ${signal === 'kill' ? '강제 종료' : '종료'}할까요?`)) {
+        return;
+    }
+    try {
+        const result = await processesApi('/kill', {}, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ pid, signal })
+        });
+        if (result.errors && result.errors.length > 0) {
+            alert('종료 실패: ' + result.errors.join('\n'));
+        }
+    } catch (e) {
+        alert('종료 오류: ' + e.message);
+        return;
+    }
+    loadProcesses();
+}
+
+// 작업 기록 (job_id -> 목록 항목), 열어 둔 작업 상세
+let jobHistory = new Map();
+let openJobId = null;
+let jobRefreshTimer = null;
+
+const jobStateText = {
+    pending: '대기',
+    sent: '전송됨',
+    running: '실행 중',
+    succeeded: '성공',
+    failed: '실패',
+    timed_out: '시간 초과',
+    offline: '오프라인',
+    skipped: '건너뜀',
+    waiting: '연결 대기',
+    retrying: '재시도 대기',
+    held: '정비 시간 대기'
+};
+
+const rolloutStateText = {
+    running: '배포 중',
+    waiting: '다음 배치 대기',
+    halted: '실패로 멈춤',
+    aborted: '중단됨',
+    completed: '배포 완료'
+};
+
+// 작업 API 호출 (대시보드 사용자/토큰으로 인증)
+async function jobsApi(path) {
+    const res = await fetch(`/api/jobs${path}${path.includes('?') ? '&' : '?'}user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`);
+    if (!res.ok) {
+        throw new Error(await res.text());
+    }
+    return res.json();
+}
+
+// 최근 작업 목록 불러오기
+async function loadJobs() {
+    try {
+        const list = await jobsApi('?limit=30');
+        jobHistory.clear();
+        list.forEach(job => jobHistory.set(job.id, job));
+    } catch (e) {
+        console.error('작업 목록 오류:', e);
+        return;
+    }
+    updateJobHistoryDisplay();
+}
+
+// 작업 요약 (예: 성공 33 · 실패 7 / 40대)
+function jobSummaryText(summary) {
+    const parts = [];
+    ['succeeded', 'failed', 'timed_out', 'offline', 'skipped', 'running', 'sent', 'pending', 'waiting', 'retrying', 'held'].forEach(state => {
+        if (summary[state] > 0) {
+            parts.push(`${jobStateText[state]} ${summary[state]}`);
+        }
+    });
+    return `${parts.join(' · ')} / ${summary.total}대`;
+}
+
+// 작업 상태 변경 처리
+function handleJobUpdate(job) {
+    jobHistory.set(job.id, job);
+    if (job.summary.done && runningCommands.delete(job.id)) {
+        // 결과가 오지 않는 대상(오프라인, 플레이북 단계 등)도 작업이 끝나면 실행 중 목록에서 뺀다
+        updateRunningDisplay();
+    }
+    updateJobHistoryDisplay();
+    if (openJobId === job.id && !jobRefreshTimer) {
+        // 결과가 몰려 올 때 상세를 너무 자주 다시 읽지 않도록 모아서 갱신
+        jobRefreshTimer = setTimeout(() => {
+            jobRefreshTimer = null;
+            if (openJobId) {
+                openJob(openJobId);
+            }
+        }, 1000);
+    }
+}
+
+// 작업 기록 표시
+function updateJobHistoryDisplay() {
+    const list = Array.from(jobHistory.values())
+        .sort((a, b) => new Date(b.created_at) - new Date(a.created_at))
+        .slice(0, 30);
+    const container = document.getElementById('job-history');
+    container.innerHTML = list.length === 0 ? '<div class="empty-state">작업 기록이 없습니다.</div>' : '';
+
+    list.forEach(job => {
+        const item = document.createElement('div');
+        item.className = 'running-item';
+        const failed = job.summary.failed + job.summary.timed_out + job.summary.offline;
+        item.innerHTML = `
+            <div>
+                <span class="result-status ${job.summary.done ? (failed > 0 ? 'result-status-failed' : 'result-status-succeeded') : ''}">
+                    ${job.summary.done ? '완료' : '진행 중'}
+                </span>
+                <span class="result-command">${escapeHtml(job.command)}</span>
+                <span style="color: #666; font-size: 0.9em;">
+                    ${jobSummaryText(job.summary)} · ${escapeHtml(job.requested_by)} · ${new Date(job.created_at).toLocaleString('ko-KR')}
+                </span>
+                ${rolloutText(job.rollout)}
+            </div>
+            <div class="approval-actions">
+                ${job.rollout?.state === 'halted' ? `<button class="btn-approve" onclick="controlRollout('${job.id}', 'resume')">재개</button>` : ''}
+                ${['running', 'waiting', 'halted'].includes(job.rollout?.state) ? `<button class="btn-reject" onclick="controlRollout('${job.id}', 'abort')">중단</button>` : ''}
+                <button onclick="openJob('${job.id}')">결과 보기</button>
+            </div>
+        `;
+        container.appendChild(item);
+    });
+}
+
+// 순차 배포 진행 상태
+function rolloutText(rollout) {
+    if (!rollout) {
+        return '';
+    }
+    let text = `${rolloutStateText[rollout.state] || rollout.state} · 배치 ${rollout.batch}/${rollout.batches}`;
+    if (rollout.next_at) {
+        text += ` · 다음 ${new Date(rollout.next_at).toLocaleTimeString('ko-KR')}`;
+    }
+    if (rollout.reason) {
+        text += ` · ${rollout.reason}`;
+    }
+    return `<div class="${rollout.state === 'halted' ? 'result-error-inline' : ''}" style="font-size: 0.9em;">${escapeHtml(text)}</div>`;
+}
+
+// 멈춘 순차 배포 재개 또는 중단
+async function controlRollout(id, action) {
+    if (action === 'abort' && !confirm('남은 배치를 보내지 않고 중단할까요?')) {
+        return;
+    }
+    try {
+        const res = await fetch(`/api/jobs/${encodeURIComponent(id)}/${action}?user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`, { method: 'POST' });
+        if (!res.ok) {
+            throw new Error(await res.text());
+        }
+    } catch (e) {
+        alert(e.message);
+    }
+}
+
+// 재시도/오프라인 대기 정보 (예: 시도 2회 · 다음 시도 10:30:00)
+function deliveryText(t) {
+    const parts = [];
+    if (t.attempts > 1) {
+        parts.push(`시도 ${t.attempts}회`);
+    }
+    if (t.next_attempt_at && t.state === 'held') {
+        parts.push(`정비 시간 ${new Date(t.next_attempt_at).toLocaleString('ko-KR')}에 전송`);
+    } else if (t.next_attempt_at) {
+        parts.push(`다음 시도 ${new Date(t.next_attempt_at).toLocaleTimeString('ko-KR')}`);
+    }
+    if (t.hold_until) {
+        parts.push(`${new Date(t.hold_until).toLocaleString('ko-KR')}까지 대기`);
+    }
+    return parts.length ? `<span style="font-size: 0.9em;">${escapeHtml(parts.join(' · '))}</span>` : '';
+}
+
+const historyPageSize = 50;
+
+// 히스토리 검색 조건 쿼리 문자열
+function historyQuery() {
+    const params = new URLSearchParams({ user: loginUser, token: loginToken });
+    [['q', 'history-q'], ['agent', 'history-agent'], ['group', 'history-group'], ['requested_by', 'history-user'],
+        ['status', 'history-status'], ['exit_code', 'history-exit'], ['from', 'history-from'], ['to', 'history-to']]
+        .forEach(([key, id]) => {
+            const value = document.getElementById(id).value.trim();
+            if (value) {
+                params.set(key, value);
+            }
+        });
+    return params;
+}
+
+// 명령 히스토리 검색 (offset부터 한 페이지)
+async function searchHistory(offset) {
+    const params = historyQuery();
+    params.set('offset', offset);
+    params.set('limit', historyPageSize);
+    let page;
+    try {
+        const res = await fetch('/api/history?' + params);
+        if (!res.ok) {
+            throw new Error(await res.text());
+        }
+        page = await res.json();
+    } catch (e) {
+        alert('히스토리 검색 실패: ' + e.message);
+        return;
+    }
+
+    const container = document.getElementById('history-results');
+    container.innerHTML = page.entries.length === 0 ? '<div class="empty-state">조건에 맞는 기록이 없습니다.</div>' : '';
+    page.entries.forEach(e => {
+        const row = document.createElement('div');
+        row.className = 'running-item';
+        row.style.flexDirection = 'column';
+        row.style.alignItems = 'stretch';
+        row.innerHTML = `
+            <div>
+                <span class="result-status result-status-${e.state}">${jobStateText[e.state] || e.state}</span>
+                <strong>${escapeHtml(e.hostname || e.agent_id || '')}</strong>
+                <span class="result-command">${escapeHtml(e.command)}</span>
+                <span style="color: #666; font-size: 0.9em;">
+                    ${e.exit_code !== undefined ? `종료 코드 ${e.exit_code} · ` : ''}${e.group ? `${escapeHtml(e.group)} · ` : ''}${escapeHtml(e.requested_by)} · ${new Date(e.created_at).toLocaleString('ko-KR')}
+                </span>
+                <button onclick="openJob('${e.job_id}')">작업 보기</button>
+            </div>
+            ${e.error ? `<div class="result-error-inline">${escapeHtml(e.error)}</div>` : ''}
+            ${e.output ? `<div class="result-output">${escapeHtml(e.output)}${e.output_truncated ? '\n…' : ''}</div>` : ''}
+        `;
+        container.appendChild(row);
+    });
+
+    const pager = document.getElementById('history-pager');
+    const end = offset + page.entries.length;
+    pager.innerHTML = page.total === 0 ? '' : `${offset + 1}-${end} / ${page.total}건 `;
+    if (offset > 0) {
+        const prev = document.createElement('button');
+        prev.textContent = '이전';
+        prev.onclick = () => searchHistory(Math.max(0, offset - historyPageSize));
+        pager.appendChild(prev);
+    }
+    if (end < page.total) {
+        const next = document.createElement('button');
+        next.textContent = '다음';
+        next.onclick = () => searchHistory(end);
+        pager.appendChild(next);
+    }
+}
+
+// 검색 조건에 맞는 히스토리 전체를 파일로 내려받기
+function exportHistory(format) {
+    const params = historyQuery();
+    params.set('format', format);
+    window.location.href = '/api/history/export?' + params;
+}
+
+document.getElementById('history-q').addEventListener('keypress', e => {
+    if (e.key === 'Enter') {
+        searchHistory(0);
+    }
+});
+
+// 작업의 대상별 상태와 결과 표시
+async function openJob(id) {
+    let job;
+    try {
+        job = await jobsApi('/' + encodeURIComponent(id));
+    } catch (e) {
+        alert('작업을 불러올 수 없습니다: ' + e.message);
+        return;
+    }
+    openJobId = id;
+
+    const targets = (job.targets || []).map(t => {
+        const name = t.hostname || t.agent_id;
+        if (t.steps) {
+            return `<div class="result-item">
+                <span class="result-agent">${escapeHtml(name)}</span>
+                <span class="result-status result-status-${t.state}">${jobStateText[t.state] || t.state}</span>
+                ${t.error ? `<span class="result-error-inline">${escapeHtml(t.error)}</span>` : ''}
+                ${playbookStepsHtml(t.steps)}
+            </div>`;
+        }
+        if (t.result && t.state !== 'retrying') {
+            return `<div class="result-item">${resultHtml(name, t.agent_id, t.result)}</div>`;
+        }
+        return `<div class="result-item">
+            <span class="result-agent">${escapeHtml(name)}</span>
+            <span class="result-status result-status-${t.state}">${jobStateText[t.state] || t.state}</span>
+            ${deliveryText(t)}
+            ${t.error ? `<span class="result-error-inline">${escapeHtml(t.error)}</span>` : ''}
+        </div>`;
+    }).join('');
+
+    document.getElementById('job-detail').innerHTML = `
+        <div style="margin: 10px 0;">
+            <strong>${escapeHtml(job.command)}</strong>
+            — ${jobSummaryText(job.summary)}
+            <button onclick="closeJob()">닫기</button>
+            ${job.targets && job.targets.length > 1 ? `<button onclick="showJobBuckets('${job.id}')">같은 결과끼리 묶기</button>` : ''}
+            ${rolloutText(job.rollout)}
+        </div>
+        <div id="job-targets">${targets}</div>
+    `;
+    document.getElementById('job-detail').style.display = 'block';
+}
+
+// 정규화한 출력/종료 코드가 같은 대상끼리 묶어 표시 (예: 35대 성공, 5대 file not found)
+async function showJobBuckets(id) {
+    let data;
+    try {
+        data = await jobsApi(`/${encodeURIComponent(id)}/buckets`);
+    } catch (e) {
+        alert('결과를 묶을 수 없습니다: ' + e.message);
+        return;
+    }
+    const buckets = data.buckets.map((b, i) => {
+        const members = b.members.map(m => {
+            const name = m.hostname || m.agent_id;
+            if (i === 0) {
+                return escapeHtml(name);
+            }
+            return `<a href="#" onclick="showJobDiff('${id}', '${escapeHtml(m.agent_id || m.hostname)}'); return false;" title="가장 많은 묶음과 출력 비교">${escapeHtml(name)}</a>`;
+        }).join(', ');
+        const exit = b.exit_code !== undefined ? ` · 종료 코드 ${b.exit_code}` : '';
+        return `<div class="result-item">
+            <strong>${b.count}대</strong>
+            <span class="result-status result-status-${b.state}">${jobStateText[b.state] || b.state}</span>${exit}
+            ${b.error ? `<span class="result-error-inline">${escapeHtml(b.error)}</span>` : ''}
+            ${b.output ? `<div class="result-output">${escapeHtml(b.output)}${b.truncated ? '\n…' : ''}</div>` : ''}
+            <div style="font-size: 0.9em; margin-top: 4px;">${members}</div>
+        </div>`;
+    }).join('');
+    document.getElementById('job-targets').innerHTML = `
+        <div style="margin: 6px 0; color: #666; font-size: 0.9em;">
+            ${data.buckets.length}개 묶음 / ${data.total}대 · 다른 묶음의 PC 이름을 누르면 가장 많은 묶음과 출력을 비교합니다.
+            <button onclick="openJob('${id}')">대상별 보기</button>
+        </div>
+        <div id="job-diff"></div>
+        ${buckets}
+    `;
+}
+
+// 대상의 출력을 가장 많은 묶음과 줄 단위로 비교
+async function showJobDiff(id, agent) {
+    let diff;
+    try {
+        diff = await jobsApi(`/${encodeURIComponent(id)}/diff?agent=${encodeURIComponent(agent)}`);
+    } catch (e) {
+        alert('출력을 비교할 수 없습니다: ' + e.message);
+        return;
+    }
+    const lines = diff.lines.map(l => {
+        const cls = l.op === '+' ? 'diff-add' : (l.op === '-' ? 'diff-del' : '');
+        return `<span class="${cls}">${escapeHtml(l.op + ' ' + l.text)}</span>`;
+    }).join('\n');
+    document.getElementById('job-diff').innerHTML = `
+        <div class="result-item">
+            <strong>${escapeHtml(diff.hostname || diff.agent_id)}</strong> 출력 비교 (- 가장 많은 묶음, + 이 PC)
+            ${diff.truncated ? '<span class="result-error-inline">출력이 길어 일부만 비교했습니다.</span>' : ''}
+            <button onclick="document.getElementById('job-diff').innerHTML = ''">닫기</button>
+            <div class="result-output">${diff.same ? '가장 많은 묶음과 같습니다.' : lines}</div>
+        </div>
+    `;
+}
+
+// 플레이북 단계별 상태
+function playbookStepsHtml(steps) {
+    const items = steps.map(step => {
+        const output = step.result ? (step.result.stdout || '') + (step.result.stderr || '') : '';
+        return `<li>
+            <span class="result-status result-status-${step.state}">${jobStateText[step.state] || step.state}</span>
+            ${escapeHtml(step.name)} <span style="color: #666;">(${escapeHtml(step.type)}${step.exit_code !== undefined ? ', 종료 코드 ' + step.exit_code : ''})</span>
+            ${step.error ? `<span class="result-error-inline">${escapeHtml(step.error)}</span>` : ''}
+            ${output ? `<div class="result-output">${escapeHtml(output)}</div>` : ''}
+        </li>`;
+    }).join('');
+    return `<ol class="playbook-steps">${items}</ol>`;
+}
+
+function closeJob() {
+    openJobId = null;
+    document.getElementById('job-detail').style.display = 'none';
+}
+
+loadJobs();
+
+// 예약 API 호출 (대시보드 사용자/토큰으로 인증)
+async function schedulesApi(path, options) {
+    const res = await fetch(`/api/schedules${path}${path.includes('?') ? '&' : '?'}user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`, options);
+    if (!res.ok) {
+        throw new Error(await res.text());
+    }
+    return res.status === 204 ? null : res.json();
+}
+
+// 예약 목록 불러오기
+async function loadSchedules() {
+    let list;
+    try {
+        list = await schedulesApi('');
+    } catch (e) {
+        console.error('예약 목록 오류:', e);
+        return;
+    }
+
+    const container = document.getElementById('schedules');
+    container.innerHTML = list.length === 0 ? '<div class="empty-state">예약이 없습니다.</div>' : '';
+    list.forEach(item => {
+        const next = (item.next_runs || [])[0];
+        const target = item.target.hostnames?.length ? item.target.hostnames.join(', ') :
+            (item.target.group ? `그룹 ${item.target.group}` : '전체');
+        const row = document.createElement('div');
+        row.className = 'running-item';
+        row.innerHTML = `
+            <div>
+                <span class="result-status ${item.enabled ? 'result-status-succeeded' : ''}">${item.enabled ? '사용' : '중지'}</span>
+                <strong>${escapeHtml(item.name)}</strong>
+                <code>${escapeHtml(item.cron)}</code>
+                <span class="result-command">${escapeHtml(item.request.command)}</span>
+                <span style="color: #666; font-size: 0.9em;">
+                    → ${escapeHtml(target)} · ${escapeHtml(item.timezone || '서버 시간')}
+                    ${next ? ` · 다음 ${new Date(next).toLocaleString('ko-KR')}` : ''}
+                    ${item.last_run ? ` · 마지막 ${new Date(item.last_run).toLocaleString('ko-KR')}` : ''}
+                </span>
+                ${item.last_error ? `<span class="result-error-inline">${escapeHtml(item.last_error)}</span>` : ''}
+            </div>
+            <div class="approval-actions">
+                ${item.last_job_id ? `<button onclick="openJob('${item.last_job_id}')">결과 보기</button>` : ''}
+                <button onclick="toggleSchedule('${item.id}', ${!item.enabled})">${item.enabled ? '중지' : '사용'}</button>
+                <button class="btn-reject" onclick="deleteSchedule('${item.id}')">삭제</button>
+            </div>
+        `;
+        container.appendChild(row);
+    });
+}
+
+// 입력한 cron 식의 다음 실행 시각 미리보기
+async function previewSchedule() {
+    const cron = document.getElementById('schedule-cron').value.trim();
+    const tz = document.getElementById('schedule-timezone').value.trim();
+    const preview = document.getElementById('schedule-preview');
+    try {
+        const res = await schedulesApi(`/preview?cron=${encodeURIComponent(cron)}&timezone=${encodeURIComponent(tz)}`);
+        preview.textContent = '다음 실행: ' + res.next_runs.map(t => new Date(t).toLocaleString('ko-KR')).join(', ');
+    } catch (e) {
+        preview.textContent = e.message;
+    }
+}
+
+// 새 예약 저장
+async function saveSchedule() {
+    const hostnames = document.getElementById('schedule-hosts').value.split(',').map(h => h.trim()).filter(h => h);
+    const body = {
+        name: document.getElementById('schedule-name').value.trim(),
+        cron: document.getElementById('schedule-cron').value.trim(),
+        timezone: document.getElementById('schedule-timezone').value.trim(),
+        missed: document.getElementById('schedule-missed').value,
+        enabled: true,
+        target: {
+            group: document.getElementById('schedule-group').value.trim(),
+            hostnames: hostnames
+        },
+        request: {
+            command: document.getElementById('schedule-command').value.trim()
+        }
+    };
+    const timeout = parseInt(document.getElementById('command-timeout').value, 10);
+    if (timeout > 0) {
+        body.request.timeout = timeout;
+    }
+    try {
+        await schedulesApi('', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body)
+        });
+        await loadSchedules();
+    } catch (e) {
+        alert('예약 저장 실패: ' + e.message);
+    }
+}
+
+async function toggleSchedule(id, enable) {
+    try {
+        await schedulesApi(`/${id}/${enable ? 'enable' : 'disable'}`, { method: 'POST' });
+        await loadSchedules();
+    } catch (e) {
+        alert(e.message);
+    }
+}
+
+async function deleteSchedule(id) {
+    if (!confirm('예약을 삭제할까요?')) {
+        return;
+    }
+    try {
+        await schedulesApi(`/${id}`, { method: 'DELETE' });
+        await loadSchedules();
+    } catch (e) {
+        alert(e.message);
+    }
+}
+
+document.getElementById('schedule-timezone').value = Intl.DateTimeFormat().resolvedOptions().timeZone || '';
+loadSchedules();
+// 마지막 실행/다음 실행 시각 갱신
+setInterval(loadSchedules, 60000);
+
+const maintenanceWindows = new Map();
+const weekdayText = { sun: '일', mon: '월', tue: '화', wed: '수', thu: '목', fri: '금', sat: '토' };
+
+// 정비 시간 API 호출 (대시보드 사용자/토큰으로 인증)
+async function maintenanceApi(path, options) {
+    const res = await fetch(`/api/maintenance${path}?user=${encodeURIComponent(loginUser)}&token=${encodeURIComponent(loginToken)}`, options);
+    if (!res.ok) {
+        throw new Error(await res.text());
+    }
+    return res.status === 204 ? null : res.json();
+}
+
+// 그룹별 정비 시간 불러오기
+async function loadMaintenance() {
+    let list;
+    try {
+        list = await maintenanceApi('');
+    } catch (e) {
+        console.error('정비 시간 목록 오류:', e);
+        return;
+    }
+
+    maintenanceWindows.clear();
+    const container = document.getElementById('maintenance-windows');
+    container.innerHTML = list.length === 0 ? '<div class="empty-state">정비 시간이 없습니다. 모든 그룹에서 언제든 실행합니다.</div>' : '';
+    list.forEach(item => {
+        maintenanceWindows.set(item.group, item);
+        const weekly = item.weekly.length === 0 ? '항상' : item.weekly.map(r =>
+            `${r.days.map(d => weekdayText[d.toLowerCase()] || d).join('')} ${r.start}-${r.end}`).join(', ');
+        const blackouts = (item.blackouts || []).map(b => b.date).join(', ');
+        const row = document.createElement('div');
+        row.className = 'running-item';
+        row.innerHTML = `
+            <div>
+                <span class="result-status ${item.open ? 'result-status-succeeded' : 'result-status-held'}">${item.open ? '정비 중' : '닫힘'}</span>
+                <strong>${escapeHtml(item.group)}</strong>
+                <span style="color: #666; font-size: 0.9em;">
+                    ${escapeHtml(weekly)} · ${escapeHtml(item.timezone || '서버 시간')}
+                    ${blackouts ? ` · 금지일 ${escapeHtml(blackouts)}` : ''}
+                    ${item.next_open ? ` · 다음 ${new Date(item.next_open).toLocaleString('ko-KR')}` : ''}
+                </span>
+            </div>
+            <div class="approval-actions">
+                <button>편집</button>
+                <button class="btn-reject">삭제</button>
+            </div>
+        `;
+        const [editButton, deleteButton] = row.querySelectorAll('button');
+        editButton.onclick = () => editMaintenance(item.group);
+        deleteButton.onclick = () => deleteMaintenance(item.group);
+        container.appendChild(row);
+    });
+}
+
+// 정비 시간 편집기 열기 (그룹이 없으면 새로 만들기)
+function editMaintenance(group) {
+    const item = maintenanceWindows.get(group);
+    const def = item ? {
+        group: item.group,
+        timezone: item.timezone || '',
+        weekly: item.weekly,
+        blackouts: item.blackouts || []
+    } : {
+        group: document.getElementById('target-group').value.trim() || 'lab1',
+        timezone: Intl.DateTimeFormat().resolvedOptions().timeZone || '',
+        weekly: [{ days: ['mon', 'tue', 'wed', 'thu', 'fri'], start: '18:00', end: '22:00' }],
+        blackouts: [{ date: new Date().toISOString().slice(0, 10), reason: '시험' }]
+    };
+    document.getElementById('maintenance-editor-text').value = JSON.stringify(def, null, 2);
+    document.getElementById('maintenance-editor').style.display = 'block';
+}
+
+// 편집한 정비 시간 저장
+async function saveMaintenance() {
+    let def;
+    try {
+        def = JSON.parse(document.getElementById('maintenance-editor-text').value);
+    } catch (e) {
+        alert('JSON 형식이 올바르지 않습니다: ' + e.message);
+        return;
+    }
+    try {
+        await maintenanceApi('', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(def)
+        });
+        document.getElementById('maintenance-editor').style.display = 'none';
+        await loadMaintenance();
+    } catch (e) {
+        alert('정비 시간 저장 실패: ' + e.message);
+    }
+}
+
+async function deleteMaintenance(group) {
+    if (!confirm(`${group} 그룹의 정비 시간을 삭제할까요? 이후 언제든 실행합니다.`)) {
+        return;
+    }
+    try {
+        await maintenanceApi('/' + encodeURIComponent(group), { method: 'DELETE' });
+        await loadMaintenance();
+    } catch (e) {
+        alert(e.message);
+    }
+}
+
+loadMaintenance();
+setInterval(loadMaintenance, 60000);
+
+// 에이전트 업데이트 결과 처리
+function handleUpdateStatus(msg) {
+    const agentName = agents.get(msg.agent_id)?.info?.hostname || msg.agent_id;
+    const update = msg.update || {};
+    if (update.status === 'failed') {
+        console.warn(`업데이트 실패 [${agentName}] ${update.version} (${update.stage}): ${update.error}`);
+        alert(`${agentName} 업데이트 실패 (${update.stage}): ${update.error}`);
+    } else {
+        console.log(`업데이트 완료 [${agentName}] ${update.version}`);
+    }
+}
+
+// 로그인 정보 저장 후 다시 연결
+function login() {
+    localStorage.setItem('gopc_user', document.getElementById('login-user').value.trim());
+    localStorage.setItem('gopc_token', document.getElementById('login-token').value);
+    location.reload();
+}
+
+// 접속 사용자 표시
+function handleSession(user) {
+    currentUser = user;
+    const approver = user.can_approve ? ', 승인 권한' : '';
+    const templatesOnly = user.templates_only ? ', 템플릿만' : '';
+    document.getElementById('session-user').textContent = `${user.name} (${user.role}${approver}${templatesOnly})`;
+    if (user.templates_only) {
+        // 자유 입력 명령은 서버에서도 거부한다
+        const input = document.getElementById('command');
+        input.disabled = true;
+        input.placeholder = '명령 템플릿으로만 실행할 수 있습니다';
+    }
+}
+
+// 승인 요청 상태 변경 처리
+function handleApprovalUpdate(approval) {
+    if (approval.status === 'pending') {
+        approvals.set(approval.id, approval);
+    } else {
+        approvals.delete(approval.id);
+        const statusText = {
+            approved: '승인됨',
+            rejected: '거절됨',
+            expired: '만료됨'
+        }[approval.status] || approval.status;
+        const by = approval.decided_by ? ` (${approval.decided_by})` : '';
+        console.log(`승인 요청 ${statusText}${by}: ${approval.request.command}`);
+    }
+    updateApprovalsDisplay();
+}
+
+// 승인 대기 목록 표시
+function updateApprovalsDisplay() {
+    const section = document.getElementById('approvals-section');
+    const container = document.getElementById('approvals');
+    section.style.display = approvals.size > 0 ? 'block' : 'none';
+    container.innerHTML = '';
+
+    approvals.forEach(approval => {
+        const item = document.createElement('div');
+        item.className = 'approval-item';
+        const expires = new Date(approval.expires_at).toLocaleTimeString('ko-KR');
+        const mine = currentUser && currentUser.name === approval.requested_by;
+        const canApprove = currentUser && currentUser.can_approve && !mine;
+
+        item.innerHTML = `
+            <div>
+                <span class="result-command">${escapeHtml(approval.request.command)}</span>
+                → ${approval.targets.length}대 · 요청자 <b>${escapeHtml(approval.requested_by)}</b> · 만료 ${expires}
+            </div>
+            <div class="approval-reasons">${approval.reasons.map(escapeHtml).join('<br>')}</div>
+            <div class="approval-actions">
+                ${canApprove ? `<button class="btn-approve" onclick="decideApproval('${approval.id}', true)">승인</button>` : ''}
+                ${canApprove || mine ? `<button class="btn-reject" onclick="decideApproval('${approval.id}', false)">${mine ? '취소' : '거절'}</button>` : ''}
+            </div>
+        `;
+        container.appendChild(item);
+    });
+}
+
+// 승인/거절 전송
+function decideApproval(id, approve) {
+    socket.send(JSON.stringify({
+        type: approve ? 'approve' : 'reject',
+        approval_id: id
+    }));
+}
+
+// 전송된 명령을 실행 중 목록에 추가
+function handleCommandDispatched(msg) {
+    if (!msg.targets || msg.targets.length === 0) {
+        return;
+    }
+    runningCommands.set(msg.command_id, {
+        command: msg.command,
+        targets: msg.targets,
+        done: new Set()
+    });
+    updateRunningDisplay();
+}
+
+// 에이전트의 결과가 도착하면 완료 처리
+function markCommandDone(commandId, agentId) {
+    const entry = runningCommands.get(commandId);
+    if (!entry) {
+        return;
+    }
+    entry.done.add(agentId);
+    if (entry.done.size >= entry.targets.length) {
+        runningCommands.delete(commandId);
+    }
+    updateRunningDisplay();
+}
+
+// 실행 중인 명령 표시
+function updateRunningDisplay() {
+    const section = document.getElementById('running-section');
+    const container = document.getElementById('running');
+    section.style.display = runningCommands.size > 0 ? 'block' : 'none';
+    container.innerHTML = '';
+
+    runningCommands.forEach((entry, id) => {
+        const item = document.createElement('div');
+        item.className = 'running-item';
+        item.innerHTML = `
+            <div>
+                <span class="result-command">${escapeHtml(entry.command)}</span>
+                ${entry.done.size}/${entry.targets.length} 완료
+            </div>
+            <div class="approval-actions">
+                <button class="btn-reject" onclick="cancelCommand('${id}')">취소</button>
+            </div>
+        `;
+        container.appendChild(item);
+    });
+}
+
+// 실행 중인 명령 취소 (선택된 에이전트가 있으면 그 에이전트만)
+function cancelCommand(commandId) {
+    const msg = {
+        type: 'cancel',
+        command_id: commandId
+    };
+    const target = document.querySelector('input[name="target"]:checked').value;
+    if (target === 'selected' && selectedAgentId) {
+        msg.agent_id = selectedAgentId;
+    }
+    socket.send(JSON.stringify(msg));
 }
 
 // HTML 이스케이프
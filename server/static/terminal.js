@@ -0,0 +1,113 @@
+// 원격 터미널 (대시보드 → 서버 /ws-terminal → 에이전트 pty)
+const screen = document.getElementById('screen');
+const stateEl = document.getElementById('state');
+const params = new URLSearchParams(location.search);
+const agentId = params.get('agent_id');
+
+document.getElementById('title').textContent = `원격 터미널 - ${params.get('name') || agentId}`;
+
+const user = localStorage.getItem('gopc_user') || '';
+const token = localStorage.getItem('gopc_token') || '';
+const socket = new WebSocket(`ws://${location.host}/ws-terminal?agent_id=${encodeURIComponent(agentId)}&user=${encodeURIComponent(user)}&token=${encodeURIComponent(token)}`);
+const decoder = new TextDecoder();
+
+socket.onmessage = (event) => {
+    const msg = JSON.parse(event.data);
+    switch (msg.type) {
+        case 'opened':
+            stateEl.textContent = '연결됨';
+            sendResize();
+            screen.focus();
+            break;
+        case 'output':
+            appendOutput(msg.data);
+            break;
+        case 'closed':
+            stateEl.textContent = `종료됨 (${msg.reason || ''})`;
+            break;
+    }
+};
+
+socket.onclose = () => {
+    if (!stateEl.textContent.startsWith('종료됨')) {
+        stateEl.textContent = '연결 끊김 (권한 또는 인증을 확인하세요)';
+    }
+};
+
+// 출력 추가 (ANSI 제어 문자는 제거하고 표시)
+function appendOutput(data) {
+    const bytes = Uint8Array.from(atob(data), c => c.charCodeAt(0));
+    let text = decoder.decode(bytes, { stream: true });
+    text = text
+        .replace(/\x1b\][^\x07]*(\x07|\x1b\\)/g, '')
+        .replace(/\x1b\[[0-9;?]*[ -/]*[@-~]/g, '')
+        .replace(/\x1b[()][0-9A-Za-z]/g, '')
+        .replace(/\r\n/g, '\n')
+        .replace(/\r/g, '');
+
+    for (const ch of text) {
+        if (ch === '\b') {
+            screen.textContent = screen.textContent.slice(0, -1);
+        } else if (ch === '\x07') {
+            continue;
+        } else {
+            screen.textContent += ch;
+        }
+    }
+    screen.scrollTop = screen.scrollHeight;
+}
+
+// 키 입력을 터미널 입력으로 변환
+const keyMap = {
+    Enter: '\r',
+    Backspace: '\x7f',
+    Tab: '\t',
+    Escape: '\x1b',
+    ArrowUp: '\x1b[A',
+    ArrowDown: '\x1b[B',
+    ArrowRight: '\x1b[C',
+    ArrowLeft: '\x1b[D',
+    Home: '\x1b[H',
+    End: '\x1b[F',
+    Delete: '\x1b[3~'
+};
+
+screen.addEventListener('keydown', (e) => {
+    let data = null;
+    if (e.ctrlKey && e.key.length === 1) {
+        const code = e.key.toUpperCase().charCodeAt(0);
+        if (code >= 64 && code <= 95) {
+            data = String.fromCharCode(code - 64);
+        }
+    } else if (keyMap[e.key]) {
+        data = keyMap[e.key];
+    } else if (e.key.length === 1 && !e.metaKey) {
+        data = e.key;
+    }
+
+    if (data !== null && socket.readyState === WebSocket.OPEN) {
+        socket.send(JSON.stringify({ type: 'input', data: data }));
+        e.preventDefault();
+    }
+});
+
+screen.addEventListener('paste', (e) => {
+    const text = e.clipboardData.getData('text');
+    if (text && socket.readyState === WebSocket.OPEN) {
+        socket.send(JSON.stringify({ type: 'input', data: text }));
+    }
+    e.preventDefault();
+});
+
+// 화면 크기에 맞춰 터미널 크기 전송
+function sendResize() {
+    const charWidth = 8.5;
+    const charHeight = 17;
+    const cols = Math.max(20, Math.floor(screen.clientWidth / charWidth));
+    const rows = Math.max(5, Math.floor(screen.clientHeight / charHeight));
+    if (socket.readyState === WebSocket.OPEN) {
+        socket.send(JSON.stringify({ type: 'resize', cols: cols, rows: rows }));
+    }
+}
+
+window.addEventListener('resize', sendResize);
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+var (
+	// errTemplateStorage 템플릿 파일 저장 실패 (요청 오류가 아닌 서버 오류)
+	errTemplateStorage = errors.New("템플릿 저장 실패")
+	// errTemplateNotFound 없는 템플릿 ID (또는 볼 수 없는 템플릿)
+	errTemplateNotFound = errors.New("템플릿을 찾을 수 없습니다")
+)
+
+// 명령 템플릿의 자리 표시자 {{name}}
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// 템플릿 문자열 값에 쓸 수 없는 셸 특수 문자 (값으로 다른 명령을 이어 붙이지 못하도록)
+const templateUnsafeChars = "`$;&|<>()\"'%^!\r\n"
+
+// CommandTemplate 자주 쓰는 명령을 이름을 붙여 저장한 템플릿
+type CommandTemplate struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Command     string         `json:"command"`         // {{name}} 자리 표시자를 포함할 수 있다
+	GUI         bool           `json:"gui,omitempty"`   // 사용자 세션에서 GUI 프로그램으로 실행
+	Params      []ScriptParam  `json:"params"`          // 자리 표시자 선언 (선언하지 않은 자리 표시자는 필수 문자열)
+	Target      ScheduleTarget `json:"target"`          // 기본 대상 (실행할 때 대상을 지정하지 않으면 사용)
+	Roles       []string       `json:"roles,omitempty"` // 보고 실행할 수 있는 역할 (비어 있으면 모든 역할)
+	CreatedBy   string         `json:"created_by"`
+	UpdatedBy   string         `json:"updated_by"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Favorites   []string       `json:"favorites,omitempty"` // 즐겨찾기한 사용자
+
+	Favorite bool `json:"favorite,omitempty"` // 조회한 사용자의 즐겨찾기 여부 (조회 시 계산)
+}
+
+// visibleTo 역할이 템플릿을 보고 실행할 수 있는지 여부
+func (t *CommandTemplate) visibleTo(role string) bool {
+	return len(t.Roles) == 0 || slices.Contains(t.Roles, role)
+}
+
+// validate 템플릿 내용을 검사하고, 선언하지 않은 자리 표시자를 필수 문자열 파라미터로 추가한다.
+func (t *CommandTemplate) validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("템플릿 이름이 비어 있습니다")
+	}
+	if strings.TrimSpace(t.Command) == "" {
+		return fmt.Errorf("템플릿 명령이 비어 있습니다")
+	}
+	if strings.HasPrefix(t.Command, "gui:") {
+		return fmt.Errorf("GUI 실행은 gui 옵션으로 지정합니다")
+	}
+	for _, role := range t.Roles {
+		if role != "admin" && role != "operator" {
+			return fmt.Errorf("알 수 없는 역할: %q", role)
+		}
+	}
+	if t.Params == nil {
+		t.Params = []ScriptParam{}
+	}
+	declared := map[string]bool{}
+	for _, p := range t.Params {
+		declared[p.Name] = true
+	}
+	for _, m := range templatePlaceholderPattern.FindAllStringSubmatch(t.Command, -1) {
+		if !declared[m[1]] {
+			declared[m[1]] = true
+			t.Params = append(t.Params, ScriptParam{Name: m[1], Type: "string", Required: true})
+		}
+	}
+	return validateScriptParams(t.Params)
+}
+
+// render 파라미터 값을 검증해 자리 표시자를 채운 명령
+func (t *CommandTemplate) render(values map[string]string) (string, map[string]string, error) {
+	params, err := resolveScriptParams(t.Params, values)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, p := range t.Params {
+		if p.Type == "string" && strings.ContainsAny(params[p.Name], templateUnsafeChars) {
+			return "", nil, fmt.Errorf("파라미터 %s에 셸 특수 문자를 쓸 수 없습니다", p.Name)
+		}
+	}
+	command := templatePlaceholderPattern.ReplaceAllStringFunc(t.Command, func(m string) string {
+		return params[templatePlaceholderPattern.FindStringSubmatch(m)[1]]
+	})
+	if t.GUI {
+		command = "gui:" + command
+	}
+	return command, params, nil
+}
+
+// TemplateRun 템플릿 ID로 요청한 명령 실행
+type TemplateRun struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name,omitempty"` // 요청 해석 후 채워짐
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// templateStore data_dir/templates.json 에 저장되는 명령 템플릿
+type templateStore struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]*CommandTemplate
+}
+
+func newTemplateStore(cfg *config.Config) *templateStore {
+	s := &templateStore{
+		path:  filepath.Join(cfg.DataDir, "templates.json"),
+		items: make(map[string]*CommandTemplate),
+	}
+	var list []*CommandTemplate
+	if err := loadJSONFile(s.path, &list); err != nil && !os.IsNotExist(err) {
+		log.Printf("템플릿 목록을 읽을 수 없습니다: %v", err)
+	}
+	for _, item := range list {
+		s.items[item.ID] = item
+	}
+	return s
+}
+
+// save mu를 잡은 상태에서 호출
+func (s *templateStore) save() error {
+	list := make([]*CommandTemplate, 0, len(s.items))
+	for _, item := range s.items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if err := saveJSONFile(s.path, list); err != nil {
+		log.Printf("템플릿 목록 저장 실패: %v", err)
+		return fmt.Errorf("%w: %v", errTemplateStorage, err)
+	}
+	return nil
+}
+
+// forUser 사용자에게 보여 줄 사본 (즐겨찾기 여부 표시)
+func (t *CommandTemplate) forUser(user *config.DashboardUser) *CommandTemplate {
+	copied := *t
+	copied.Favorite = slices.Contains(t.Favorites, user.Name)
+	return &copied
+}
+
+// List 사용자가 볼 수 있는 템플릿 (즐겨찾기 먼저, 이름순)
+func (s *templateStore) List(user *config.DashboardUser) []*CommandTemplate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*CommandTemplate, 0, len(s.items))
+	for _, item := range s.items {
+		if item.visibleTo(user.Role) {
+			list = append(list, item.forUser(user))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Favorite != list[j].Favorite {
+			return list[i].Favorite
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Get 사용자가 볼 수 있는 템플릿
+func (s *templateStore) Get(id string, user *config.DashboardUser) (*CommandTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok || !item.visibleTo(user.Role) {
+		return nil, fmt.Errorf("%w: %s", errTemplateNotFound, id)
+	}
+	return item.forUser(user), nil
+}
+
+// Save 템플릿을 새로 만들거나 (ID가 비어 있으면) 기존 템플릿을 바꾼다.
+func (s *templateStore) Save(item CommandTemplate, user string) (*CommandTemplate, error) {
+	if err := item.validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item.ID == "" {
+		item.ID = newID()
+		item.CreatedBy = user
+		item.Favorites = nil
+	} else {
+		old, ok := s.items[item.ID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errTemplateNotFound, item.ID)
+		}
+		item.CreatedBy = old.CreatedBy
+		item.Favorites = old.Favorites
+	}
+	item.UpdatedBy = user
+	item.UpdatedAt = time.Now()
+	item.Favorite = false
+
+	prev := s.items[item.ID]
+	s.items[item.ID] = &item
+	if err := s.save(); err != nil {
+		if prev != nil {
+			s.items[item.ID] = prev
+		} else {
+			delete(s.items, item.ID)
+		}
+		return nil, err
+	}
+	copied := item
+	return &copied, nil
+}
+
+// Delete 템플릿을 지운다.
+func (s *templateStore) Delete(id string) (*CommandTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errTemplateNotFound, id)
+	}
+	delete(s.items, id)
+	if err := s.save(); err != nil {
+		s.items[id] = item
+		return nil, err
+	}
+	return item, nil
+}
+
+// SetFavorite 사용자의 즐겨찾기에 추가하거나 뺀다.
+func (s *templateStore) SetFavorite(id string, user *config.DashboardUser, favorite bool) (*CommandTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[id]
+	if !ok || !item.visibleTo(user.Role) {
+		return nil, fmt.Errorf("%w: %s", errTemplateNotFound, id)
+	}
+	prev := item.Favorites
+	has := slices.Contains(item.Favorites, user.Name)
+	switch {
+	case favorite && !has:
+		item.Favorites = append(slices.Clone(item.Favorites), user.Name)
+	case !favorite && has:
+		item.Favorites = slices.DeleteFunc(slices.Clone(item.Favorites), func(name string) bool { return name == user.Name })
+	default:
+		return item.forUser(user), nil
+	}
+	if err := s.save(); err != nil {
+		item.Favorites = prev
+		return nil, err
+	}
+	return item.forUser(user), nil
+}
+
+// Resolve 템플릿으로 명령을 채우고, 요청에 대상이 없으면 템플릿의 기본 대상을 쓴다.
+// 역할 확인은 handleCommand에서 사용자와 함께 한다.
+func (s *templateStore) Resolve(req *CommandRequest) error {
+	s.mu.Lock()
+	item, ok := s.items[req.Template.ID]
+	var copied CommandTemplate
+	if ok {
+		copied = *item
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", errTemplateNotFound, req.Template.ID)
+	}
+	command, params, err := copied.render(req.Template.Params)
+	if err != nil {
+		return fmt.Errorf("템플릿 %s: %v", copied.Name, err)
+	}
+	req.Command = command
+	req.Template.Name = copied.Name
+	req.Template.Params = params
+	if req.AgentID == "" && req.Group == "" && len(req.Hostnames) == 0 {
+		req.Group = copied.Target.Group
+		req.Hostnames = copied.Target.Hostnames
+	}
+	return nil
+}
+
+// Allowed 사용자가 요청을 실행할 수 있는지 확인한다.
+// templates_only 사용자는 볼 수 있는 템플릿으로만 명령을 보낼 수 있다.
+func (s *templateStore) Allowed(req CommandRequest, user *config.DashboardUser) error {
+	if req.Template == nil {
+		if user.TemplatesOnly {
+			return fmt.Errorf("%s 사용자는 템플릿으로만 명령을 실행할 수 있습니다", user.Name)
+		}
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item, ok := s.items[req.Template.ID]; !ok || !item.visibleTo(user.Role) {
+		return fmt.Errorf("%w: %s", errTemplateNotFound, req.Template.ID)
+	}
+	return nil
+}
+
+func templateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errTemplateStorage):
+		return http.StatusInternalServerError
+	case errors.Is(err, errTemplateNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// handleListTemplates GET /api/templates
+func handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, templates.List(user))
+}
+
+// handleGetTemplate GET /api/templates/{id}
+func handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok {
+		return
+	}
+	item, err := templates.Get(r.PathValue("id"), user)
+	if err != nil {
+		http.Error(w, err.Error(), templateErrorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// handleSaveTemplate POST /api/templates (id가 있으면 수정)
+func handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok || !requireAdmin(w, user, "saving templates") {
+		return
+	}
+	var item CommandTemplate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&item); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	saved, err := templates.Save(item, user.Name)
+	if err != nil {
+		http.Error(w, err.Error(), templateErrorStatus(err))
+		return
+	}
+	audit.Record("template_saved", user.Name, map[string]interface{}{
+		"template_id": saved.ID,
+		"name":        saved.Name,
+		"command":     saved.Command,
+		"roles":       saved.Roles,
+	})
+	writeJSON(w, http.StatusOK, saved.forUser(user))
+}
+
+// handleDeleteTemplate DELETE /api/templates/{id}
+func handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := requireDashboardUser(w, r)
+	if !ok || !requireAdmin(w, user, "deleting templates") {
+		return
+	}
+	item, err := templates.Delete(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), templateErrorStatus(err))
+		return
+	}
+	audit.Record("template_deleted", user.Name, map[string]interface{}{
+		"template_id": item.ID,
+		"name":        item.Name,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFavoriteTemplate PUT/DELETE /api/templates/{id}/favorite
+func handleFavoriteTemplate(favorite bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := requireDashboardUser(w, r)
+		if !ok {
+			return
+		}
+		item, err := templates.SetFavorite(r.PathValue("id"), user, favorite)
+		if err != nil {
+			http.Error(w, err.Error(), templateErrorStatus(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	}
+}
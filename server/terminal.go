@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gopc-server/config"
+)
+
+// 대시보드 터미널에 한 메시지를 쓰는 최대 시간 (넘으면 세션을 닫음)
+const terminalWriteTimeout = 10 * time.Second
+
+// terminalSession 대시보드 터미널 ↔ 에이전트 pty 세션 연결
+type terminalSession struct {
+	id         string
+	agentID    string
+	user       string
+	dashboard  *websocket.Conn
+	writeMu    sync.Mutex // dashboard 쓰기 직렬화
+	lastActive time.Time
+	opened     time.Time
+}
+
+// send 대시보드에 메시지를 쓴다. 브라우저가 terminalWriteTimeout 안에 받지 못하면 연결을 닫는다.
+func (s *terminalSession) send(msg interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.dashboard.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+	err := s.dashboard.WriteJSON(msg)
+	if err != nil {
+		s.dashboard.Close()
+	}
+	return err
+}
+
+// terminalBridge 대시보드 터미널 엔드포인트와 에이전트 pty 세션을 중계한다.
+type terminalBridge struct {
+	mu          sync.Mutex
+	sessions    map[string]*terminalSession
+	maxPerAgent int
+	idleTimeout time.Duration
+	token       string
+}
+
+func newTerminalBridge(cfg *config.Config) *terminalBridge {
+	return &terminalBridge{
+		sessions:    make(map[string]*terminalSession),
+		maxPerAgent: cfg.Terminal.MaxSessionsPerAgent,
+		idleTimeout: cfg.GetTerminalIdleTimeout(),
+		token:       cfg.AuthToken,
+	}
+}
+
+// register 세션 수 제한을 확인하고 새 세션을 등록한다.
+func (b *terminalBridge) register(s *terminalSession) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	for _, other := range b.sessions {
+		if other.agentID == s.agentID {
+			count++
+		}
+	}
+	if b.maxPerAgent > 0 && count >= b.maxPerAgent {
+		return fmt.Errorf("에이전트당 터미널 세션 제한 (%d) 초과", b.maxPerAgent)
+	}
+	b.sessions[s.id] = s
+	return nil
+}
+
+func (b *terminalBridge) get(id string) *terminalSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sessions[id]
+}
+
+// remove 세션을 제거한다. 이미 제거된 경우 false.
+func (b *terminalBridge) remove(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.sessions[id]; !ok {
+		return false
+	}
+	delete(b.sessions, id)
+	return true
+}
+
+func (b *terminalBridge) touch(s *terminalSession) {
+	b.mu.Lock()
+	s.lastActive = time.Now()
+	b.mu.Unlock()
+}
+
+// sendToAgent 에이전트에 pty 메시지 전송 (agentsMutex를 잡은 상태에서 호출)
+func (b *terminalBridge) sendToAgent(agentID, msgType string, fields map[string]interface{}) error {
+	for _, agent := range agents {
+		if agent.ID != agentID {
+			continue
+		}
+		msg := map[string]interface{}{
+			"type":  msgType,
+			"token": b.token,
+		}
+		for k, v := range fields {
+			msg[k] = v
+		}
+		return agent.Conn.WriteJSON(msg)
+	}
+	return fmt.Errorf("agent %s not connected", agentID)
+}
+
+// handleTerminalConnections 대시보드 터미널 WebSocket 엔드포인트 (/ws-terminal?agent_id=...)
+func (b *terminalBridge) handleTerminalConnections(w http.ResponseWriter, r *http.Request) {
+	user, ok := authenticateDashboard(r, dashboardUsers)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.Role != "admin" {
+		http.Error(w, "terminal requires admin role", http.StatusForbidden)
+		return
+	}
+	agentID := r.URL.Query().Get("agent_id")
+	if agentID == "" {
+		http.Error(w, "agent_id required", http.StatusBadRequest)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("terminal upgrade error:", err)
+		return
+	}
+	defer ws.Close()
+
+	now := time.Now()
+	s := &terminalSession{
+		id:         newID(),
+		agentID:    agentID,
+		user:       user.Name,
+		dashboard:  ws,
+		lastActive: now,
+		opened:     now,
+	}
+	if err := b.register(s); err != nil {
+		s.send(map[string]string{"type": "closed", "reason": err.Error()})
+		return
+	}
+
+	agentsMutex.Lock()
+	err = b.sendToAgent(agentID, "pty_open", map[string]interface{}{
+		"session": s.id,
+		"cols":    80,
+		"rows":    24,
+	})
+	agentsMutex.Unlock()
+	if err != nil {
+		b.remove(s.id)
+		s.send(map[string]string{"type": "closed", "reason": err.Error()})
+		return
+	}
+
+	audit.Record("terminal_open", user.Name, map[string]interface{}{
+		"session":  s.id,
+		"agent_id": agentID,
+	})
+
+	defer func() {
+		if b.remove(s.id) {
+			agentsMutex.Lock()
+			b.sendToAgent(agentID, "pty_close", map[string]interface{}{"session": s.id})
+			agentsMutex.Unlock()
+		}
+		audit.Record("terminal_close", user.Name, map[string]interface{}{
+			"session":  s.id,
+			"agent_id": agentID,
+			"duration": time.Since(s.opened).Round(time.Second).String(),
+		})
+	}()
+
+	for {
+		var msg struct {
+			Type string `json:"type"`
+			Data string `json:"data"`
+			Cols uint16 `json:"cols"`
+			Rows uint16 `json:"rows"`
+		}
+		if err := ws.ReadJSON(&msg); err != nil {
+			return
+		}
+		if b.get(s.id) == nil {
+			// 유휴 시간 초과 또는 에이전트 쪽에서 종료됨
+			return
+		}
+		b.touch(s)
+
+		agentsMutex.Lock()
+		switch msg.Type {
+		case "input":
+			err = b.sendToAgent(agentID, "pty_input", map[string]interface{}{
+				"session": s.id,
+				"data":    base64.StdEncoding.EncodeToString([]byte(msg.Data)),
+			})
+		case "resize":
+			err = b.sendToAgent(agentID, "pty_resize", map[string]interface{}{
+				"session": s.id,
+				"cols":    msg.Cols,
+				"rows":    msg.Rows,
+			})
+		}
+		agentsMutex.Unlock()
+		if err != nil {
+			s.send(map[string]string{"type": "closed", "reason": err.Error()})
+			return
+		}
+	}
+}
+
+// FromAgent 에이전트의 pty_* 메시지를 해당 대시보드 터미널로 전달
+func (b *terminalBridge) FromAgent(agentID, msgType string, msg map[string]interface{}) {
+	data, _ := json.Marshal(msg["pty"])
+	var pty struct {
+		Session string `json:"session"`
+		Data    string `json:"data"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &pty); err != nil {
+		return
+	}
+
+	s := b.get(pty.Session)
+	if s == nil || s.agentID != agentID {
+		return
+	}
+
+	switch msgType {
+	case "pty_opened":
+		s.send(map[string]string{"type": "opened"})
+	case "pty_output":
+		b.touch(s)
+		if err := s.send(map[string]string{"type": "output", "data": pty.Data}); err != nil {
+			// 대시보드 읽기 루프가 닫힌 연결을 보고 pty_close를 보낸다
+			log.Printf("Terminal session %s: dashboard write failed: %v", s.id, err)
+		}
+	case "pty_closed":
+		b.remove(s.id)
+		s.send(map[string]string{"type": "closed", "reason": pty.Reason})
+		s.dashboard.Close()
+	}
+}
+
+// AgentGone 에이전트 연결이 끊기면 해당 에이전트의 세션을 모두 종료한다.
+func (b *terminalBridge) AgentGone(agentID string) {
+	b.mu.Lock()
+	var gone []*terminalSession
+	for id, s := range b.sessions {
+		if s.agentID == agentID {
+			delete(b.sessions, id)
+			gone = append(gone, s)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, s := range gone {
+		s.send(map[string]string{"type": "closed", "reason": "agent disconnected"})
+		s.dashboard.Close()
+	}
+}
+
+// runIdleCheck 유휴 시간이 지난 세션을 종료한다.
+func (b *terminalBridge) runIdleCheck() {
+	if b.idleTimeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		b.mu.Lock()
+		var idle []*terminalSession
+		for id, s := range b.sessions {
+			if now.Sub(s.lastActive) > b.idleTimeout {
+				delete(b.sessions, id)
+				idle = append(idle, s)
+			}
+		}
+		b.mu.Unlock()
+
+		for _, s := range idle {
+			log.Printf("Terminal session %s idle timeout", s.id)
+			agentsMutex.Lock()
+			b.sendToAgent(s.agentID, "pty_close", map[string]interface{}{"session": s.id})
+			agentsMutex.Unlock()
+			s.send(map[string]string{"type": "closed", "reason": "idle timeout"})
+			s.dashboard.Close()
+		}
+	}
+}
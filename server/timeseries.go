@@ -0,0 +1,665 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+// 상태 기록 해상도
+const (
+	ResolutionRaw    = "raw"    // 상태 보고마다 (메모리에만 보관)
+	ResolutionMinute = "minute" // 1분 평균
+	ResolutionHour   = "hour"   // 1시간 평균
+)
+
+// 끝난 구간을 닫고 오래된 기록을 지우는 주기, 파일을 다시 쓰는 주기
+const (
+	metricsFlushInterval   = time.Minute
+	metricsCompactInterval = time.Hour
+)
+
+// auto 해상도에서 원본/1분 기록을 쓰는 최대 조회 구간
+const (
+	autoRawSpan    = time.Hour
+	autoMinuteSpan = 2 * 24 * time.Hour
+)
+
+// StatusPoint 상태 기록 한 점. 1분/1시간 해상도는 구간 평균이고 Time은 구간 시작 시각이다.
+type StatusPoint struct {
+	Time          time.Time `json:"time"`
+	Count         int       `json:"count"` // 평균에 들어간 상태 보고 수
+	CPU           float64   `json:"cpu"`
+	Memory        float64   `json:"memory"`
+	Disk          float64   `json:"disk"`
+	Load          float64   `json:"load,omitempty"` // 1분 평균 부하 (Linux)
+	LatencyMs     float64   `json:"latency_ms,omitempty"`
+	RxBytesPerSec float64   `json:"rx_bytes_per_sec"` // 루프백 제외 합계
+	TxBytesPerSec float64   `json:"tx_bytes_per_sec"`
+}
+
+// GroupPoint 그룹 집계 한 점. 평균은 에이전트마다 같은 비중으로 계산한다.
+type GroupPoint struct {
+	StatusPoint
+	Agents    int     `json:"agents"` // 이 구간에 기록이 있는 에이전트 수
+	CPUMax    float64 `json:"cpu_max"`
+	MemoryMax float64 `json:"memory_max"`
+	DiskMax   float64 `json:"disk_max"`
+}
+
+// pointFromStatus 상태 보고를 기록 한 점으로 바꾼다.
+func pointFromStatus(now time.Time, status *AgentStatus) StatusPoint {
+	p := StatusPoint{
+		Time:      now,
+		Count:     1,
+		CPU:       status.CPUUsage,
+		Memory:    status.MemoryUsage,
+		Disk:      status.DiskUsage,
+		LatencyMs: status.LatencyMs,
+	}
+	if len(status.Load) > 0 {
+		p.Load = status.Load[0]
+	}
+	for _, ni := range status.Network {
+		if !ni.Loopback {
+			p.RxBytesPerSec += ni.RxBytesPerSec
+			p.TxBytesPerSec += ni.TxBytesPerSec
+		}
+	}
+	return p
+}
+
+// pointSum 구간 평균을 내기 위한 합계 (보고 수로 가중)
+type pointSum struct {
+	start time.Time
+	sum   StatusPoint
+}
+
+func (a *pointSum) add(p StatusPoint) {
+	w := float64(p.Count)
+	a.sum.Count += p.Count
+	a.sum.CPU += p.CPU * w
+	a.sum.Memory += p.Memory * w
+	a.sum.Disk += p.Disk * w
+	a.sum.Load += p.Load * w
+	a.sum.LatencyMs += p.LatencyMs * w
+	a.sum.RxBytesPerSec += p.RxBytesPerSec * w
+	a.sum.TxBytesPerSec += p.TxBytesPerSec * w
+}
+
+func (a *pointSum) avg() StatusPoint {
+	n := float64(a.sum.Count)
+	return StatusPoint{
+		Time:          a.start,
+		Count:         a.sum.Count,
+		CPU:           a.sum.CPU / n,
+		Memory:        a.sum.Memory / n,
+		Disk:          a.sum.Disk / n,
+		Load:          a.sum.Load / n,
+		LatencyMs:     a.sum.LatencyMs / n,
+		RxBytesPerSec: a.sum.RxBytesPerSec / n,
+		TxBytesPerSec: a.sum.TxBytesPerSec / n,
+	}
+}
+
+// seriesTier 해상도 하나의 기록과 아직 끝나지 않은 구간
+type seriesTier struct {
+	step      time.Duration // 0 = 원본
+	retention time.Duration
+	file      string // 끝난 구간을 한 줄씩 덧붙이는 JSON lines 파일 (원본은 없음)
+	points    []StatusPoint
+	open      *pointSum
+}
+
+// trim 보관 기간이 지난 기록을 지운다.
+func (t *seriesTier) trim(now time.Time) {
+	if t.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-t.retention)
+	i, _ := slices.BinarySearchFunc(t.points, cutoff, func(p StatusPoint, c time.Time) int {
+		return p.Time.Compare(c)
+	})
+	if i > 0 {
+		t.points = append(t.points[:0:0], t.points[i:]...)
+	}
+}
+
+// between from 이상 to 이하의 기록 (끝나지 않은 구간은 지금까지의 평균으로 덧붙임)
+func (t *seriesTier) between(from, to time.Time) []StatusPoint {
+	var out []StatusPoint
+	for _, p := range t.points {
+		if !p.Time.Before(from) && !p.Time.After(to) {
+			out = append(out, p)
+		}
+	}
+	if t.open != nil && !t.open.start.Before(from) && !t.open.start.After(to) {
+		out = append(out, t.open.avg())
+	}
+	return out
+}
+
+// SeriesMeta 에이전트 기록의 이름과 그룹 (data_dir/metrics/<key>/meta.json)
+type SeriesMeta struct {
+	Key      string    `json:"key"` // MAC 주소 또는 호스트 이름
+	Hostname string    `json:"hostname"`
+	Group    string    `json:"group,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// statusSeries 에이전트 한 대의 해상도별 상태 기록
+type statusSeries struct {
+	SeriesMeta
+	dir               string
+	raw, minute, hour *seriesTier
+}
+
+func (s *statusSeries) tier(resolution string) *seriesTier {
+	switch resolution {
+	case ResolutionRaw:
+		return s.raw
+	case ResolutionMinute:
+		return s.minute
+	case ResolutionHour:
+		return s.hour
+	}
+	return nil
+}
+
+// metricsStore 에이전트 상태를 시계열로 보관하고 1분/1시간 평균으로 줄인다.
+// 끝난 1분/1시간 구간은 data_dir/metrics 아래 파일에 덧붙이고, 주기적으로 보관 기간이 지난 줄을 지워 다시 쓴다.
+//
+// 상태 보고는 agentsMutex를 잡은 채로 기록하므로 파일 작업은 mu 안에서 하지 않고
+// writes 대기열에 넣어 runWriter가 잠금 밖에서 순서대로 실행한다.
+type metricsStore struct {
+	mu        sync.Mutex
+	dir       string
+	retention map[string]time.Duration
+	series    map[string]*statusSeries
+	compacted time.Time
+	writes    []func()
+	wake      chan struct{}
+}
+
+func newMetricsStore(cfg *config.Config) *metricsStore {
+	raw, minute, hour := cfg.GetMetricsRetention()
+	s := &metricsStore{
+		dir: filepath.Join(cfg.DataDir, "metrics"),
+		retention: map[string]time.Duration{
+			ResolutionRaw:    raw,
+			ResolutionMinute: minute,
+			ResolutionHour:   hour,
+		},
+		series:    make(map[string]*statusSeries),
+		compacted: time.Now(),
+		wake:      make(chan struct{}, 1),
+	}
+	s.load()
+	return s
+}
+
+// seriesDirName 키를 디렉토리 이름으로 쓸 수 있게 바꾼다 (MAC 주소의 ':' 등).
+func seriesDirName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+func (s *metricsStore) newSeries(meta SeriesMeta) *statusSeries {
+	dir := filepath.Join(s.dir, seriesDirName(meta.Key))
+	return &statusSeries{
+		SeriesMeta: meta,
+		dir:        dir,
+		raw:        &seriesTier{retention: s.retention[ResolutionRaw]},
+		minute:     &seriesTier{step: time.Minute, retention: s.retention[ResolutionMinute], file: filepath.Join(dir, "minute.jsonl")},
+		hour:       &seriesTier{step: time.Hour, retention: s.retention[ResolutionHour], file: filepath.Join(dir, "hour.jsonl")},
+	}
+}
+
+// load 저장된 1분/1시간 기록을 불러온다 (보관 기간이 지난 기록은 버림).
+func (s *metricsStore) load() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("상태 기록: %v", err)
+		}
+		return
+	}
+	now := time.Now()
+	points := 0
+	for _, entry := range entries {
+		var meta SeriesMeta
+		if err := loadJSONFile(filepath.Join(s.dir, entry.Name(), "meta.json"), &meta); err != nil || meta.Key == "" {
+			continue
+		}
+		series := s.newSeries(meta)
+		for _, tier := range []*seriesTier{series.minute, series.hour} {
+			tier.points, err = readPoints(tier.file)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("상태 기록 %s: %v", tier.file, err)
+			}
+			tier.trim(now)
+			points += len(tier.points)
+		}
+		// 재시작 전에 닫지 못한 1시간 구간을 저장된 1분 평균으로 다시 만든다
+		var lastHour time.Time
+		if n := len(series.hour.points); n > 0 {
+			lastHour = series.hour.points[n-1].Time
+		}
+		for _, p := range series.minute.points {
+			if p.Time.Truncate(time.Hour).After(lastHour) {
+				s.accumulate(series, series.hour, p)
+			}
+		}
+		s.series[meta.Key] = series
+	}
+	log.Printf("상태 기록: 에이전트 %d대, %d개 로드", len(s.series), points)
+}
+
+// readPoints JSON lines 파일을 읽는다. 깨진 줄(쓰다가 멈춘 마지막 줄 등)은 건너뛴다.
+func readPoints(path string) ([]StatusPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var points []StatusPoint
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var p StatusPoint
+		if err := json.Unmarshal(scanner.Bytes(), &p); err == nil {
+			points = append(points, p)
+		}
+	}
+	slices.SortStableFunc(points, func(a, b StatusPoint) int { return a.Time.Compare(b.Time) })
+	return points, scanner.Err()
+}
+
+// appendPoint 끝난 구간 하나를 파일 끝에 덧붙인다.
+func appendPoint(path string, p StatusPoint) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// writePoints 파일을 메모리의 기록으로 다시 쓴다 (임시 파일에 쓴 뒤 이름 바꿈).
+func writePoints(path string, points []StatusPoint) error {
+	var b strings.Builder
+	for _, p := range points {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// later 파일 작업을 대기열에 넣는다 (mu를 잡은 상태에서 호출)
+func (s *metricsStore) later(write func()) {
+	s.writes = append(s.writes, write)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runWriter 대기열의 파일 작업을 실행한다.
+func (s *metricsStore) runWriter() {
+	for range s.wake {
+		s.flush()
+	}
+}
+
+// flush 지금까지 쌓인 파일 작업을 넣은 순서대로 실행한다. 하나의 고루틴(runWriter)에서만 호출한다.
+func (s *metricsStore) flush() {
+	s.mu.Lock()
+	writes := s.writes
+	s.writes = nil
+	s.mu.Unlock()
+
+	for _, write := range writes {
+		write()
+	}
+}
+
+// Record 상태 보고를 원본 기록에 더하고 1분/1시간 평균에 반영한다.
+func (s *metricsStore) Record(info *AgentInfo, status *AgentStatus, now time.Time) {
+	if info == nil {
+		return
+	}
+	key := inventoryKey(info)
+	if key == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, ok := s.series[key]
+	if !ok {
+		series = s.newSeries(SeriesMeta{Key: key})
+		s.series[key] = series
+	}
+	if !ok || series.Hostname != info.Hostname || series.Group != info.Group || now.Sub(series.LastSeen) >= metricsCompactInterval {
+		series.Hostname, series.Group, series.LastSeen = info.Hostname, info.Group, now
+		path, meta := filepath.Join(series.dir, "meta.json"), series.SeriesMeta
+		s.later(func() {
+			if err := saveJSONFile(path, meta); err != nil {
+				log.Printf("상태 기록 %s: %v", key, err)
+			}
+		})
+	}
+
+	p := pointFromStatus(now, status)
+	if series.raw.retention > 0 {
+		series.raw.points = append(series.raw.points, p)
+		series.raw.trim(now)
+	}
+	s.closeDue(series, now)
+	s.accumulate(series, series.minute, p)
+}
+
+// accumulate 점을 해당 해상도의 열린 구간에 더한다 (다른 구간이 열려 있으면 먼저 닫음).
+func (s *metricsStore) accumulate(series *statusSeries, tier *seriesTier, p StatusPoint) {
+	start := p.Time.Truncate(tier.step)
+	if tier.open != nil && !tier.open.start.Equal(start) {
+		s.closeTier(series, tier)
+	}
+	if tier.open == nil {
+		tier.open = &pointSum{start: start}
+	}
+	tier.open.add(p)
+}
+
+// closeDue now 기준으로 끝난 1분/1시간 구간을 닫는다.
+func (s *metricsStore) closeDue(series *statusSeries, now time.Time) {
+	for _, tier := range []*seriesTier{series.minute, series.hour} {
+		if tier.open != nil && !now.Before(tier.open.start.Add(tier.step)) {
+			s.closeTier(series, tier)
+		}
+	}
+}
+
+// closeTier 열린 구간을 평균 한 점으로 닫아 기록과 파일에 더한다. 닫은 1분 평균은 1시간 평균에 더한다.
+func (s *metricsStore) closeTier(series *statusSeries, tier *seriesTier) {
+	p := tier.open.avg()
+	tier.open = nil
+	tier.points = append(tier.points, p)
+	dir, file := series.dir, tier.file
+	s.later(func() {
+		err := os.MkdirAll(dir, 0700)
+		if err == nil {
+			err = appendPoint(file, p)
+		}
+		if err != nil {
+			log.Printf("상태 기록 %s: %v", file, err)
+		}
+	})
+	if tier == series.minute {
+		s.accumulate(series, series.hour, p)
+	}
+}
+
+// runMaintenance 보고가 끊긴 에이전트의 구간을 닫고, 보관 기간이 지난 기록을 지운다.
+func (s *metricsStore) runMaintenance() {
+	go s.runWriter()
+
+	ticker := time.NewTicker(metricsFlushInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.mu.Lock()
+		compact := now.Sub(s.compacted) >= metricsCompactInterval
+		if compact {
+			s.compacted = now
+		}
+		for key, series := range s.series {
+			s.closeDue(series, now)
+			series.raw.trim(now)
+			for _, tier := range []*seriesTier{series.minute, series.hour} {
+				before := len(tier.points)
+				tier.trim(now)
+				if compact && len(tier.points) != before {
+					file, points := tier.file, slices.Clone(tier.points)
+					s.later(func() {
+						if err := writePoints(file, points); err != nil {
+							log.Printf("상태 기록 %s: %v", file, err)
+						}
+					})
+				}
+			}
+			// 모든 기록이 보관 기간을 넘긴 에이전트는 지운다
+			if len(series.raw.points)+len(series.minute.points)+len(series.hour.points) == 0 &&
+				series.minute.open == nil && series.hour.open == nil {
+				delete(s.series, key)
+				dir := series.dir
+				s.later(func() { os.RemoveAll(dir) })
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Resolve 에이전트 ID(연결된 경우), MAC 주소 또는 호스트 이름으로 기록의 키를 찾는다.
+func (s *metricsStore) Resolve(id string) (string, bool) {
+	agentsMutex.Lock()
+	if agent := agentByID(id); agent != nil && agent.Info != nil {
+		id = inventoryKey(agent.Info)
+	}
+	agentsMutex.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[id]; ok {
+		return id, true
+	}
+	for key, series := range s.series {
+		if strings.EqualFold(series.Hostname, id) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// pickResolution auto일 때 조회 구간과 보관 기간에 맞는 가장 촘촘한 해상도
+func (s *metricsStore) pickResolution(from, to, now time.Time, allowRaw bool) string {
+	span := to.Sub(from)
+	kept := func(resolution string) bool {
+		retention := s.retention[resolution]
+		return (retention == 0 && resolution != ResolutionRaw) || now.Sub(from) <= retention
+	}
+	switch {
+	case allowRaw && span <= autoRawSpan && kept(ResolutionRaw):
+		return ResolutionRaw
+	case span <= autoMinuteSpan && kept(ResolutionMinute):
+		return ResolutionMinute
+	default:
+		return ResolutionHour
+	}
+}
+
+// Agent 에이전트 한 대의 기록
+func (s *metricsStore) Agent(key, resolution string, from, to time.Time) (SeriesMeta, []StatusPoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series := s.series[key]
+	if series == nil {
+		return SeriesMeta{Key: key}, nil
+	}
+	return series.SeriesMeta, series.tier(resolution).between(from, to)
+}
+
+// Group 그룹에 속한 에이전트 기록을 구간별로 모은다 (그룹은 마지막 보고 기준).
+func (s *metricsStore) Group(group, resolution string, from, to time.Time) (agents []SeriesMeta, points []GroupPoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type bucket struct {
+		sum     pointSum // 에이전트마다 비중 1
+		reports int
+		max     GroupPoint
+	}
+	buckets := map[time.Time]*bucket{}
+	for _, series := range s.series {
+		if series.Group != group {
+			continue
+		}
+		agents = append(agents, series.SeriesMeta)
+		for _, p := range series.tier(resolution).between(from, to) {
+			b := buckets[p.Time]
+			if b == nil {
+				b = &bucket{sum: pointSum{start: p.Time}}
+				buckets[p.Time] = b
+			}
+			b.reports += p.Count
+			p.Count = 1
+			b.sum.add(p)
+			b.max.CPUMax = max(b.max.CPUMax, p.CPU)
+			b.max.MemoryMax = max(b.max.MemoryMax, p.Memory)
+			b.max.DiskMax = max(b.max.DiskMax, p.Disk)
+		}
+	}
+	for _, b := range buckets {
+		gp := b.max
+		gp.StatusPoint = b.sum.avg()
+		gp.Agents = gp.Count
+		gp.Count = b.reports
+		points = append(points, gp)
+	}
+	slices.SortFunc(points, func(a, b GroupPoint) int { return a.Time.Compare(b.Time) })
+	slices.SortFunc(agents, func(a, b SeriesMeta) int { return strings.Compare(a.Hostname, b.Hostname) })
+	return agents, points
+}
+
+// metricsQuery 조회 구간과 해상도 (?from=&to=&resolution=raw|minute|hour|auto)
+// from, to는 RFC3339 또는 YYYY-MM-DD이며 기본값은 최근 24시간이다.
+func metricsQuery(r *http.Request, allowRaw bool) (resolution string, from, to time.Time, err error) {
+	q := r.URL.Query()
+	now := time.Now()
+	to = now
+	if v := q.Get("to"); v != "" {
+		if to, err = parseHistoryDate(v, true); err != nil {
+			return
+		}
+	}
+	from = to.Add(-24 * time.Hour)
+	if v := q.Get("from"); v != "" {
+		if from, err = parseHistoryDate(v, false); err != nil {
+			return
+		}
+	}
+	if !from.Before(to) {
+		err = fmt.Errorf("from must be before to")
+		return
+	}
+	switch resolution = q.Get("resolution"); resolution {
+	case "", "auto":
+		resolution = metrics.pickResolution(from, to, now, allowRaw)
+	case ResolutionRaw:
+		if !allowRaw {
+			err = fmt.Errorf("raw resolution is only available for a single agent")
+		}
+	case ResolutionMinute, ResolutionHour:
+	default:
+		err = fmt.Errorf("resolution must be raw, minute, hour or auto")
+	}
+	return
+}
+
+// handleAgentMetrics GET /api/agents/{id}/metrics
+// {id}는 연결된 에이전트 ID, 또는 꺼진 에이전트의 MAC 주소나 호스트 이름
+func handleAgentMetrics(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	resolution, from, to, err := metricsQuery(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, ok := metrics.Resolve(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "no metrics for this agent", http.StatusNotFound)
+		return
+	}
+	meta, points := metrics.Agent(key, resolution, from, to)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"agent":      meta,
+		"resolution": resolution,
+		"from":       from,
+		"to":         to,
+		"points":     nonNil(points),
+	})
+}
+
+// handleGroupMetrics GET /api/groups/{group}/metrics (그룹이 없는 에이전트는 "-")
+func handleGroupMetrics(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireDashboardUser(w, r); !ok {
+		return
+	}
+	resolution, from, to, err := metricsQuery(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group := r.PathValue("group")
+	if group == "-" {
+		group = ""
+	}
+	agents, points := metrics.Group(group, resolution, from, to)
+	if len(agents) == 0 {
+		http.Error(w, "no metrics for this group", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"group":      group,
+		"agents":     agents,
+		"resolution": resolution,
+		"from":       from,
+		"to":         to,
+		"points":     nonNil(points),
+	})
+}
+
+// nonNil 빈 목록을 null 대신 []로 내보낸다.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
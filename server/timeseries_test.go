@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopc-server/config"
+)
+
+func TestMetricsRecordDefersFileWrites(t *testing.T) {
+	dir := t.TempDir()
+	s := newMetricsStore(&config.Config{DataDir: dir})
+	info := &AgentInfo{Hostname: "pc-01", MacAddr: "aa:bb:cc:dd:ee:ff"}
+	start := time.Date(2026, 1, 5, 9, 0, 10, 0, time.Local)
+
+	s.Record(info, &AgentStatus{CPUUsage: 10}, start)
+	s.Record(info, &AgentStatus{CPUUsage: 30}, start.Add(20*time.Second))
+	// 다음 1분 구간의 보고가 앞 구간을 닫는다
+	s.Record(info, &AgentStatus{CPUUsage: 50}, start.Add(time.Minute))
+
+	seriesDir := filepath.Join(dir, "metrics", seriesDirName(info.MacAddr))
+	if _, err := os.Stat(seriesDir); !os.IsNotExist(err) {
+		t.Fatalf("files written while recording: %v", err)
+	}
+
+	s.flush()
+	if _, err := os.Stat(filepath.Join(seriesDir, "meta.json")); err != nil {
+		t.Errorf("meta.json: %v", err)
+	}
+	points, err := readPoints(filepath.Join(seriesDir, "minute.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].Count != 2 || points[0].CPU != 20 {
+		t.Errorf("minute points = %+v", points)
+	}
+}
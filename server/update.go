@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopc-server/config"
+)
+
+// 업데이트 파일 이름 (updates 디렉토리 기준)
+const updateFileName = "agent.exe"
+
+// UpdateManifest 에이전트 업데이트 매니페스트
+// 에이전트는 Signature를 고정된 공개키로 검증한 뒤에만 파일을 교체한다.
+type UpdateManifest struct {
+	Version   string `json:"version"`
+	File      string `json:"file"`
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size"`
+	Signature string `json:"signature"` // base64(Ed25519(manifestPayload))
+}
+
+// manifestPayload 서명 대상 문자열 (에이전트의 동일 함수와 형식이 같아야 함)
+func manifestPayload(version, file, sum string, size int64) []byte {
+	return []byte(fmt.Sprintf("gopc-agent-update\n%s\n%s\n%s\n%d", version, file, sum, size))
+}
+
+// updatePublisher 업데이트 파일의 해시/서명을 계산하고 캐시한다.
+type updatePublisher struct {
+	mu       sync.Mutex
+	cfg      *config.Config
+	key      ed25519.PrivateKey
+	manifest *UpdateManifest
+	modTime  time.Time
+	size     int64
+}
+
+func newUpdatePublisher(cfg *config.Config) *updatePublisher {
+	p := &updatePublisher{cfg: cfg}
+	key, err := loadUpdateKey(cfg.UpdateKeyFile)
+	if err != nil {
+		log.Printf("업데이트: 서명 키를 불러올 수 없습니다. 매니페스트가 제공되지 않습니다: %v", err)
+		return p
+	}
+	p.key = key
+	log.Printf("업데이트: 서명 공개키 %s", base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+	return p
+}
+
+// Manifest 현재 업데이트 파일에 대한 서명된 매니페스트 반환
+func (p *updatePublisher) Manifest() (*UpdateManifest, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.key == nil {
+		return nil, fmt.Errorf("update signing key not configured")
+	}
+
+	path := filepath.Join(p.cfg.UpdatesDir, updateFileName)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 파일이 바뀌지 않았으면 캐시된 매니페스트 사용
+	if p.manifest != nil && fi.ModTime().Equal(p.modTime) && fi.Size() == p.size {
+		return p.manifest, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	sig := ed25519.Sign(p.key, manifestPayload(p.cfg.AgentVersion, updateFileName, sum, size))
+	p.manifest = &UpdateManifest{
+		Version:   p.cfg.AgentVersion,
+		File:      updateFileName,
+		SHA256:    sum,
+		Size:      size,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	}
+	p.modTime = fi.ModTime()
+	p.size = fi.Size()
+	log.Printf("업데이트: 매니페스트 생성 (version=%s, size=%d, sha256=%s)", p.manifest.Version, size, sum)
+	return p.manifest, nil
+}
+
+func (p *updatePublisher) handleManifest(w http.ResponseWriter, r *http.Request) {
+	m, err := p.Manifest()
+	if err != nil {
+		log.Printf("업데이트: 매니페스트 오류: %v", err)
+		http.Error(w, "update manifest unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// loadUpdateKey base64로 인코딩된 Ed25519 시드 파일을 읽는다.
+func loadUpdateKey(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		return nil, fmt.Errorf("update_key_file is empty")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid key size %d", len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// generateUpdateKey 새 서명 키를 생성하여 저장하고 공개키를 반환한다.
+func generateUpdateKey(path string) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	seed := base64.StdEncoding.EncodeToString(priv.Seed())
+	if err := os.WriteFile(path, []byte(seed+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// handleUpdateStatus 에이전트가 보고한 업데이트 결과를 기록하고 대시보드에 전달
+func handleUpdateStatus(msg map[string]interface{}, agentID string) {
+	data, _ := json.Marshal(msg["update"])
+	log.Printf("Update status from %s: %s", agentID, data)
+
+	broadcastToDashboards(map[string]interface{}{
+		"type":     "update_status",
+		"agent_id": agentID,
+		"update":   msg["update"],
+	})
+}